@@ -0,0 +1,341 @@
+// 用户活跃度统计组件，基于Redis位图实现
+// 每天一个位图，以用户ID作为位偏移量记录当天活跃的用户，可统计日活、周活、月活(BITOP OR)及留存(BITOP AND)；
+// 同时为每个用户维护一个以天为偏移量的位图，用于签到日历及连续签到天数(BITFIELD)统计。
+//
+//	a := pactivity.New(redisClient, "login")
+//	first, err := a.Mark(ctx, uid, time.Now()) // first为true表示当天首次活跃
+//	dau, err := a.DAU(ctx, time.Now())
+//	streak, err := a.Streak(ctx, uid, time.Now())
+//	r, err := a.Retention(ctx, time.Now().AddDate(0, 0, -7), true, 1, 3, 7) // 7天前新增用户的次日、3日、7日留存
+//
+// 同一统计的所有键使用相同的hash tag，集群模式下位于同一槽位；位图大小取决于最大用户ID，
+// 用户ID从较大的数开始时可通过 BaseID 减小位图
+package pactivity
+
+import (
+	"context"
+	"errors"
+	"math/bits"
+	"strconv"
+	"time"
+
+	"github.com/perpower/goframe/utils/pdb/redis"
+	"github.com/perpower/goframe/utils/prand"
+)
+
+type Options struct {
+	Prefix    string         // 键名前缀，默认 "activity:"
+	BaseID    int64          // 用户ID减去该值后作为位偏移量
+	Retention time.Duration  // 每日位图的保留时间，默认400天，负数表示不过期
+	Epoch     time.Time      // 用户位图中第0位对应的日期，默认2020-01-01
+	Location  *time.Location // 划分自然日使用的时区，默认time.Local
+}
+
+type Activity struct {
+	client *redis.Client
+	name   string
+	opts   Options
+}
+
+// Retention 留存统计结果
+type Retention struct {
+	Cohort   int   // 队列人数
+	Days     []int // 第几日
+	Retained []int // 与Days对应的留存人数
+}
+
+var (
+	defaultPrefix    = "activity:"
+	defaultRetention = 400 * 24 * time.Hour
+	defaultEpoch     = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxOffset        = int64(1)<<32 - 1 // 位图最大为512MB
+	fieldWidth       = 63               // BITFIELD无符号整数最大宽度
+	fieldsPerCall    = 8                // 统计连续天数时每次BITFIELD读取的整数个数
+	tmpKeyTTL        = time.Minute
+
+	ErrInvalidUser = errors.New("pactivity: 用户ID减去BaseID后需在0到2^32-1之间")
+	ErrInvalidDay  = errors.New("pactivity: 日期不能早于Epoch")
+
+	// 记录活跃，同时记录首次活跃的新用户
+	// KEYS: day, user, seen, new  ARGV: 用户偏移量, 日期偏移量, 每日位图保留时间(ms)
+	markScript = redis.NewScript(`
+local old = redis.call("SETBIT", KEYS[1], ARGV[1], 1)
+redis.call("SETBIT", KEYS[2], ARGV[2], 1)
+if redis.call("SETBIT", KEYS[3], ARGV[1], 1) == 0 then
+	redis.call("SETBIT", KEYS[4], ARGV[1], 1)
+	if tonumber(ARGV[3]) > 0 then
+		redis.call("PEXPIRE", KEYS[4], ARGV[3])
+	end
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[3])
+end
+return old`)
+)
+
+// New 创建活跃度统计
+// client: *redis.Client redis客户端
+// name: string 统计名称，如 login、sign
+// opts: Options 可选配置，未设置的项使用默认值
+func New(client *redis.Client, name string, opts ...Options) *Activity {
+	opt := Options{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Prefix == "" {
+		opt.Prefix = defaultPrefix
+	}
+	if opt.Retention == 0 {
+		opt.Retention = defaultRetention
+	}
+	if opt.Location == nil {
+		opt.Location = time.Local
+	}
+	if opt.Epoch.IsZero() {
+		opt.Epoch = defaultEpoch
+	}
+	opt.Epoch = time.Date(opt.Epoch.Year(), opt.Epoch.Month(), opt.Epoch.Day(), 0, 0, 0, 0, opt.Location)
+	return &Activity{
+		client: client,
+		name:   name,
+		opts:   opt,
+	}
+}
+
+// Mark 记录用户在指定日期活跃(签到)
+// return: bool 是否为当天首次记录
+func (a *Activity) Mark(ctx context.Context, userID int64, day time.Time) (bool, error) {
+	offset, err := a.offset(userID)
+	if err != nil {
+		return false, err
+	}
+	index, err := a.dayIndex(day)
+	if err != nil {
+		return false, err
+	}
+	var ttl int64
+	if a.opts.Retention > 0 {
+		ttl = a.opts.Retention.Milliseconds()
+	}
+	old, err := markScript.Do(a.client.WithContext(ctx),
+		[]string{a.dayKey(day), a.userKey(userID), a.key("seen"), a.newKey(day)},
+		offset, index, ttl).Int()
+	return old == 0, err
+}
+
+// Active 查询用户在指定日期是否活跃
+func (a *Activity) Active(ctx context.Context, userID int64, day time.Time) (bool, error) {
+	offset, err := a.offset(userID)
+	if err != nil {
+		return false, err
+	}
+	bit, err := a.client.WithContext(ctx).Bit.Getbit(a.dayKey(day), int(offset))
+	return bit == 1, err
+}
+
+// DAU 指定日期的活跃用户数
+func (a *Activity) DAU(ctx context.Context, day time.Time) (int, error) {
+	return a.client.WithContext(ctx).Bit.Bitcount(a.dayKey(day), 0, -1)
+}
+
+// WAU 截止指定日期(含)最近7天的活跃用户数
+func (a *Activity) WAU(ctx context.Context, day time.Time) (int, error) {
+	return a.Count(ctx, day.AddDate(0, 0, -6), day)
+}
+
+// MAU 截止指定日期(含)最近30天的活跃用户数
+func (a *Activity) MAU(ctx context.Context, day time.Time) (int, error) {
+	return a.Count(ctx, day.AddDate(0, 0, -29), day)
+}
+
+// Count 日期区间[from, to]内活跃过的用户数(去重)
+func (a *Activity) Count(ctx context.Context, from, to time.Time) (int, error) {
+	keys := make([]string, 0)
+	for d := a.truncate(from); !d.After(a.truncate(to)); d = d.AddDate(0, 0, 1) {
+		keys = append(keys, a.dayKey(d))
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if len(keys) == 1 {
+		return a.DAU(ctx, from)
+	}
+	return a.combine(ctx, "OR", keys)
+}
+
+// Retention 留存统计，队列为指定日期活跃(或新增)的用户，统计其在之后第N日仍活跃的人数
+// day: time.Time 队列日期
+// newUsers: bool 为true时队列为当天首次活跃的新用户，否则为当天所有活跃用户
+// days: ...int 第几日留存，如 1, 3, 7 分别为次日、3日、7日留存
+func (a *Activity) Retention(ctx context.Context, day time.Time, newUsers bool, days ...int) (Retention, error) {
+	cohortKey := a.dayKey(day)
+	if newUsers {
+		cohortKey = a.newKey(day)
+	}
+	cohort, err := a.client.WithContext(ctx).Bit.Bitcount(cohortKey, 0, -1)
+	if err != nil {
+		return Retention{}, err
+	}
+	r := Retention{Cohort: cohort, Days: days, Retained: make([]int, len(days))}
+	if cohort == 0 {
+		return r, nil
+	}
+	for i, n := range days {
+		r.Retained[i], err = a.combine(ctx, "AND", []string{cohortKey, a.dayKey(day.AddDate(0, 0, n))})
+		if err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// Rate 第i个留存日的留存率
+func (r Retention) Rate(i int) float64 {
+	if r.Cohort == 0 || i < 0 || i >= len(r.Retained) {
+		return 0
+	}
+	return float64(r.Retained[i]) / float64(r.Cohort)
+}
+
+// Streak 截止指定日期(含)的连续活跃天数，指定日期未活跃时返回0
+// 如需"今天未签到时显示截止昨天的连续天数"，可在返回0时再查询前一天
+func (a *Activity) Streak(ctx context.Context, userID int64, day time.Time) (int, error) {
+	end, err := a.dayIndex(day)
+	if err != nil {
+		return 0, err
+	}
+	client := a.client.WithContext(ctx)
+	key := a.userKey(userID)
+	streak := 0
+	for end >= 0 {
+		// 从end往前按63位一段读取，每段中末位对应最近的一天
+		ops := make([]redis.BitfieldOp, 0, fieldsPerCall)
+		widths := make([]int, 0, fieldsPerCall)
+		for i := 0; i < fieldsPerCall && end >= 0; i++ {
+			width := fieldWidth
+			if end+1 < width {
+				width = end + 1
+			}
+			start := end - width + 1
+			ops = append(ops, redis.BitfieldOp{Op: "GET", Type: "u" + strconv.Itoa(width), Offset: strconv.Itoa(start)})
+			widths = append(widths, width)
+			end = start - 1
+		}
+		values, err := client.Bit.Bitfield(key, ops)
+		if err != nil {
+			return streak, err
+		}
+		for i, v := range values {
+			ones := 0
+			if v != nil {
+				ones = bits.TrailingZeros64(^uint64(*v))
+			}
+			if ones < widths[i] {
+				return streak + ones, nil
+			}
+			streak += widths[i]
+		}
+	}
+	return streak, nil
+}
+
+// History 用户在日期区间[from, to]内每天是否活跃，用于展示签到日历
+func (a *Activity) History(ctx context.Context, userID int64, from, to time.Time) ([]bool, error) {
+	start, err := a.dayIndex(from)
+	if err != nil {
+		return []bool{}, err
+	}
+	end, err := a.dayIndex(to)
+	if err != nil || end < start {
+		return []bool{}, err
+	}
+
+	ops := make([]redis.BitfieldOp, 0)
+	for offset := start; offset <= end; offset += fieldWidth {
+		width := fieldWidth
+		if end-offset+1 < width {
+			width = end - offset + 1
+		}
+		ops = append(ops, redis.BitfieldOp{Op: "GET", Type: "u" + strconv.Itoa(width), Offset: strconv.Itoa(offset)})
+	}
+	values, err := a.client.WithContext(ctx).Bit.Bitfield(a.userKey(userID), ops)
+	if err != nil {
+		return []bool{}, err
+	}
+
+	res := make([]bool, 0, end-start+1)
+	for i, v := range values {
+		width := fieldWidth
+		if i == len(values)-1 {
+			width = end - start + 1 - i*fieldWidth
+		}
+		var n uint64
+		if v != nil {
+			n = uint64(*v)
+		}
+		// 高位在前
+		for j := width - 1; j >= 0; j-- {
+			res = append(res, n>>uint(j)&1 == 1)
+		}
+	}
+	return res, nil
+}
+
+// 多个位图进行位运算后统计，临时结果用完即删
+func (a *Activity) combine(ctx context.Context, operation string, keys []string) (int, error) {
+	tmp := a.key("tmp:" + prand.Letters(16))
+	p := a.client.WithContext(ctx).Pipeline()
+	p.Bit.Bitop(operation, tmp, keys)
+	p.Do("PEXPIRE", tmp, tmpKeyTTL.Milliseconds())
+	p.Bit.Bitcount(tmp, 0, -1)
+	count := p.Last()
+	p.Do("UNLINK", tmp)
+	if _, err := p.Exec(); err != nil {
+		return 0, err
+	}
+	return count.Int()
+}
+
+func (a *Activity) offset(userID int64) (int64, error) {
+	offset := userID - a.opts.BaseID
+	if offset < 0 || offset > maxOffset {
+		return 0, ErrInvalidUser
+	}
+	return offset, nil
+}
+
+// 日期距Epoch的天数
+func (a *Activity) dayIndex(day time.Time) (int, error) {
+	d := a.truncate(day)
+	if d.Before(a.opts.Epoch) {
+		return 0, ErrInvalidDay
+	}
+	// 按日历日计算，不受夏令时影响
+	y1, m1, d1 := a.opts.Epoch.Date()
+	y2, m2, d2 := d.Date()
+	t1 := time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC)
+	return int(t2.Sub(t1).Hours() / 24), nil
+}
+
+func (a *Activity) truncate(t time.Time) time.Time {
+	t = t.In(a.opts.Location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, a.opts.Location)
+}
+
+// 键名，使用hash tag保证同一统计的键位于同一槽位，以便进行位运算
+func (a *Activity) key(name string) string {
+	return a.opts.Prefix + "{" + a.name + "}:" + name
+}
+
+func (a *Activity) dayKey(day time.Time) string {
+	return a.key(a.truncate(day).Format("20060102"))
+}
+
+func (a *Activity) newKey(day time.Time) string {
+	return a.key("new:" + a.truncate(day).Format("20060102"))
+}
+
+func (a *Activity) userKey(userID int64) string {
+	return a.key("user:" + strconv.FormatInt(userID, 10))
+}
@@ -0,0 +1,228 @@
+package pactivity
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/perpower/goframe/utils/pdb/redis"
+	"github.com/perpower/goframe/utils/pdb/redis/redistest"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newActivity(t *testing.T, opts Options) (*Activity, *redistest.Server) {
+	t.Helper()
+	s := redistest.NewServer(t)
+	client, err := redis.New(redis.Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if opts.Epoch.IsZero() {
+		opts.Epoch = epoch
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	return New(client, "sign", opts), s
+}
+
+// Epoch之后第n天的中午
+func day(n int) time.Time {
+	return epoch.AddDate(0, 0, n).Add(12 * time.Hour)
+}
+
+// 直接设置用户位图中[from, to]天的位
+func markRange(s *redistest.Server, key string, from, to int) {
+	for i := from; i <= to; i++ {
+		s.Do("SETBIT", key, strconv.Itoa(i), "1")
+	}
+}
+
+func TestMark(t *testing.T) {
+	a, s := newActivity(t, Options{BaseID: 1000, Retention: time.Hour})
+	ctx := context.Background()
+
+	if first, err := a.Mark(ctx, 1001, day(0)); err != nil || !first {
+		t.Fatalf("Mark = %v, %v, want the first mark", first, err)
+	}
+	if first, err := a.Mark(ctx, 1001, day(0)); err != nil || first {
+		t.Fatalf("Mark twice = %v, %v", first, err)
+	}
+	a.Mark(ctx, 1005, day(0))
+	a.Mark(ctx, 1005, day(1))
+
+	if active, err := a.Active(ctx, 1001, day(0)); err != nil || !active {
+		t.Fatalf("Active = %v, %v", active, err)
+	}
+	if active, _ := a.Active(ctx, 1001, day(1)); active {
+		t.Fatal("1001 was not active on day 1")
+	}
+	if n, err := a.DAU(ctx, day(0)); err != nil || n != 2 {
+		t.Fatalf("DAU = %d, %v", n, err)
+	}
+	if n, err := a.WAU(ctx, day(1)); err != nil || n != 2 {
+		t.Fatalf("WAU = %d, %v", n, err)
+	}
+	if n, err := a.Count(ctx, day(1), day(1)); err != nil || n != 1 {
+		t.Fatalf("Count of a single day = %d, %v", n, err)
+	}
+	if n, err := a.Count(ctx, day(2), day(1)); err != nil || n != 0 {
+		t.Fatalf("Count of an empty range = %d, %v", n, err)
+	}
+
+	// 按BaseID计算位偏移量，每日位图按Retention过期
+	dayKey := "activity:{sign}:20260101"
+	if s.Do("GETBIT", dayKey, "5") != int64(1) {
+		t.Fatal("user 1005 should be stored at offset 5")
+	}
+	if ttl, _ := s.Do("PTTL", dayKey).(int64); ttl <= 0 || ttl > time.Hour.Milliseconds() {
+		t.Fatalf("PTTL = %d", ttl)
+	}
+	// 位运算的临时键用完即删
+	for _, key := range s.Keys() {
+		if strings.Contains(key, "tmp:") {
+			t.Fatalf("temporary key left: %s", key)
+		}
+	}
+
+	if _, err := a.Mark(ctx, 999, day(0)); err != ErrInvalidUser {
+		t.Fatalf("Mark below BaseID = %v, want ErrInvalidUser", err)
+	}
+	if _, err := a.Mark(ctx, 1001, epoch.AddDate(0, 0, -1)); err != ErrInvalidDay {
+		t.Fatalf("Mark before Epoch = %v, want ErrInvalidDay", err)
+	}
+}
+
+func TestStreak(t *testing.T) {
+	a, s := newActivity(t, Options{})
+	ctx := context.Background()
+	key := a.userKey(1)
+
+	// 从Epoch开始连续130天，跨越两个63位分段，最后一段不足63位
+	markRange(s, key, 0, 129)
+	// 恰好63天及64天，连续区间的起点位于分段边界两侧
+	markRange(s, key, 200, 262)
+	markRange(s, key, 300, 363)
+	// 超过一次BITFIELD读取的分段数(8*63=504天)
+	markRange(s, key, 1000, 1599)
+
+	cases := []struct {
+		day, want int
+	}{
+		{129, 130},
+		{62, 63},
+		{63, 64},
+		{0, 1},
+		{130, 0},
+		{262, 63},
+		{263, 0},
+		{363, 64},
+		{325, 26},
+		{1599, 600},
+		{1504, 505},
+		{1503, 504},
+	}
+	for _, c := range cases {
+		if got, err := a.Streak(ctx, 1, day(c.day)); err != nil || got != c.want {
+			t.Errorf("Streak on day %d = %d, %v, want %d", c.day, got, err, c.want)
+		}
+	}
+
+	// 用户位图不存在
+	if got, err := a.Streak(ctx, 2, day(10)); err != nil || got != 0 {
+		t.Fatalf("Streak of an unknown user = %d, %v", got, err)
+	}
+	if _, err := a.Streak(ctx, 1, epoch.AddDate(0, 0, -1)); err != ErrInvalidDay {
+		t.Fatalf("Streak before Epoch = %v, want ErrInvalidDay", err)
+	}
+
+	// 通过Mark写入的连续签到
+	for i := 2000; i < 2003; i++ {
+		a.Mark(ctx, 3, day(i))
+	}
+	if got, _ := a.Streak(ctx, 3, day(2002)); got != 3 {
+		t.Fatalf("Streak after Mark = %d, want 3", got)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	a, s := newActivity(t, Options{})
+	ctx := context.Background()
+	key := a.userKey(1)
+	active := map[int]bool{}
+	for _, i := range []int{5, 10, 11, 67, 68, 72, 135, 139} {
+		s.Do("SETBIT", key, strconv.Itoa(i), "1")
+		active[i] = true
+	}
+
+	// 区间跨越两个63位分段，最后一段不足63位
+	for _, r := range [][2]int{{5, 139}, {10, 72}, {0, 0}, {68, 68}, {6, 9}} {
+		got, err := a.History(ctx, 1, day(r[0]), day(r[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != r[1]-r[0]+1 {
+			t.Fatalf("History %v has %d days", r, len(got))
+		}
+		for i, v := range got {
+			if v != active[r[0]+i] {
+				t.Fatalf("History %v: day %d = %v", r, r[0]+i, v)
+			}
+		}
+	}
+
+	if got, err := a.History(ctx, 1, day(10), day(5)); err != nil || len(got) != 0 {
+		t.Fatalf("History with to before from = %v, %v", got, err)
+	}
+	if got, err := a.History(ctx, 2, day(0), day(3)); err != nil || len(got) != 4 || got[0] || got[3] {
+		t.Fatalf("History of an unknown user = %v, %v", got, err)
+	}
+	if _, err := a.History(ctx, 1, epoch.AddDate(0, 0, -1), day(3)); err != ErrInvalidDay {
+		t.Fatalf("History before Epoch = %v, want ErrInvalidDay", err)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	a, _ := newActivity(t, Options{})
+	ctx := context.Background()
+
+	// 用户1在队列日之前已活跃过，不计入新增用户
+	a.Mark(ctx, 1, day(9))
+	for _, uid := range []int64{1, 2, 3, 4} {
+		a.Mark(ctx, uid, day(10))
+	}
+	a.Mark(ctx, 1, day(11))
+	a.Mark(ctx, 2, day(11))
+	a.Mark(ctx, 3, day(11))
+	a.Mark(ctx, 2, day(13))
+	a.Mark(ctx, 5, day(13))
+
+	r, err := a.Retention(ctx, day(10), false, 1, 3, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Cohort != 4 || len(r.Days) != 3 || r.Retained[0] != 3 || r.Retained[1] != 1 || r.Retained[2] != 0 {
+		t.Fatalf("Retention = %+v", r)
+	}
+	if r.Rate(0) != 0.75 || r.Rate(1) != 0.25 || r.Rate(3) != 0 {
+		t.Fatalf("Rate = %v, %v", r.Rate(0), r.Rate(1))
+	}
+
+	r, err = a.Retention(ctx, day(10), true, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Cohort != 3 || r.Retained[0] != 2 || r.Retained[1] != 1 {
+		t.Fatalf("new user Retention = %+v", r)
+	}
+
+	// 队列为空
+	r, err = a.Retention(ctx, day(20), true, 1)
+	if err != nil || r.Cohort != 0 || r.Retained[0] != 0 || r.Rate(0) != 0 {
+		t.Fatalf("empty Retention = %+v, %v", r, err)
+	}
+}
@@ -0,0 +1,427 @@
+// 对象缓存组件，基于redis实现旁路缓存(cache-aside)
+// 读取时先查缓存，未命中再调用loader回源(如查询mysql)并写入缓存，例如：
+//
+//	cache := pcache.New(redisClient, pcache.Options{Codec: pcache.MsgPack()})
+//	user, err := pcache.GetOrLoad(ctx, cache, "user:1", 10*time.Minute, func(ctx context.Context) (User, error) {
+//		var u User
+//		res, err := db.GetOne(params)
+//		if err != nil {
+//			return u, err
+//		}
+//		if len(res) == 0 {
+//			return u, pcache.ErrNotFound
+//		}
+//		...
+//		return u, nil
+//	}, "users")
+//
+//	// 用户数据变更后，按标签批量失效
+//	cache.InvalidateTags(ctx, "users")
+//
+// 特性：
+//  1. 同一key的并发未命中只回源一次(singleflight)，其余请求共享结果
+//  2. loader返回 ErrNotFound(或gorm.ErrRecordNotFound)时缓存空结果，避免缓存穿透
+//  3. 过期时间加入随机抖动，避免大量key同时过期造成缓存雪崩
+//  4. 写入时可附带标签，按标签批量失效
+//  5. redis不可用时直接回源，不影响业务
+//  6. 可选的进程内LRU缓存作为一级缓存，适用于配置、字典等热点数据，
+//     Set、Delete、InvalidateTags 时通过redis发布订阅通知其他实例删除本地副本
+package pcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/perpower/goframe/utils/pdb/redis"
+	"github.com/perpower/goframe/utils/prand"
+	"gorm.io/gorm"
+)
+
+type Options struct {
+	Prefix      string               // 缓存key前缀，默认 "cache:"
+	Codec       Codec                // 序列化方式，默认JSON
+	TTL         time.Duration        // 调用时未指定ttl时使用的默认过期时间，默认10分钟
+	Jitter      float64              // 过期时间随机抖动比例，如0.1表示在[ttl, ttl*1.1]之间随机，默认0.1，小于0表示不抖动
+	NotFoundTTL time.Duration        // 数据不存在时空结果的缓存时间，默认1分钟，小于0表示不缓存空结果
+	IsNotFound  func(err error) bool // 判断loader返回的错误是否表示数据不存在，默认匹配 ErrNotFound 及 gorm.ErrRecordNotFound
+	Local       *LocalOptions        // 本地缓存配置，为空时不启用
+}
+
+type Cache struct {
+	client *redis.Client
+	opts   Options
+	flight group
+	local  *local
+	id     string             // 实例标识，忽略自己发出的失效广播
+	cancel context.CancelFunc // 结束失效广播的订阅
+
+	localHits   int64
+	localMisses int64
+	redisHits   int64
+	redisMisses int64
+}
+
+// 单层缓存的命中统计
+type TierStats struct {
+	Hits   int64
+	Misses int64
+}
+
+// 缓存统计
+type Stats struct {
+	Local        TierStats // 本地缓存
+	Redis        TierStats // redis缓存
+	LocalEntries int       // 本地缓存当前条目数
+	LocalBytes   int64     // 本地缓存当前占用字节数
+	Evictions    int64     // 本地缓存因容量不足累计淘汰的条目数
+}
+
+// 失效广播消息
+type invalidation struct {
+	From string   `json:"from"`
+	Keys []string `json:"keys"`
+}
+
+var (
+	defaultPrefix      = "cache:"
+	defaultTTL         = 10 * time.Minute
+	defaultJitter      = 0.1
+	defaultNotFoundTTL = time.Minute
+	tagPrefix          = "tag:"
+
+	// 缓存值首字节标记
+	markValue    byte = 'v'
+	markNotFound byte = 'n'
+
+	ErrNotFound = errors.New("pcache: 数据不存在")
+	ErrMiss     = errors.New("pcache: 缓存未命中")
+)
+
+// New 创建缓存对象
+// client: *redis.Client redis客户端
+// opts: Options 可选配置，未设置的项使用默认值
+func New(client *redis.Client, opts ...Options) *Cache {
+	opt := Options{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Prefix == "" {
+		opt.Prefix = defaultPrefix
+	}
+	if opt.Codec == nil {
+		opt.Codec = JSON()
+	}
+	if opt.TTL <= 0 {
+		opt.TTL = defaultTTL
+	}
+	if opt.Jitter == 0 {
+		opt.Jitter = defaultJitter
+	}
+	if opt.NotFoundTTL == 0 {
+		opt.NotFoundTTL = defaultNotFoundTTL
+	}
+	if opt.IsNotFound == nil {
+		opt.IsNotFound = func(err error) bool {
+			return errors.Is(err, ErrNotFound) || errors.Is(err, gorm.ErrRecordNotFound)
+		}
+	}
+	c := &Cache{
+		client: client,
+		opts:   opt,
+	}
+	if opt.Local != nil {
+		local := *opt.Local
+		if local.Channel == "" {
+			local.Channel = opt.Prefix + "invalidate"
+		}
+		c.opts.Local = &local
+		c.local = newLocal(c.opts.Local)
+		c.id = prand.Letters(16)
+		c.subscribe()
+	}
+	return c
+}
+
+// Close 停止接收失效广播，未启用本地缓存时无需调用
+func (c *Cache) Close() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// Stats 返回各层缓存的命中统计
+func (c *Cache) Stats() Stats {
+	stats := Stats{
+		Local: TierStats{
+			Hits:   atomic.LoadInt64(&c.localHits),
+			Misses: atomic.LoadInt64(&c.localMisses),
+		},
+		Redis: TierStats{
+			Hits:   atomic.LoadInt64(&c.redisHits),
+			Misses: atomic.LoadInt64(&c.redisMisses),
+		},
+	}
+	if c.local != nil {
+		stats.LocalEntries, stats.LocalBytes, stats.Evictions = c.local.usage()
+	}
+	return stats
+}
+
+// GetOrLoad 读取缓存，未命中时调用loader回源并写入缓存
+// ctx: context.Context 并发未命中合并回源时，loader使用第一个请求的ctx执行
+// c: *Cache 缓存对象
+// key: string 缓存key，实际存储时会加上前缀
+// ttl: time.Duration 过期时间，传0使用默认值
+// loader: 回源函数，数据不存在时返回 ErrNotFound
+// tags: ...string 缓存标签，用于 InvalidateTags 批量失效
+// return: 数据不存在时返回 ErrNotFound
+func GetOrLoad[T any](ctx context.Context, c *Cache, key string, ttl time.Duration, loader func(ctx context.Context) (T, error), tags ...string) (T, error) {
+	value, err := Get[T](ctx, c, key)
+	if err != ErrMiss {
+		return value, err
+	}
+
+	res, err := c.flight.do(key, func() (interface{}, error) {
+		// 等待期间可能已有其他实例写入缓存
+		if value, err := Get[T](ctx, c, key); err != ErrMiss {
+			return value, err
+		}
+
+		value, err := loader(ctx)
+		if err != nil {
+			if c.opts.IsNotFound(err) {
+				if c.opts.NotFoundTTL > 0 {
+					c.set(ctx, key, []byte{markNotFound}, c.opts.NotFoundTTL, tags)
+				}
+				return value, ErrNotFound
+			}
+			return value, err
+		}
+		// 回源前缓存中不存在该key，其他实例也没有本地副本，无需广播
+		c.store(ctx, key, value, ttl, tags, false)
+		return value, nil
+	})
+	value, _ = res.(T)
+	return value, err
+}
+
+// Get 读取缓存
+// return: 未命中返回 ErrMiss，命中空结果返回 ErrNotFound
+func Get[T any](ctx context.Context, c *Cache, key string) (T, error) {
+	var value T
+	data, err := c.get(ctx, key)
+	if err != nil || len(data) == 0 {
+		return value, ErrMiss
+	}
+	switch data[0] {
+	case markNotFound:
+		return value, ErrNotFound
+	case markValue:
+		if err := c.opts.Codec.Unmarshal(data[1:], &value); err == nil {
+			return value, nil
+		}
+	}
+	// 无法解析的缓存值(如结构体定义已变更)视为未命中
+	return value, ErrMiss
+}
+
+// Set 写入缓存
+// ttl: time.Duration 过期时间，传0使用默认值
+// tags: ...string 缓存标签
+func Set[T any](ctx context.Context, c *Cache, key string, value T, ttl time.Duration, tags ...string) error {
+	return c.store(ctx, key, value, ttl, tags, true)
+}
+
+// Delete 删除缓存
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	fullKeys := make([]string, 0, len(keys))
+	p := c.client.WithContext(ctx).Pipeline()
+	for _, key := range keys {
+		// 逐个删除，集群模式下各key可能位于不同节点
+		p.Do("UNLINK", c.key(key))
+		fullKeys = append(fullKeys, c.key(key))
+	}
+	if _, err := p.Exec(); err != nil {
+		return err
+	}
+	return c.evict(ctx, fullKeys)
+}
+
+// InvalidateTags 使带有指定标签的缓存全部失效
+func (c *Cache) InvalidateTags(ctx context.Context, tags ...string) error {
+	client := c.client.WithContext(ctx)
+	for _, tag := range tags {
+		tagKey := c.tagKey(tag)
+		members, err := redigo.Strings(client.Do("SMEMBERS", tagKey))
+		if err != nil {
+			return err
+		}
+		if len(members) == 0 {
+			continue
+		}
+		p := client.Pipeline()
+		for _, member := range members {
+			p.Do("UNLINK", member)
+		}
+		// 只移除已删除的成员，期间新写入的缓存仍保留在标签中
+		args := make([]interface{}, 0, len(members)+1)
+		args = append(args, tagKey)
+		for _, member := range members {
+			args = append(args, member)
+		}
+		p.Do("SREM", args...)
+		if _, err := p.Exec(); err != nil {
+			return err
+		}
+		if err := c.evict(ctx, members); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cache) key(key string) string {
+	return c.opts.Prefix + key
+}
+
+func (c *Cache) tagKey(tag string) string {
+	return c.opts.Prefix + tagPrefix + tag
+}
+
+// 过期时间加入随机抖动
+func (c *Cache) jitter(ttl time.Duration) time.Duration {
+	if c.opts.Jitter <= 0 {
+		return ttl
+	}
+	spread := int64(float64(ttl) * c.opts.Jitter)
+	if spread <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int63n(spread+1))
+}
+
+// 依次读取本地缓存、redis，redis命中时写入本地缓存
+func (c *Cache) get(ctx context.Context, key string) ([]byte, error) {
+	fullKey := c.key(key)
+	if c.local == nil {
+		data, err := redigo.Bytes(c.client.WithContext(ctx).Do("GET", fullKey))
+		c.count(&c.redisHits, &c.redisMisses, err == nil)
+		return data, err
+	}
+
+	data, ok := c.local.get(fullKey)
+	c.count(&c.localHits, &c.localMisses, ok)
+	if ok {
+		return data, nil
+	}
+
+	// 同时读取剩余过期时间，本地副本不晚于redis中的值过期
+	p := c.client.WithContext(ctx).Pipeline()
+	value := p.Do("GET", fullKey)
+	pttl := p.Do("PTTL", fullKey)
+	p.Exec()
+	data, err := redigo.Bytes(value.Value())
+	c.count(&c.redisHits, &c.redisMisses, err == nil)
+	if err != nil {
+		return nil, err
+	}
+	ms, _ := pttl.Int64()
+	if ms > 0 {
+		c.local.set(fullKey, data, time.Duration(ms)*time.Millisecond)
+	}
+	return data, nil
+}
+
+func (c *Cache) count(hits, misses *int64, hit bool) {
+	if hit {
+		atomic.AddInt64(hits, 1)
+	} else {
+		atomic.AddInt64(misses, 1)
+	}
+}
+
+// 序列化并写入缓存
+// broadcast: bool 是否通知其他实例删除本地副本
+func (c *Cache) store(ctx context.Context, key string, value interface{}, ttl time.Duration, tags []string, broadcast bool) error {
+	data, err := c.opts.Codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		ttl = c.opts.TTL
+	}
+	if err := c.set(ctx, key, append([]byte{markValue}, data...), c.jitter(ttl), tags); err != nil {
+		return err
+	}
+	if broadcast {
+		return c.broadcast(ctx, []string{c.key(key)})
+	}
+	return nil
+}
+
+// 删除本地副本并广播给其他实例
+func (c *Cache) evict(ctx context.Context, fullKeys []string) error {
+	if c.local == nil || len(fullKeys) == 0 {
+		return nil
+	}
+	c.local.del(fullKeys...)
+	return c.broadcast(ctx, fullKeys)
+}
+
+// 通知其他实例删除本地副本
+func (c *Cache) broadcast(ctx context.Context, fullKeys []string) error {
+	if c.local == nil {
+		return nil
+	}
+	payload, err := json.Marshal(invalidation{From: c.id, Keys: fullKeys})
+	if err != nil {
+		return err
+	}
+	_, err = c.client.WithContext(ctx).Publish(c.opts.Local.Channel, string(payload))
+	return err
+}
+
+// 订阅失效广播，删除其他实例已变更的本地副本；订阅连接断开期间可能丢失广播，重连后清空本地缓存
+func (c *Cache) subscribe() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	sub := c.client.NewSubscriber(redis.SubscribeOptions{
+		Channels:    []string{c.opts.Local.Channel},
+		OnReconnect: c.local.clear,
+	})
+	go sub.Run(ctx, func(msg redis.Message) {
+		var inv invalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil || inv.From == c.id {
+			return
+		}
+		c.local.del(inv.Keys...)
+	})
+}
+
+// 写入缓存值，并将key加入各标签集合，标签集合的过期时间不短于其中任一缓存
+func (c *Cache) set(ctx context.Context, key string, data []byte, ttl time.Duration, tags []string) error {
+	ms := ttl.Milliseconds()
+	p := c.client.WithContext(ctx).Pipeline()
+	p.Do("SET", c.key(key), data, "PX", ms)
+	for _, tag := range tags {
+		tagKey := c.tagKey(tag)
+		p.Do("SADD", tagKey, c.key(key))
+		p.Do("PEXPIRE", tagKey, ms, "NX")
+		p.Do("PEXPIRE", tagKey, ms, "GT")
+	}
+	if _, err := p.Exec(); err != nil {
+		return err
+	}
+	if c.local != nil {
+		c.local.set(c.key(key), data, ttl)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package pcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/perpower/goframe/utils/pdb/redis"
+	"github.com/perpower/goframe/utils/pdb/redis/redistest"
+	"gorm.io/gorm"
+)
+
+func newClient(t *testing.T, s *redistest.Server) *redis.Client {
+	t.Helper()
+	client, err := redis.New(redis.Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+type user struct {
+	ID   int
+	Name string
+}
+
+func TestGetOrLoad(t *testing.T) {
+	s := redistest.NewServer(t)
+	c := New(newClient(t, s), Options{Jitter: -1})
+	ctx := context.Background()
+
+	calls := 0
+	loader := func(ctx context.Context) (user, error) {
+		calls++
+		return user{ID: 1, Name: "a"}, nil
+	}
+	for i := 0; i < 2; i++ {
+		u, err := GetOrLoad(ctx, c, "user:1", time.Minute, loader, "users")
+		if err != nil || u != (user{ID: 1, Name: "a"}) {
+			t.Fatalf("GetOrLoad = %+v, %v", u, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("loader called %d times, want 1", calls)
+	}
+	if got := s.Do("GET", "cache:user:1"); got != `v{"ID":1,"Name":"a"}` {
+		t.Fatalf("stored value %q", got)
+	}
+	if ttl, _ := s.Do("PTTL", "cache:user:1").(int64); ttl <= 0 || ttl > 60000 {
+		t.Fatalf("PTTL = %d", ttl)
+	}
+	if s.Do("SISMEMBER", "cache:tag:users", "cache:user:1") != int64(1) {
+		t.Fatal("the key should be added to the tag set")
+	}
+
+	// 按标签失效后重新回源
+	if err := c.InvalidateTags(ctx, "users"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Get[user](ctx, c, "user:1"); err != ErrMiss {
+		t.Fatalf("Get after InvalidateTags = %v, want ErrMiss", err)
+	}
+	if _, err := GetOrLoad(ctx, c, "user:1", 0, loader); err != nil || calls != 2 {
+		t.Fatalf("GetOrLoad after InvalidateTags = %v, loader called %d times", err, calls)
+	}
+
+	// loader的其他错误不缓存
+	boom := errors.New("boom")
+	if _, err := GetOrLoad(ctx, c, "user:2", 0, func(ctx context.Context) (user, error) { return user{}, boom }); err != boom {
+		t.Fatalf("GetOrLoad with a failing loader = %v, want boom", err)
+	}
+	if s.Do("EXISTS", "cache:user:2") != int64(0) {
+		t.Fatal("a loader error must not be cached")
+	}
+}
+
+func TestGetOrLoadSingleflight(t *testing.T) {
+	const n = 10
+	var gets int32
+	s := redistest.NewServer(t, func(s *redistest.Server) {
+		s.Hook = func(c *redistest.Conn, args []string) (interface{}, bool) {
+			if args[0] == "GET" {
+				atomic.AddInt32(&gets, 1)
+			}
+			return nil, false
+		}
+	})
+	// 不缓存空结果，并发请求只能通过合并回源共享结果
+	c := New(newClient(t, s), Options{NotFoundTTL: -1})
+	ctx := context.Background()
+
+	var calls int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (user, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return user{}, ErrNotFound
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := GetOrLoad(ctx, c, "user:1", 0, loader)
+			errs <- err
+		}()
+	}
+	// 所有请求读取缓存未命中，且第一个请求已在回源中再次读取缓存
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&gets) < n+1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != ErrNotFound {
+			t.Fatalf("GetOrLoad = %v, want ErrNotFound", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("loader called %d times, want 1", calls)
+	}
+
+	// 合并结束后再次未命中时重新回源
+	if _, err := GetOrLoad(ctx, c, "user:1", 0, func(ctx context.Context) (user, error) {
+		atomic.AddInt32(&calls, 1)
+		return user{}, ErrNotFound
+	}); err != ErrNotFound || calls != 2 {
+		t.Fatalf("GetOrLoad after the flight = %v, loader called %d times", err, calls)
+	}
+}
+
+func TestSingleflightPanic(t *testing.T) {
+	var g group
+	started := make(chan struct{})
+	release := make(chan struct{})
+	waited := make(chan error)
+	go func() {
+		<-started
+		go func() {
+			_, err := g.do("k", func() (interface{}, error) { return nil, nil })
+			waited <- err
+		}()
+		// 等待第二个请求进入等待
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("the panic should be raised in the calling goroutine")
+			}
+		}()
+		g.do("k", func() (interface{}, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	if err := <-waited; err != errLoaderPanic {
+		t.Fatalf("waiter err = %v, want errLoaderPanic", err)
+	}
+	// panic后该key不再处于合并状态
+	if v, err := g.do("k", func() (interface{}, error) { return 1, nil }); v != 1 || err != nil {
+		t.Fatalf("do after panic = %v, %v", v, err)
+	}
+}
+
+func TestNotFoundMarker(t *testing.T) {
+	s := redistest.NewServer(t)
+	c := New(newClient(t, s), Options{NotFoundTTL: 50 * time.Millisecond})
+	ctx := context.Background()
+
+	calls := 0
+	loader := func(ctx context.Context) (user, error) {
+		calls++
+		return user{}, gorm.ErrRecordNotFound
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := GetOrLoad(ctx, c, "user:9", 0, loader, "users"); err != ErrNotFound {
+			t.Fatalf("GetOrLoad = %v, want ErrNotFound", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("loader called %d times, want the empty result to be cached", calls)
+	}
+	if got := s.Do("GET", "cache:user:9"); got != "n" {
+		t.Fatalf("stored marker %q, want n", got)
+	}
+	if ttl, _ := s.Do("PTTL", "cache:user:9").(int64); ttl <= 0 || ttl > 50 {
+		t.Fatalf("PTTL = %d, want within NotFoundTTL", ttl)
+	}
+	if _, err := Get[user](ctx, c, "user:9"); err != ErrNotFound {
+		t.Fatalf("Get = %v, want ErrNotFound", err)
+	}
+
+	// 空结果过期后重新回源
+	time.Sleep(60 * time.Millisecond)
+	if _, err := GetOrLoad(ctx, c, "user:9", 0, loader); err != ErrNotFound || calls != 2 {
+		t.Fatalf("GetOrLoad after expiry = %v, loader called %d times", err, calls)
+	}
+
+	// 写入数据覆盖空结果
+	if err := Set(ctx, c, "user:9", user{ID: 9}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := Get[user](ctx, c, "user:9"); err != nil || u.ID != 9 {
+		t.Fatalf("Get after Set = %+v, %v", u, err)
+	}
+
+	// 无法解析的缓存值视为未命中
+	s.Do("SET", "cache:user:9", "vnot json")
+	if _, err := Get[user](ctx, c, "user:9"); err != ErrMiss {
+		t.Fatalf("Get of an undecodable value = %v, want ErrMiss", err)
+	}
+}
@@ -0,0 +1,43 @@
+package pcache
+
+import (
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec 缓存值的序列化方式
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSON 使用encoding/json序列化，可读性好，便于排查问题
+func JSON() Codec {
+	return jsonCodec{}
+}
+
+// MsgPack 使用msgpack序列化，体积更小、编解码更快
+func MsgPack() Codec {
+	return mpack{}
+}
+
+type jsonCodec struct{}
+
+func (j jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (j jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type mpack struct{}
+
+func (m mpack) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (m mpack) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
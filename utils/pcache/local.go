@@ -0,0 +1,132 @@
+package pcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// 本地缓存配置
+type LocalOptions struct {
+	MaxEntries int           // 最大条目数，默认10000
+	MaxBytes   int64         // 最大占用字节数，按key及序列化后的值计算，默认64MB
+	TTL        time.Duration // 本地缓存时间，默认1分钟，不超过写入redis时的过期时间
+	Channel    string        // 失效广播频道，默认为 Prefix+"invalidate"
+}
+
+// local 进程内LRU缓存，按条目数及字节数限制容量，条目过期后惰性删除
+type local struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+	ll         *list.List
+	items      map[string]*list.Element
+	bytes      int64
+	evictions  int64
+}
+
+type localEntry struct {
+	key    string
+	data   []byte
+	expire time.Time
+}
+
+var (
+	defaultLocalEntries = 10000
+	defaultLocalBytes   = int64(64 << 20)
+	defaultLocalTTL     = time.Minute
+)
+
+func newLocal(opts *LocalOptions) *local {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = defaultLocalEntries
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultLocalBytes
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = defaultLocalTTL
+	}
+	return &local{
+		maxEntries: opts.MaxEntries,
+		maxBytes:   opts.MaxBytes,
+		ttl:        opts.TTL,
+		ll:         list.New(),
+		items:      map[string]*list.Element{},
+	}
+}
+
+func (l *local) get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*localEntry)
+	if time.Now().After(e.expire) {
+		l.remove(el)
+		return nil, false
+	}
+	l.ll.MoveToFront(el)
+	return e.data, true
+}
+
+// ttl: 该值在redis中的过期时间，本地缓存时间不超过它，传0表示未知
+func (l *local) set(key string, data []byte, ttl time.Duration) {
+	size := int64(len(key) + len(data))
+	if size > l.maxBytes {
+		return
+	}
+	if ttl <= 0 || ttl > l.ttl {
+		ttl = l.ttl
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		l.remove(el)
+	}
+	l.items[key] = l.ll.PushFront(&localEntry{
+		key:    key,
+		data:   data,
+		expire: time.Now().Add(ttl),
+	})
+	l.bytes += size
+	for l.ll.Len() > l.maxEntries || l.bytes > l.maxBytes {
+		l.remove(l.ll.Back())
+		l.evictions++
+	}
+}
+
+func (l *local) del(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := l.items[key]; ok {
+			l.remove(el)
+		}
+	}
+}
+
+func (l *local) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ll.Init()
+	l.items = map[string]*list.Element{}
+	l.bytes = 0
+}
+
+// 当前条目数、占用字节数及累计淘汰数
+func (l *local) usage() (int, int64, int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len(), l.bytes, l.evictions
+}
+
+func (l *local) remove(el *list.Element) {
+	e := l.ll.Remove(el).(*localEntry)
+	delete(l.items, e.key)
+	l.bytes -= int64(len(e.key) + len(e.data))
+}
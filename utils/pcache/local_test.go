@@ -0,0 +1,158 @@
+package pcache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/perpower/goframe/utils/pdb/redis/redistest"
+)
+
+// 等待条件成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLocalByteBound(t *testing.T) {
+	s := redistest.NewServer(t)
+	// 每个条目占用 len("cache:k1") + len(`v"12345678"`) = 19 字节，最多容纳两个
+	c := New(newClient(t, s), Options{Local: &LocalOptions{MaxBytes: 50}})
+	defer c.Close()
+	ctx := context.Background()
+
+	for _, key := range []string{"k1", "k2"} {
+		if err := Set(ctx, c, key, "12345678", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := c.Stats(); stats.LocalEntries != 2 || stats.LocalBytes != 38 || stats.Evictions != 0 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+	// 访问k1后k2成为最久未使用的条目
+	if v, err := Get[string](ctx, c, "k1"); err != nil || v != "12345678" {
+		t.Fatalf("Get k1 = %q, %v", v, err)
+	}
+	if err := Set(ctx, c, "k3", "12345678", 0); err != nil {
+		t.Fatal(err)
+	}
+	stats := c.Stats()
+	if stats.LocalEntries != 2 || stats.LocalBytes != 38 || stats.Evictions != 1 {
+		t.Fatalf("Stats after eviction = %+v", stats)
+	}
+	if stats.Local.Hits != 1 || stats.Redis.Hits != 0 {
+		t.Fatalf("hits = %+v", stats)
+	}
+
+	// 被淘汰的k2从redis读取并重新写入本地缓存，淘汰此时最久未使用的k1
+	if v, err := Get[string](ctx, c, "k2"); err != nil || v != "12345678" {
+		t.Fatalf("Get k2 = %q, %v", v, err)
+	}
+	stats = c.Stats()
+	if stats.Local.Misses != 1 || stats.Redis.Hits != 1 || stats.Evictions != 2 {
+		t.Fatalf("Stats after reloading k2 = %+v", stats)
+	}
+	if _, ok := c.local.get("cache:k1"); ok {
+		t.Fatal("k1 should have been evicted")
+	}
+
+	// 超过容量的值只写入redis
+	big := strings.Repeat("x", 64)
+	if err := Set(ctx, c, "big", big, 0); err != nil {
+		t.Fatal(err)
+	}
+	if stats := c.Stats(); stats.LocalEntries != 2 || stats.LocalBytes != 38 || stats.Evictions != 2 {
+		t.Fatalf("Stats after an oversized value = %+v", stats)
+	}
+	if v, err := Get[string](ctx, c, "big"); err != nil || v != big {
+		t.Fatalf("Get big = %v", err)
+	}
+}
+
+func TestLocalEntryBoundAndTTL(t *testing.T) {
+	s := redistest.NewServer(t)
+	c := New(newClient(t, s), Options{Local: &LocalOptions{MaxEntries: 1, TTL: 30 * time.Millisecond}})
+	defer c.Close()
+	ctx := context.Background()
+
+	Set(ctx, c, "a", 1, time.Minute)
+	Set(ctx, c, "b", 2, time.Minute)
+	if stats := c.Stats(); stats.LocalEntries != 1 || stats.Evictions != 1 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+	// 本地副本到期后从redis读取
+	time.Sleep(40 * time.Millisecond)
+	if v, err := Get[int](ctx, c, "b"); err != nil || v != 2 {
+		t.Fatalf("Get = %d, %v", v, err)
+	}
+	if stats := c.Stats(); stats.Local.Misses != 1 || stats.Redis.Hits != 1 {
+		t.Fatalf("Stats = %+v", stats)
+	}
+}
+
+func TestInvalidationBroadcast(t *testing.T) {
+	s := redistest.NewServer(t)
+	a := New(newClient(t, s), Options{Local: &LocalOptions{}})
+	defer a.Close()
+	b := New(newClient(t, s), Options{Local: &LocalOptions{}})
+	defer b.Close()
+	ctx := context.Background()
+	waitFor(t, "both caches to subscribe", func() bool {
+		subs := s.Subscriptions()
+		return len(subs) == 2 && subs[0] == "cache:invalidate" && subs[1] == "cache:invalidate"
+	})
+
+	if err := Set(ctx, a, "k", "old", 0, "t"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := Get[string](ctx, b, "k"); err != nil || v != "old" {
+		t.Fatalf("b Get = %q, %v", v, err)
+	}
+	// a的写入通知b删除本地副本，a忽略自己发出的广播
+	if err := Set(ctx, a, "k", "new", 0, "t"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "b to drop its local copy after Set", func() bool {
+		v, _ := Get[string](ctx, b, "k")
+		return v == "new"
+	})
+	if _, ok := a.local.get("cache:k"); !ok {
+		t.Fatal("a should keep its own local copy")
+	}
+
+	waitFor(t, "b to reload the key", func() bool { return b.Stats().LocalEntries == 1 })
+	if err := a.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if a.Stats().LocalEntries != 0 {
+		t.Fatal("Delete should drop the local copy")
+	}
+	waitFor(t, "b to drop its local copy after Delete", func() bool { return b.Stats().LocalEntries == 0 })
+
+	Set(ctx, a, "k", "tagged", 0, "t")
+	if v, err := Get[string](ctx, b, "k"); err != nil || v != "tagged" {
+		t.Fatalf("b Get = %q, %v", v, err)
+	}
+	if err := a.InvalidateTags(ctx, "t"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "b to drop its local copy after InvalidateTags", func() bool { return b.Stats().LocalEntries == 0 })
+	if _, err := Get[string](ctx, b, "k"); err != ErrMiss {
+		t.Fatalf("b Get after InvalidateTags = %v, want ErrMiss", err)
+	}
+
+	// 订阅连接断开期间可能丢失广播，重连后清空本地缓存
+	Set(ctx, b, "k", "v", 0)
+	if b.Stats().LocalEntries != 1 {
+		t.Fatal("Set should write the local copy")
+	}
+	s.DropConns()
+	waitFor(t, "b to clear its local cache after reconnecting", func() bool { return b.Stats().LocalEntries == 0 })
+}
@@ -0,0 +1,47 @@
+package pcache
+
+import (
+	"errors"
+	"sync"
+)
+
+// group 合并同一key的并发回源请求，同一时刻只有一个请求真正执行，其余等待并共享结果
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+var errLoaderPanic = errors.New("pcache: 回源函数发生panic")
+
+type call struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+func (g *group) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*call{}
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+	// fn发生panic时等待者收到该错误，panic本身仍在当前调用方抛出
+	c.err = errLoaderPanic
+	c.val, c.err = fn()
+	return c.val, c.err
+}
@@ -0,0 +1,368 @@
+// 数据库连接初始化：DSN组装、连接池设置、表前缀、健康检查及读写分离
+// 配置了从库时，GetOne、GetList、GetListCursor、Raw 等读操作在健康的从库间轮询，
+// Create、Update、Delete、Exec 等写操作始终在主库执行，例如：
+//
+//	db, err := mysql.New(mysql.Config{
+//		Address:  "127.0.0.1:3306",
+//		Username: "root",
+//		Password: "123456",
+//		Database: "shop",
+//		Prefix:   "t_",
+//		Replicas: []string{"127.0.0.2:3306", "127.0.0.3:3306"},
+//	})
+//
+// 写入后需要立即读到最新数据时，使用 db.UsePrimary() 在主库上读取
+package mysql
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+type Config struct {
+	Address             string                          // 主库地址 ip:port
+	Username            string                          // 用户名
+	Password            string                          // 密码
+	Database            string                          // 数据库名
+	Charset             string                          // 字符集，默认utf8mb4
+	Params              map[string]string               // 其他DSN参数，如 {"tls": "true"}
+	DSN                 string                          // 直接指定主库DSN，设置后忽略以上连接参数
+	Replicas            []string                        // 只读从库地址 ip:port，用户名、密码、库名等与主库一致
+	ReplicaDSNs         []string                        // 直接指定只读从库DSN，与Replicas合并使用
+	Prefix              string                          // 表前缀，同时作用于模型表名及FilterParams.Table
+	SingularTable       bool                            // 模型表名是否使用单数形式
+	MaxOpenConns        int                             // 最大连接数(0表示使用默认值100，负数表示不限制)
+	MaxIdleConns        int                             // 最大空闲连接数(0表示使用默认值10)
+	ConnMaxLifetime     int                             // 连接最长存活时间，单位s(0表示使用默认值3600，负数表示不限制)
+	ConnMaxIdleTime     int                             // 连接最大空闲时间，单位s(0表示不限制)
+	ConnectTimeout      int                             // 建立连接超时时间，单位ms(0表示使用默认值5000)
+	ReadTimeout         int                             // 读取超时时间，单位ms(0表示不限制)
+	WriteTimeout        int                             // 写入超时时间，单位ms(0表示不限制)
+	HealthCheckInterval int                             // 从库健康检查间隔，单位s，不可用的从库暂停读取，恢复后重新加入(0表示使用默认值10，负数表示不检查)
+	LogLevel            string                          // gorm日志级别 silent | error | warn | info，默认warn
+	SlowThreshold       int                             // 慢查询日志阈值，单位ms(0表示使用默认值200)
+	TxRetries           int                             // 事务遇到死锁或锁等待超时时的自动重试次数(0表示不重试)
+	Policy              TablePolicy                     // 所有表默认的软删除方式及默认排序，设置后所有表的查询始终过滤已删除的数据
+	Policies            map[string]TablePolicy          // 按表名(不含前缀亦可)指定策略，覆盖Policy，指定的表查询时始终过滤已删除的数据
+	Dialector           func(dsn string) gorm.Dialector // 自定义数据库驱动，默认MySQL，测试时可替换为SQLite等
+}
+
+// replicaSet 只读从库，按健康状态轮询
+type replicaSet struct {
+	conns   []*gorm.DB
+	healthy []int32 // 1健康 0不可用
+	next    uint32
+	stop    chan struct{}
+	once    sync.Once
+}
+
+var (
+	defaultCharset             = "utf8mb4"
+	defaultMaxOpenConns        = 100
+	defaultMaxIdleConns        = 10
+	defaultConnMaxLifetime     = 3600
+	defaultConnectTimeout      = 5000
+	defaultHealthCheckInterval = 10
+	defaultSlowThreshold       = 200
+
+	ErrConfig = errors.New("mysql: 需要配置Address及Database，或者直接指定DSN")
+)
+
+// New 按配置连接主库及从库，设置连接池并检测连接可用性，任一连接失败时关闭已建立的连接并返回错误
+func New(conf Config) (*Db, error) {
+	dsn := conf.DSN
+	if dsn == "" {
+		var err error
+		if dsn, err = conf.dsn(conf.Address); err != nil {
+			return nil, err
+		}
+	}
+	gormConf := conf.gormConfig()
+
+	primary, err := conf.open(dsn, gormConf)
+	if err != nil {
+		return nil, err
+	}
+	db := &Db{
+		Prefix:    conf.Prefix,
+		Conn:      primary,
+		txRetries: conf.TxRetries,
+		policies: &policySet{
+			fallback: conf.Policy,
+			explicit: !reflect.DeepEqual(conf.Policy, TablePolicy{}),
+			tables:   make(map[string]TablePolicy, len(conf.Policies)),
+		},
+	}
+	for table, policy := range conf.Policies {
+		db.policies.tables[db.table(table)] = policy
+	}
+
+	replicaDSNs := make([]string, 0, len(conf.Replicas)+len(conf.ReplicaDSNs))
+	for _, addr := range conf.Replicas {
+		replicaDSN, err := conf.dsn(addr)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		replicaDSNs = append(replicaDSNs, replicaDSN)
+	}
+	replicaDSNs = append(replicaDSNs, conf.ReplicaDSNs...)
+	if len(replicaDSNs) > 0 {
+		set := &replicaSet{
+			stop: make(chan struct{}),
+		}
+		db.replicas = set
+		for _, replicaDSN := range replicaDSNs {
+			conn, err := conf.open(replicaDSN, gormConf)
+			if err != nil {
+				db.Close()
+				return nil, err
+			}
+			set.conns = append(set.conns, conn)
+			set.healthy = append(set.healthy, 1)
+		}
+
+		interval := conf.HealthCheckInterval
+		if interval == 0 {
+			interval = defaultHealthCheckInterval
+		}
+		if interval > 0 {
+			go set.watch(time.Duration(interval) * time.Second)
+		}
+	}
+	return db, nil
+}
+
+// 按连接参数组装指定地址的DSN
+func (conf Config) dsn(address string) (string, error) {
+	if address == "" || conf.Database == "" {
+		return "", ErrConfig
+	}
+	cfg := driver.NewConfig()
+	cfg.User = conf.Username
+	cfg.Passwd = conf.Password
+	cfg.Net = "tcp"
+	cfg.Addr = address
+	cfg.DBName = conf.Database
+	cfg.ParseTime = true
+	cfg.Loc = time.Local
+	cfg.Params = map[string]string{
+		"charset": defaultCharset,
+	}
+	if conf.Charset != "" {
+		cfg.Params["charset"] = conf.Charset
+	}
+	for k, v := range conf.Params {
+		cfg.Params[k] = v
+	}
+	cfg.Timeout = time.Duration(defaultConnectTimeout) * time.Millisecond
+	if conf.ConnectTimeout > 0 {
+		cfg.Timeout = time.Duration(conf.ConnectTimeout) * time.Millisecond
+	}
+	if conf.ReadTimeout > 0 {
+		cfg.ReadTimeout = time.Duration(conf.ReadTimeout) * time.Millisecond
+	}
+	if conf.WriteTimeout > 0 {
+		cfg.WriteTimeout = time.Duration(conf.WriteTimeout) * time.Millisecond
+	}
+	return cfg.FormatDSN(), nil
+}
+
+func (conf Config) gormConfig() *gorm.Config {
+	level := logger.Warn
+	switch strings.ToLower(conf.LogLevel) {
+	case "silent":
+		level = logger.Silent
+	case "error":
+		level = logger.Error
+	case "info":
+		level = logger.Info
+	}
+	slow := defaultSlowThreshold
+	if conf.SlowThreshold > 0 {
+		slow = conf.SlowThreshold
+	}
+	return &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{
+			TablePrefix:   conf.Prefix,
+			SingularTable: conf.SingularTable,
+		},
+		Logger: logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+			SlowThreshold:             time.Duration(slow) * time.Millisecond,
+			LogLevel:                  level,
+			IgnoreRecordNotFoundError: true,
+			Colorful:                  false,
+		}),
+	}
+}
+
+// 建立连接、设置连接池并检测连接可用性
+func (conf Config) open(dsn string, gormConf *gorm.Config) (*gorm.DB, error) {
+	dialector := gormmysql.Open
+	if conf.Dialector != nil {
+		dialector = conf.Dialector
+	}
+	conn, err := gorm.Open(dialector(dsn), gormConf)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	maxOpen := conf.MaxOpenConns
+	if maxOpen == 0 {
+		maxOpen = defaultMaxOpenConns
+	}
+	if maxOpen > 0 {
+		sqlDB.SetMaxOpenConns(maxOpen)
+	}
+	maxIdle := conf.MaxIdleConns
+	if maxIdle == 0 {
+		maxIdle = defaultMaxIdleConns
+	}
+	sqlDB.SetMaxIdleConns(maxIdle)
+	lifetime := conf.ConnMaxLifetime
+	if lifetime == 0 {
+		lifetime = defaultConnMaxLifetime
+	}
+	if lifetime > 0 {
+		sqlDB.SetConnMaxLifetime(time.Duration(lifetime) * time.Second)
+	}
+	if conf.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(time.Duration(conf.ConnMaxIdleTime) * time.Second)
+	}
+
+	timeout := time.Duration(defaultConnectTimeout) * time.Millisecond
+	if conf.ConnectTimeout > 0 {
+		timeout = time.Duration(conf.ConnectTimeout) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Ping 健康检查，依次检测主库及所有从库的连接可用性，返回第一个错误
+func (db *Db) Ping(ctx context.Context) error {
+	conns := []*gorm.DB{db.Conn}
+	if db.replicas != nil {
+		conns = append(conns, db.replicas.conns...)
+	}
+	for _, conn := range conns {
+		sqlDB, err := conn.DB()
+		if err != nil {
+			return err
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UsePrimary 返回读操作也在主库执行的对象，用于写入后需要立即读取最新数据的场景
+func (db *Db) UsePrimary() *Db {
+	view := *db
+	view.replicas = nil
+	return &view
+}
+
+// Close 关闭主库及从库的连接池，停止健康检查
+func (db *Db) Close() error {
+	var first error
+	conns := []*gorm.DB{db.Conn}
+	if db.replicas != nil {
+		db.replicas.once.Do(func() {
+			close(db.replicas.stop)
+		})
+		conns = append(conns, db.replicas.conns...)
+	}
+	for _, conn := range conns {
+		if conn == nil {
+			continue
+		}
+		sqlDB, err := conn.DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		if first == nil && err != nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// 读操作使用的连接：轮询健康的从库，没有从库或从库均不可用时使用主库
+func (db *Db) reader() *gorm.DB {
+	if db.replicas == nil {
+		return db.Conn
+	}
+	if conn := db.replicas.pick(); conn != nil {
+		return conn
+	}
+	return db.Conn
+}
+
+// 表名加上前缀，已带前缀的表名不重复添加
+func (db *Db) table(name string) string {
+	if db.Prefix == "" || name == "" || strings.HasPrefix(name, db.Prefix) {
+		return name
+	}
+	return db.Prefix + name
+}
+
+func (s *replicaSet) pick() *gorm.DB {
+	n := len(s.conns)
+	start := atomic.AddUint32(&s.next, 1)
+	for i := 0; i < n; i++ {
+		idx := int((start + uint32(i)) % uint32(n))
+		if atomic.LoadInt32(&s.healthy[idx]) == 1 {
+			return s.conns[idx]
+		}
+	}
+	return nil
+}
+
+// 定时检测从库，不可用的从库暂停读取
+func (s *replicaSet) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		for i, conn := range s.conns {
+			healthy := int32(0)
+			if sqlDB, err := conn.DB(); err == nil {
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				if sqlDB.PingContext(ctx) == nil {
+					healthy = 1
+				}
+				cancel()
+			}
+			if atomic.SwapInt32(&s.healthy[i], healthy) != healthy && healthy == 0 {
+				log.Printf("mysql: 从库[%d]健康检查失败，暂停读取", i)
+			}
+		}
+	}
+}
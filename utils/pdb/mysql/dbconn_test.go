@@ -0,0 +1,169 @@
+package mysql
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type User struct {
+	ID        int64  `gorm:"column:id;primaryKey"`
+	Name      string `gorm:"column:name"`
+	DelStatus int    `gorm:"column:delStatus;default:1"`
+	CreatedAt int64  `gorm:"column:createdAt"`
+}
+
+// 创建SQLite数据库文件，写入一条name为指定值的数据
+func seedSQLite(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name+".db")
+	conn, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := conn.DB()
+	defer sqlDB.Close()
+	for _, stmt := range []string{
+		"CREATE TABLE t_users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, delStatus INTEGER DEFAULT 1, createdAt INTEGER DEFAULT 0)",
+		"INSERT INTO t_users (name) VALUES ('" + name + "')",
+	} {
+		if err := conn.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+// 直接读取数据库文件中的name列表
+func namesIn(t *testing.T, path string) []string {
+	t.Helper()
+	conn, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := conn.DB()
+	defer sqlDB.Close()
+	var names []string
+	if err := conn.Raw("SELECT name FROM t_users ORDER BY id").Scan(&names).Error; err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func newSQLite(t *testing.T) (*Db, string, []string) {
+	t.Helper()
+	primary := seedSQLite(t, "primary")
+	replicas := []string{seedSQLite(t, "replica"), seedSQLite(t, "replica")}
+	db, err := New(Config{
+		DSN:                 primary,
+		ReplicaDSNs:         replicas,
+		Prefix:              "t_",
+		LogLevel:            "silent",
+		HealthCheckInterval: -1,
+		Dialector:           sqlite.Open,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, primary, replicas
+}
+
+func TestReadsUseReplicas(t *testing.T) {
+	db, _, _ := newSQLite(t)
+
+	for i := 0; i < 4; i++ {
+		row, err := db.GetOne(FilterParams{Table: "users"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row["name"] != "replica" {
+			t.Fatalf("GetOne read %v, want the replica row", row["name"])
+		}
+	}
+
+	rows, count, err := db.GetList(FilterParams{Table: "users", Limit: [2]int{10, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || len(rows) != 1 || rows[0]["name"] != "replica" {
+		t.Fatalf("GetList = %v, %d", rows, count)
+	}
+
+	var names []string
+	if _, err := db.Raw(&names, "SELECT name FROM t_users"); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "replica" {
+		t.Fatalf("Raw = %v", names)
+	}
+
+	// UsePrimary 在主库上读取
+	row, err := db.UsePrimary().GetOne(FilterParams{Table: "users"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row["name"] != "primary" {
+		t.Fatalf("UsePrimary().GetOne read %v, want the primary row", row["name"])
+	}
+}
+
+func TestWritesUsePrimary(t *testing.T) {
+	db, primary, replicas := newSQLite(t)
+
+	// 模型表名及FilterParams.Table都加上前缀
+	if _, err := db.Create(&User{Name: "created"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Update(FilterParams{Table: "users", Where: QueryArgs{Query: "name = ?", Args: []interface{}{"primary"}}},
+		map[string]interface{}{"name": "updated"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO t_users (name) VALUES (?)", "executed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Delete(&User{}, FilterParams{Where: QueryArgs{Query: "name = ?", Args: []interface{}{"created"}}}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	got := namesIn(t, primary)
+	want := []string{"updated", "executed"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("primary rows = %v, want %v", got, want)
+	}
+	for _, replica := range replicas {
+		if got := namesIn(t, replica); len(got) != 1 || got[0] != "replica" {
+			t.Fatalf("replica rows = %v, want them untouched", got)
+		}
+	}
+}
+
+func TestNewClosesConnectionsOnFailure(t *testing.T) {
+	primary := seedSQLite(t, "primary")
+	primaryDB, err := sql.Open("sqlite3", primary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primaryDB.Close()
+
+	_, err = New(Config{
+		DSN:         primary,
+		ReplicaDSNs: []string{filepath.Join(t.TempDir(), "missing", "replica.db")},
+		LogLevel:    "silent",
+		Dialector: func(dsn string) gorm.Dialector {
+			if dsn == primary {
+				return &sqlite.Dialector{DSN: dsn, Conn: primaryDB}
+			}
+			return sqlite.Open(dsn)
+		},
+	})
+	if err == nil {
+		t.Fatal("New should fail when a replica cannot be opened")
+	}
+	if err := primaryDB.Ping(); err == nil {
+		t.Fatal("the primary connection should be closed after New fails")
+	}
+}
@@ -0,0 +1,439 @@
+// 结构体标签驱动的查询条件绑定
+// 将gin绑定好的查询参数结构体转换为 FilterParams，字段名只来自标签及白名单，用户输入只作为参数值，不会以原生SQL进入Where或Order，例如：
+//
+//	type UserQuery struct {
+//		Name      string  `form:"name" filter:"name,op=like"`
+//		Status    *int    `form:"status" filter:"status"`
+//		Ids       []int64 `form:"ids" filter:"id,op=in"`
+//		CreatedAt []int64 `form:"createdAt" filter:"createdAt,op=between"`
+//		Sort      string  `form:"sort" filter:",sort=createdAt|id"`
+//		Page      int     `form:"page" filter:",page"`
+//		PageSize  int     `form:"pageSize" filter:",size"`
+//	}
+//
+//	var q UserQuery
+//	if err := c.ShouldBindQuery(&q); err != nil { ... }
+//	params, err := mysql.BindFilter(mysql.FilterParams{Table: "users"}, q)
+//
+// 过滤标签格式 filter:"字段名,op=操作符"，字段名可带表别名如 u.name，op默认eq，可选：
+//
+//	eq | ne | gt | gte | lt | lte        比较
+//	like | prefix | suffix               模糊匹配(包含、前缀、后缀)，参数中的 % _ 会被转义
+//	in | notin                           切片，或逗号分隔的字符串
+//	between                              两个元素的切片，或逗号分隔的字符串
+//	null                                 bool，true为 IS NULL，false为 IS NOT NULL
+//
+// 值为零值(空字符串、0、nil、空切片)的字段不参与过滤，需要按零值过滤时使用指针类型
+// 排序字段 filter:",sort=a|b" 的值格式为 "createdAt desc,id asc" 或 "-createdAt,id"，只允许标签及 FilterOptions.Sortable 中的字段
+// 分页字段 filter:",page"、filter:",size" 转换为 Limit，页码从1开始
+//
+// 注意：生成的条件放在Where中，表未配置策略(Config.Policy、Config.Policies、SetPolicy)时，GetList 等查询传了Where不会过滤已删除的数据，
+// 如 GET /users?name=x 会查出已删除的用户。使用 BindFilter 的表请配置策略，或在传入的 params.Where 中加上删除状态条件
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// FilterOptions 查询条件绑定选项
+type FilterOptions struct {
+	Filterable  []string // 允许过滤的字段，为空时不限制(字段名只来自结构体标签)
+	Sortable    []string // 允许排序的字段，与排序字段标签中的 sort=a|b 合并
+	DefaultSize int      // 未传每页条数时的默认值
+	MaxSize     int      // 每页条数的最大值，超出时按最大值查询
+}
+
+var (
+	defaultFilterOptions = FilterOptions{
+		DefaultSize: 20,
+		MaxSize:     100,
+	}
+
+	// 允许的字段名格式：字段名 或 表名.字段名
+	filterColumnRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+	// 操作符对应的SQL
+	filterOps = map[string]string{
+		"eq":      "= ?",
+		"ne":      "<> ?",
+		"gt":      "> ?",
+		"gte":     ">= ?",
+		"lt":      "< ?",
+		"lte":     "<= ?",
+		"like":    `LIKE ? ESCAPE '\\'`,
+		"prefix":  `LIKE ? ESCAPE '\\'`,
+		"suffix":  `LIKE ? ESCAPE '\\'`,
+		"in":      "IN ?",
+		"notin":   "NOT IN ?",
+		"between": "BETWEEN ? AND ?",
+		"null":    "IS NULL",
+	}
+
+	likeReplacer = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+	ErrFilterTag    = errors.New("mysql: filter标签格式错误")
+	ErrFilterColumn = errors.New("mysql: 字段不允许过滤")
+	ErrFilterValue  = errors.New("mysql: 过滤参数格式错误")
+	ErrSortColumn   = errors.New("mysql: 字段不允许排序")
+	ErrFilterWhere  = errors.New("mysql: 只能在字符串形式的Where条件上追加过滤条件")
+)
+
+// filterBinder 绑定过程中的状态
+type filterBinder struct {
+	opts       FilterOptions
+	filterable map[string]struct{}
+	sortable   map[string]struct{}
+	conds      []string
+	args       []interface{}
+	order      []interface{}
+	page       int
+	size       int
+	paged      bool
+}
+
+// BindFilter 按结构体的filter标签生成查询条件
+// params: FilterParams 基础查询条件(表名、字段、Join等)，其中字符串形式的Where与生成的条件以AND合并
+// query: interface{} 绑定了请求参数的结构体或结构体指针
+// opts: FilterOptions 白名单及分页选项
+// 表未配置策略时，生成的Where会使查询包含已删除的数据，见 FilterWhere
+func BindFilter(params FilterParams, query interface{}, opts ...FilterOptions) (FilterParams, error) {
+	b := &filterBinder{
+		opts: defaultFilterOptions,
+	}
+	if len(opts) > 0 {
+		b.opts = opts[0]
+		if b.opts.DefaultSize <= 0 {
+			b.opts.DefaultSize = defaultFilterOptions.DefaultSize
+		}
+		if b.opts.MaxSize <= 0 {
+			b.opts.MaxSize = defaultFilterOptions.MaxSize
+		}
+	}
+	if len(b.opts.Filterable) > 0 {
+		b.filterable = make(map[string]struct{}, len(b.opts.Filterable))
+		for _, column := range b.opts.Filterable {
+			b.filterable[column] = struct{}{}
+		}
+	}
+	b.sortable = make(map[string]struct{}, len(b.opts.Sortable))
+	for _, column := range b.opts.Sortable {
+		b.sortable[column] = struct{}{}
+	}
+
+	v := reflect.ValueOf(query)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return params, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return params, ErrFilterValue
+	}
+	if err := b.bind(v); err != nil {
+		return params, err
+	}
+
+	if len(b.conds) > 0 {
+		where := strings.Join(b.conds, " AND ")
+		if !reflect.DeepEqual(params.Where, QueryArgs{}) {
+			base, ok := params.Where.Query.(string)
+			if !ok {
+				return params, ErrFilterWhere
+			}
+			if base != "" {
+				where = "(" + base + ") AND " + where
+			}
+		}
+		params.Where = QueryArgs{
+			Query: where,
+			Args:  append(append([]interface{}{}, params.Where.Args...), b.args...),
+		}
+	}
+	if len(b.order) > 0 {
+		params.Order = append(append([]interface{}{}, params.Order...), b.order...)
+	}
+	if b.paged {
+		size := b.size
+		if size <= 0 {
+			size = b.opts.DefaultSize
+		}
+		if size > b.opts.MaxSize {
+			size = b.opts.MaxSize
+		}
+		page := b.page
+		if page < 1 {
+			page = 1
+		}
+		// 页码过大时限制在偏移量不溢出的范围内
+		if maxPage := math.MaxInt/size + 1; page > maxPage {
+			page = maxPage
+		}
+		params.Limit = [2]int{size, (page - 1) * size}
+	}
+	return params, nil
+}
+
+func (b *filterBinder) bind(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		tag, ok := field.Tag.Lookup("filter")
+		if !ok {
+			// 未设置标签的嵌入结构体，继续绑定其中的字段
+			if field.Anonymous {
+				for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+					fv = fv.Elem()
+				}
+				if fv.Kind() == reflect.Struct {
+					if err := b.bind(fv); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+		if tag == "-" {
+			continue
+		}
+
+		column, options, err := parseFilterTag(tag)
+		if err != nil {
+			return fmt.Errorf("%w: %s.%s", err, t.Name(), field.Name)
+		}
+		if column == "" {
+			// 排序及分页字段，声明了分页字段时即使未传值也按默认条数分页
+			switch {
+			case hasOption(options, "page"):
+				b.paged = true
+				b.page = intValue(fv)
+			case hasOption(options, "size"):
+				b.paged = true
+				b.size = intValue(fv)
+			case hasOption(options, "sort"):
+				if !isEmptyFilterValue(fv) {
+					if err := b.sort(options["sort"], indirect(fv)); err != nil {
+						return err
+					}
+				}
+			default:
+				return fmt.Errorf("%w: %s.%s", ErrFilterTag, t.Name(), field.Name)
+			}
+			continue
+		}
+
+		if isEmptyFilterValue(fv) {
+			continue
+		}
+		if err := b.filter(column, options["op"], indirect(fv)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 生成单个字段的过滤条件
+func (b *filterBinder) filter(column, op string, fv reflect.Value) error {
+	if b.filterable != nil {
+		if _, ok := b.filterable[column]; !ok {
+			return fmt.Errorf("%w: %s", ErrFilterColumn, column)
+		}
+	}
+	if op == "" {
+		op = "eq"
+	}
+	sql, ok := filterOps[op]
+	if !ok {
+		return fmt.Errorf("%w: op=%s", ErrFilterTag, op)
+	}
+	quoted := quoteColumn(column)
+
+	switch op {
+	case "like", "prefix", "suffix":
+		s := likeReplacer.Replace(fmt.Sprint(fv.Interface()))
+		switch op {
+		case "like":
+			s = "%" + s + "%"
+		case "prefix":
+			s = s + "%"
+		case "suffix":
+			s = "%" + s
+		}
+		b.conds = append(b.conds, quoted+" "+sql)
+		b.args = append(b.args, s)
+	case "in", "notin":
+		values := listValue(fv)
+		if len(values) == 0 {
+			return nil
+		}
+		b.conds = append(b.conds, quoted+" "+sql)
+		b.args = append(b.args, values)
+	case "between":
+		values := listValue(fv)
+		if len(values) != 2 {
+			return fmt.Errorf("%w: %s", ErrFilterValue, column)
+		}
+		b.conds = append(b.conds, quoted+" "+sql)
+		b.args = append(b.args, values...)
+	case "null":
+		if fv.Kind() != reflect.Bool {
+			return fmt.Errorf("%w: %s", ErrFilterValue, column)
+		}
+		if fv.Bool() {
+			b.conds = append(b.conds, quoted+" IS NULL")
+		} else {
+			b.conds = append(b.conds, quoted+" IS NOT NULL")
+		}
+	default:
+		b.conds = append(b.conds, quoted+" "+sql)
+		b.args = append(b.args, fv.Interface())
+	}
+	return nil
+}
+
+// 解析排序参数，只允许白名单中的字段
+func (b *filterBinder) sort(allowed string, fv reflect.Value) error {
+	sortable := b.sortable
+	if allowed != "" {
+		sortable = make(map[string]struct{}, len(b.sortable))
+		for column := range b.sortable {
+			sortable[column] = struct{}{}
+		}
+		for _, column := range strings.Split(allowed, "|") {
+			sortable[strings.TrimSpace(column)] = struct{}{}
+		}
+	}
+
+	var items []string
+	for _, value := range listValue(fv) {
+		items = append(items, fmt.Sprint(value))
+	}
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		desc := false
+		column := item
+		if strings.HasPrefix(item, "-") {
+			desc = true
+			column = item[1:]
+		} else if fields := strings.Fields(item); len(fields) == 2 {
+			column = fields[0]
+			switch strings.ToLower(fields[1]) {
+			case "asc":
+			case "desc":
+				desc = true
+			default:
+				return fmt.Errorf("%w: %s", ErrSortColumn, item)
+			}
+		}
+		if _, ok := sortable[column]; !ok || !filterColumnRegexp.MatchString(column) {
+			return fmt.Errorf("%w: %s", ErrSortColumn, column)
+		}
+		b.order = append(b.order, clause.OrderByColumn{Column: columnOf(column), Desc: desc})
+	}
+	return nil
+}
+
+// 解析filter标签，返回字段名及选项
+func parseFilterTag(tag string) (column string, options map[string]string, err error) {
+	parts := strings.Split(tag, ",")
+	column = strings.TrimSpace(parts[0])
+	if column != "" && !filterColumnRegexp.MatchString(column) {
+		return "", nil, ErrFilterTag
+	}
+	options = make(map[string]string, len(parts)-1)
+	for _, part := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		options[key] = value
+	}
+	return column, options, nil
+}
+
+func hasOption(options map[string]string, key string) bool {
+	_, ok := options[key]
+	return ok
+}
+
+// 字段名加反引号，表名.字段名 分别处理
+func quoteColumn(column string) string {
+	if table, name, ok := strings.Cut(column, "."); ok {
+		return "`" + table + "`.`" + name + "`"
+	}
+	return "`" + column + "`"
+}
+
+func columnOf(column string) clause.Column {
+	if table, name, ok := strings.Cut(column, "."); ok {
+		return clause.Column{Table: table, Name: name}
+	}
+	return clause.Column{Name: column}
+}
+
+// 零值不参与过滤，非nil指针视为有值
+func isEmptyFilterValue(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return fv.IsNil()
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return fv.Len() == 0
+	default:
+		return fv.IsZero()
+	}
+}
+
+// 切片、数组转为参数列表，字符串按逗号分隔
+func listValue(fv reflect.Value) []interface{} {
+	switch fv.Kind() {
+	case reflect.Slice, reflect.Array:
+		values := make([]interface{}, 0, fv.Len())
+		for i := 0; i < fv.Len(); i++ {
+			values = append(values, fv.Index(i).Interface())
+		}
+		return values
+	case reflect.String:
+		var values []interface{}
+		for _, s := range strings.Split(fv.String(), ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return []interface{}{fv.Interface()}
+	}
+}
+
+// 取指针指向的值，nil指针返回无效的Value
+func indirect(fv reflect.Value) reflect.Value {
+	for fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return reflect.Value{}
+		}
+		fv = fv.Elem()
+	}
+	return fv
+}
+
+func intValue(fv reflect.Value) int {
+	fv = indirect(fv)
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(fv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(fv.Uint())
+	}
+	return 0
+}
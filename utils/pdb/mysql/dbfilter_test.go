@@ -0,0 +1,197 @@
+package mysql
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm/clause"
+)
+
+func TestBindFilterConditions(t *testing.T) {
+	type query struct {
+		Name    string  `filter:"name,op=like"`
+		Prefix  string  `filter:"u.code,op=prefix"`
+		Status  *int    `filter:"status"`
+		Ids     string  `filter:"id,op=in"`
+		Types   []int   `filter:"type,op=notin"`
+		Created string  `filter:"createdAt,op=between"`
+		Score   float64 `filter:"score,op=gte"`
+		Deleted *bool   `filter:"deletedAt,op=null"`
+		Ignored string
+		Skipped string `filter:"-"`
+	}
+	zero := 0
+	deleted := false
+	params, err := BindFilter(FilterParams{Table: "users"}, &query{
+		Name:    `50%_off\`,
+		Prefix:  "a_",
+		Status:  &zero,
+		Ids:     "1, 2,,3",
+		Types:   []int{7},
+		Created: "100,200",
+		Deleted: &deleted,
+		Ignored: "x",
+		Skipped: "y",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "`name` LIKE ? ESCAPE '\\\\' AND `u`.`code` LIKE ? ESCAPE '\\\\' AND `status` = ? AND `id` IN ? AND `type` NOT IN ? AND `createdAt` BETWEEN ? AND ? AND `deletedAt` IS NOT NULL"
+	if params.Where.Query != want {
+		t.Fatalf("Where = %v\nwant    %s", params.Where.Query, want)
+	}
+	// LIKE参数中的 \ % _ 被转义；指针的零值参与过滤，非指针的零值(Score)不参与
+	wantArgs := []interface{}{
+		`%50\%\_off\\%`,
+		`a\_%`,
+		0,
+		[]interface{}{"1", "2", "3"},
+		[]interface{}{7},
+		"100", "200",
+	}
+	if !reflect.DeepEqual(params.Where.Args, wantArgs) {
+		t.Fatalf("Args = %#v\nwant   %#v", params.Where.Args, wantArgs)
+	}
+	if params.Table != "users" || params.Limit != [2]int{} {
+		t.Fatalf("params = %+v", params)
+	}
+
+	if _, err := BindFilter(FilterParams{}, struct {
+		Range string `filter:"createdAt,op=between"`
+	}{"1,2,3"}); !errors.Is(err, ErrFilterValue) {
+		t.Fatalf("between with 3 values = %v, want ErrFilterValue", err)
+	}
+	if _, err := BindFilter(FilterParams{}, struct {
+		Name string `filter:"name; DROP TABLE users"`
+	}{"x"}); !errors.Is(err, ErrFilterTag) {
+		t.Fatalf("invalid column tag = %v, want ErrFilterTag", err)
+	}
+}
+
+func TestBindFilterFilterable(t *testing.T) {
+	type query struct {
+		Name   string `filter:"name"`
+		Secret string `filter:"password"`
+	}
+	opts := FilterOptions{Filterable: []string{"name"}}
+	if _, err := BindFilter(FilterParams{}, query{Name: "a"}, opts); err != nil {
+		t.Fatal(err)
+	}
+	// 未传值的字段不检查
+	if _, err := BindFilter(FilterParams{}, query{Name: "a", Secret: "x"}, opts); !errors.Is(err, ErrFilterColumn) {
+		t.Fatalf("non-filterable column = %v, want ErrFilterColumn", err)
+	}
+}
+
+func TestBindFilterMergesWhere(t *testing.T) {
+	type query struct {
+		Name string `filter:"name"`
+	}
+	base := FilterParams{Where: where("tenant = ? OR tenant = ?", 1, 2)}
+	params, err := BindFilter(base, query{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Where.Query != "(tenant = ? OR tenant = ?) AND `name` = ?" {
+		t.Fatalf("Where = %v", params.Where.Query)
+	}
+	if !reflect.DeepEqual(params.Where.Args, []interface{}{1, 2, "a"}) {
+		t.Fatalf("Args = %v", params.Where.Args)
+	}
+	if len(base.Where.Args) != 2 {
+		t.Fatal("the base params must not be modified")
+	}
+
+	// 未生成条件时保留原Where
+	params, err = BindFilter(base, query{})
+	if err != nil || !reflect.DeepEqual(params.Where, base.Where) {
+		t.Fatalf("Where = %+v, %v", params.Where, err)
+	}
+
+	if _, err := BindFilter(FilterParams{Where: QueryArgs{Query: map[string]interface{}{"tenant": 1}}}, query{Name: "a"}); err != ErrFilterWhere {
+		t.Fatalf("map Where = %v, want ErrFilterWhere", err)
+	}
+}
+
+func TestBindFilterSort(t *testing.T) {
+	type query struct {
+		Sort string `filter:",sort=createdAt|u.id"`
+	}
+	params, err := BindFilter(FilterParams{Order: []interface{}{"top desc"}}, query{Sort: "-createdAt, u.id asc,name DESC"},
+		FilterOptions{Sortable: []string{"name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		"top desc",
+		clause.OrderByColumn{Column: clause.Column{Name: "createdAt"}, Desc: true},
+		clause.OrderByColumn{Column: clause.Column{Table: "u", Name: "id"}},
+		clause.OrderByColumn{Column: clause.Column{Name: "name"}, Desc: true},
+	}
+	if !reflect.DeepEqual(params.Order, want) {
+		t.Fatalf("Order = %#v", params.Order)
+	}
+
+	for _, sort := range []string{"password", "-password", "createdAt sideways", "createdAt desc, (select 1)", "createdAt;drop"} {
+		if _, err := BindFilter(FilterParams{}, query{Sort: sort}); !errors.Is(err, ErrSortColumn) {
+			t.Errorf("sort %q = %v, want ErrSortColumn", sort, err)
+		}
+	}
+}
+
+func TestBindFilterPaging(t *testing.T) {
+	type query struct {
+		Page int  `filter:",page"`
+		Size uint `filter:",size"`
+	}
+	cases := []struct {
+		in   query
+		want [2]int
+	}{
+		{query{}, [2]int{20, 0}},
+		{query{Page: 3, Size: 10}, [2]int{10, 20}},
+		{query{Page: -1, Size: 1000}, [2]int{100, 0}},
+		// 页码过大时偏移量不溢出
+		{query{Page: math.MaxInt, Size: 100}, [2]int{100, math.MaxInt / 100 * 100}},
+	}
+	for _, c := range cases {
+		params, err := BindFilter(FilterParams{}, c.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if params.Limit != c.want || params.Limit[1] < 0 {
+			t.Errorf("%+v: Limit = %v, want %v", c.in, params.Limit, c.want)
+		}
+	}
+
+	params, err := BindFilter(FilterParams{}, query{Page: 2}, FilterOptions{DefaultSize: 5, MaxSize: 50})
+	if err != nil || params.Limit != [2]int{5, 5} {
+		t.Fatalf("DefaultSize: Limit = %v, %v", params.Limit, err)
+	}
+}
+
+func TestBindFilterQuery(t *testing.T) {
+	db := newPolicyDb(t, Config{})
+	if _, err := db.Exec("INSERT INTO t_users (name) VALUES ('bob'), ('bobby')"); err != nil {
+		t.Fatal(err)
+	}
+	type query struct {
+		Names string `filter:"name,op=in"`
+		Sort  string `filter:",sort=id"`
+		Page  int    `filter:",page"`
+		Size  int    `filter:",size"`
+	}
+	params, err := BindFilter(FilterParams{Table: "users"}, query{Names: "alive,bob,bobby", Sort: "-id", Page: 1, Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, count, err := db.GetList(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || len(rows) != 2 || rows[0]["name"] != "bobby" || rows[1]["name"] != "bob" {
+		t.Fatalf("GetList = %v, count %d", rows, count)
+	}
+}
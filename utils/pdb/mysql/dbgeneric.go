@@ -0,0 +1,104 @@
+// 泛型查询方法，查询结果直接扫描到结构体，保留字段类型，无需再经过 funcs/convert 转换
+// 查询条件与 GetOne、GetList 一致，T可以是数据表模型，也可以是只包含部分字段的DTO结构体，例如：
+//
+//	type UserItem struct {
+//		Id   int64
+//		Name string `gorm:"column:nickName"`
+//	}
+//
+//	list, total, err := mysql.List[UserItem](db, mysql.FilterParams{Table: "users", Limit: [2]int{20, 0}})
+//
+// params.Fields 为空且没有Join时，只查询T中定义的字段；params.Table 为空时使用T的表名(模型名或TableName()方法)
+package mysql
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// Find 查询所有匹配的数据，params.Limit[0]大于0时按Limit分页
+// db: *Db 数据库对象，事务中传入事务回调的tx
+// params: FilterParams 查询条件
+func Find[T any](db *Db, params FilterParams) (res []T, err error) {
+	conn, err := filterOf[T](db, params)
+	if err != nil {
+		return nil, err
+	}
+	if params.Limit[0] > 0 {
+		conn.Limit(params.Limit[0])
+		conn.Offset(params.Limit[1])
+	}
+
+	res = make([]T, 0)
+	err = conn.Find(&res).Error
+	return res, err
+}
+
+// First 查询第一条匹配的数据，没有数据时返回 gorm.ErrRecordNotFound
+// db: *Db 数据库对象，事务中传入事务回调的tx
+// params: FilterParams 查询条件，排序规则与GetOne一致
+func First[T any](db *Db, params FilterParams) (res T, err error) {
+	conn, err := filterOf[T](db, params)
+	if err != nil {
+		return res, err
+	}
+	conn.Limit(1)
+
+	result := conn.Find(&res)
+	if result.Error != nil {
+		return res, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return res, gorm.ErrRecordNotFound
+	}
+	return res, nil
+}
+
+// List 查询数据列表，params.Limit[0]大于0时按Limit分页
+// db: *Db 数据库对象，事务中传入事务回调的tx
+// params: FilterParams 查询条件
+// return:
+//
+//	res: []T 查询结果
+//	count: int64 匹配查询条件的总记录数
+func List[T any](db *Db, params FilterParams) (res []T, count int64, err error) {
+	conn, err := filterOf[T](db, params)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	//统计满足匹配条件的数据总数
+	if err = conn.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if params.Limit[0] > 0 {
+		conn.Limit(params.Limit[0])
+		conn.Offset(params.Limit[1])
+	}
+
+	res = make([]T, 0)
+	err = conn.Find(&res).Error
+	return res, count, err
+}
+
+// 按T补全表名及查询字段后处理查询条件
+func filterOf[T any](db *Db, params FilterParams) (*gorm.DB, error) {
+	var model T
+	if reflect.Indirect(reflect.ValueOf(&model)).Kind() == reflect.Struct {
+		stmt := &gorm.Statement{DB: db.Conn}
+		if err := stmt.Parse(&model); err != nil {
+			return nil, err
+		}
+		if params.Table == "" {
+			params.Table = stmt.Schema.Table
+		}
+		if len(params.Fields) == 0 && len(params.Join) == 0 && len(stmt.Schema.DBNames) > 0 {
+			params.Fields = stmt.Schema.DBNames
+		}
+	}
+
+	conn, _ := db.FilterWhere(params)
+	return conn, nil
+}
@@ -0,0 +1,99 @@
+package mysql
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// 只包含部分字段、字段名与模型不同的DTO
+type userName struct {
+	Nick string `gorm:"column:name"`
+}
+
+// 通过TableName指定表名的DTO
+type namedUser struct {
+	ID   int64
+	Name string
+}
+
+func (namedUser) TableName() string { return "users" }
+
+func TestGenericFind(t *testing.T) {
+	db := newPolicyDb(t, Config{})
+	if _, err := db.Exec("INSERT INTO t_users (name) VALUES ('bob')"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未指定表名时使用模型的表名，未传Where时过滤已删除的数据
+	users, err := Find[User](db, FilterParams{Order: []interface{}{"id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].Name != "alive" || users[1].Name != "bob" || users[1].ID == 0 || users[1].DelStatus != 1 {
+		t.Fatalf("Find[User] = %+v", users)
+	}
+
+	// Limit分页
+	users, err = Find[User](db, FilterParams{Order: []interface{}{"id"}, Limit: [2]int{1, 1}})
+	if err != nil || len(users) != 1 || users[0].Name != "bob" {
+		t.Fatalf("Find[User] with Limit = %+v, %v", users, err)
+	}
+
+	// DTO只查询其中定义的字段
+	names, err := Find[userName](db, FilterParams{Table: "users", Order: []interface{}{"id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0].Nick != "alive" || names[1].Nick != "bob" {
+		t.Fatalf("Find[userName] = %+v", names)
+	}
+
+	named, err := Find[namedUser](db.WithTrashed(), FilterParams{Order: []interface{}{"id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(named) != 3 || named[1].Name != "gone" {
+		t.Fatalf("Find[namedUser] = %+v", named)
+	}
+}
+
+func TestGenericFirst(t *testing.T) {
+	db := newPolicyDb(t, Config{})
+
+	user, err := First[User](db, FilterParams{Where: where("name = ?", "gone")})
+	if err != nil || user.Name != "gone" || user.DelStatus != 2 {
+		t.Fatalf("First[User] = %+v, %v", user, err)
+	}
+	name, err := First[userName](db, FilterParams{Table: "users"})
+	if err != nil || name.Nick != "alive" {
+		t.Fatalf("First[userName] = %+v, %v", name, err)
+	}
+	if _, err := First[User](db, FilterParams{Where: where("name = ?", "nobody")}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("First without rows = %v, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+func TestGenericList(t *testing.T) {
+	db := newPolicyDb(t, Config{})
+	for _, name := range []string{"b", "c"} {
+		if _, err := db.Exec("INSERT INTO t_users (name) VALUES (?)", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 总数不受分页影响
+	list, count, err := List[userName](db, FilterParams{Table: "users", Order: []interface{}{"id desc"}, Limit: [2]int{2, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || len(list) != 2 || list[0].Nick != "c" || list[1].Nick != "b" {
+		t.Fatalf("List[userName] = %+v, count %d", list, count)
+	}
+
+	list, count, err = List[userName](db, FilterParams{Table: "users", Where: where("name = ?", "nobody")})
+	if err != nil || count != 0 || list == nil || len(list) != 0 {
+		t.Fatalf("List without rows = %v, %d, %v, want an empty list", list, count, err)
+	}
+}
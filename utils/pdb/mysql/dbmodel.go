@@ -6,15 +6,18 @@ import (
 	"log"
 	"reflect"
 
-	"github.com/perpower/goframe/funcs/ptime"
-
 	"gorm.io/gorm"
 )
 
 type Db struct {
-	Prefix string // 数据库表前缀
-	Conn   *gorm.DB
-	Error  error
+	Prefix    string   // 数据库表前缀
+	Conn      *gorm.DB // 主库连接
+	Error     error
+	replicas  *replicaSet // 只读从库，通过New创建时配置
+	txRetries int         // 事务遇到死锁或锁等待超时时的重试次数
+	tx        *txState    // 事务状态，非事务对象为nil
+	policies  *policySet  // 数据表策略，未配置时使用默认策略
+	trashed   int         // 已删除数据的查询方式，通过WithTrashed、OnlyTrashed设置
 }
 
 // func(query, args...) 类方法传参结构体
@@ -105,7 +108,7 @@ func (db *Db) CreateInBatch(datas interface{}, batchSize int) (num int64, err er
 	return num, err
 }
 
-// 查询单条数据
+// 查询单条数据，已删除数据的过滤方式见 FilterWhere
 // params: FilterParams 查询条件
 // res: map[string]interface{} 查询结果
 func (db *Db) GetOne(params FilterParams) (res map[string]interface{}, err error) {
@@ -117,7 +120,7 @@ func (db *Db) GetOne(params FilterParams) (res map[string]interface{}, err error
 	return res, err
 }
 
-// GetList 查询数据列表
+// GetList 查询数据列表，已删除数据的过滤方式见 FilterWhere
 // params: FilterParams 查询条件
 // return:
 //
@@ -171,9 +174,12 @@ func (db *Db) GetListCursor(params FilterParams, limitSize int, cursorWhere ...Q
 }
 
 // FilterWhere 统一处理查询条件
+// 注意：未为表配置策略(Config.Policy、Config.Policies、SetPolicy)时沿用原有约定，只在Where为空时过滤已删除的数据，
+// 传了Where(包括 BindFilter 生成的条件)就会查出已删除的数据，需由调用方在Where中自行加上删除状态条件；
+// 配置策略后无论是否传Where都会过滤，WithTrashed、OnlyTrashed 才能按预期切换
 // params: FilterParams  查询条件
 func (db *Db) FilterWhere(params FilterParams) (*gorm.DB, reflect.Type) {
-	conn := db.Conn.Table(params.Table)
+	conn := db.reader().Table(db.table(params.Table))
 	filters := reflect.TypeOf(params)
 	if _, ok := filters.FieldByName("Fields"); ok {
 		if len(params.Fields) == 0 {
@@ -191,44 +197,45 @@ func (db *Db) FilterWhere(params FilterParams) (*gorm.DB, reflect.Type) {
 		}
 	}
 
-	//查询条件如果未传值或传空值，默认以delStatus=1作为过滤条件
-	if _, ok := filters.FieldByName("Where"); ok {
-		if !reflect.DeepEqual(params.Where, QueryArgs{}) { // 判断是否为空结构体
-			conn.Where(params.Where.Query, params.Where.Args...)
-		} else {
-			conn.Where(map[string]interface{}{
-				"delStatus": 1,
-			})
-		}
-	} else {
-		conn.Where(map[string]interface{}{
-			"delStatus": 1,
-		})
+	//Where与Or条件作为一个整体，再与软删除过滤条件AND组合，避免已删除的数据从Or分支查出
+	hasWhere := !reflect.DeepEqual(params.Where, QueryArgs{}) // 判断是否为空结构体
+	hasOr := !reflect.DeepEqual(params.Or, QueryArgs{})
+	switch {
+	case hasWhere && hasOr:
+		group := conn.Session(&gorm.Session{NewDB: true}).Where(params.Where.Query, params.Where.Args...).Or(params.Or.Query, params.Or.Args...)
+		conn.Where(group)
+	case hasWhere:
+		conn.Where(params.Where.Query, params.Where.Args...)
+	case hasOr:
+		conn.Where(params.Or.Query, params.Or.Args...)
 	}
 
-	if _, ok := filters.FieldByName("Or"); ok {
-		if !reflect.DeepEqual(params.Or, QueryArgs{}) { // 判断是否为空结构体
-			conn.Or(params.Or)
+	//按数据表的软删除策略过滤已删除的数据，未配置策略的表沿用原有约定：只在未传Where条件时以delStatus=1过滤
+	policy := db.policy(params.Table)
+	if policy.softDelete() {
+		switch db.trashed {
+		case trashedExclude:
+			if !hasWhere || db.configured(params.Table) {
+				conn.Where(policy.normal(params.Table != ""))
+			}
+		case trashedOnly:
+			conn.Where(policy.deleted(params.Table != ""))
 		}
 	}
 
 	if _, ok := filters.FieldByName("Not"); ok {
 		if !reflect.DeepEqual(params.Not, QueryArgs{}) { // 判断是否为空结构体
-			conn.Not(params.Not)
+			conn.Not(params.Not.Query, params.Not.Args...)
 		}
 	}
 
-	//排序如果未传值或者传的是一个空值，默认按createAt降序
-	if _, ok := filters.FieldByName("Order"); ok {
-		if len(params.Order) > 0 {
-			for _, value := range params.Order {
-				conn.Order(value)
-			}
-		} else {
-			conn.Order("createdAt desc")
+	//排序如果未传值或者传的是一个空值，使用数据表策略中的默认排序
+	if len(params.Order) > 0 {
+		for _, value := range params.Order {
+			conn.Order(value)
 		}
-	} else {
-		conn.Order("createdAt desc")
+	} else if policy.DefaultOrder != OrderNone {
+		conn.Order(policy.DefaultOrder)
 	}
 
 	if _, ok := filters.FieldByName("Group"); ok {
@@ -239,7 +246,7 @@ func (db *Db) FilterWhere(params FilterParams) (*gorm.DB, reflect.Type) {
 
 	if _, ok := filters.FieldByName("Having"); ok {
 		if !reflect.DeepEqual(params.Having, QueryArgs{}) { // 判断是否为空结构体
-			conn.Having(params.Having)
+			conn.Having(params.Having.Query, params.Having.Args...)
 		}
 	}
 
@@ -261,7 +268,7 @@ func (db *Db) FilterWhere(params FilterParams) (*gorm.DB, reflect.Type) {
 //
 // return: int64  返回操作影响的行数
 func (db *Db) Update(params FilterParams, datas interface{}) (num int64, err error) {
-	conn := db.Conn.Table(params.Table)
+	conn := db.Conn.Table(db.table(params.Table))
 	filters := reflect.TypeOf(params)
 	if _, ok := filters.FieldByName("Where"); ok {
 		if !reflect.DeepEqual(params.Where, QueryArgs{}) { //不允许传空值条件
@@ -280,23 +287,31 @@ func (db *Db) Update(params FilterParams, datas interface{}) (num int64, err err
 	return 0, nil
 }
 
-// Delete 支持真删除和软删除
-// params: FilterParams  匹配条件
+// Delete 支持真删除和软删除，软删除按数据表策略更新状态字段或删除时间字段
+// params: FilterParams  匹配条件，软删除时Table为空则使用model的表名
 // model: interface{} 需要操作的数据表模型，传值需加地址符&
-// scoped: bool 真删除=true 软删除=false
+// scoped: bool 真删除=true 软删除=false，数据表未启用软删除时返回 ErrNoSoftDelete，无法确定表名时返回 ErrPolicyTable
 func (db *Db) Delete(model interface{}, params FilterParams, scoped bool) (num int64, err error) {
 	filters := reflect.TypeOf(params)
 	if _, ok := filters.FieldByName("Where"); ok {
 		if !reflect.DeepEqual(params.Where, QueryArgs{}) { //不允许传空值条件
 			var result *gorm.DB
 			if scoped { //真删除
-				db.Conn = db.Conn.Unscoped()
-				result = db.Conn.Where(params.Where.Query, params.Where.Args...).Delete(model)
+				result = db.Conn.Unscoped().Where(params.Where.Query, params.Where.Args...).Delete(model)
 			} else {
-				result = db.Conn.Table(params.Table).Where(params.Where.Query, params.Where.Args...).Updates(map[string]interface{}{
-					"deletedAt": ptime.TimestampMilli(),
-					"delStatus": 2,
-				})
+				table, err := db.tableOf(model, params.Table)
+				if err != nil {
+					return 0, err
+				}
+				if table == "" {
+					return 0, ErrPolicyTable
+				}
+				policy := db.policy(table)
+				if !policy.softDelete() {
+					return 0, ErrNoSoftDelete
+				}
+				//已删除的数据不重复更新，保留原删除时间
+				result = db.Conn.Table(table).Where(params.Where.Query, params.Where.Args...).Where(policy.normal(false)).Updates(policy.deleteValues())
 			}
 
 			num = result.RowsAffected
@@ -313,13 +328,43 @@ func (db *Db) Delete(model interface{}, params FilterParams, scoped bool) (num i
 	return 0, nil
 }
 
+// Restore 恢复软删除的数据
+// params: FilterParams  匹配条件，Table为空则使用model的表名
+// model: interface{} 需要操作的数据表模型，传值需加地址符&，指定了params.Table时可传nil
+// return: int64  返回恢复的数据条数，数据表未启用软删除时返回 ErrNoSoftDelete，model为nil且未指定Table时返回 ErrPolicyTable
+func (db *Db) Restore(model interface{}, params FilterParams) (num int64, err error) {
+	if reflect.DeepEqual(params.Where, QueryArgs{}) { //不允许传空值条件
+		return 0, nil
+	}
+	table, err := db.tableOf(model, params.Table)
+	if err != nil {
+		return 0, err
+	}
+	if table == "" {
+		return 0, ErrPolicyTable
+	}
+	policy := db.policy(table)
+	if !policy.softDelete() {
+		return 0, ErrNoSoftDelete
+	}
+
+	result := db.Conn.Table(table).Where(params.Where.Query, params.Where.Args...).Where(policy.deleted(false)).Updates(policy.restoreValues())
+	num = result.RowsAffected
+	err = result.Error
+
+	if err != nil {
+		log.Println(result.Error)
+	}
+	return num, err
+}
+
 // Raw 执行原生sql查询
 // res: interface{} 存储查询结果，传值需加地址符&
 // sql: string 原生sql语句
 // values: ...interface{}  多参数值映射
 func (db *Db) Raw(res interface{}, sql string, values ...interface{}) (interface{}, error) {
 	if sql != "" {
-		result := db.Conn.Raw(sql, values...).Scan(res)
+		result := db.reader().Raw(sql, values...).Scan(res)
 		err := result.Error
 		return res, err
 	}
@@ -0,0 +1,230 @@
+package mysql
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// 主库中写入正常数据 alive 及已删除数据 gone
+func newPolicyDb(t *testing.T, conf Config) *Db {
+	t.Helper()
+	path := seedSQLite(t, "alive")
+	conf.DSN = path
+	conf.Prefix = "t_"
+	conf.LogLevel = "silent"
+	conf.Dialector = sqlite.Open
+	db, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("INSERT INTO t_users (name, delStatus) VALUES ('gone', 2)"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func names(t *testing.T, db *Db, params FilterParams) []string {
+	t.Helper()
+	params.Table = "users"
+	params.Limit = [2]int{10, 0}
+	rows, _, err := db.GetList(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := make([]string, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, row["name"].(string))
+	}
+	return res
+}
+
+func where(query string, args ...interface{}) QueryArgs {
+	return QueryArgs{Query: query, Args: args}
+}
+
+func TestFilterWhereLegacyConvention(t *testing.T) {
+	db := newPolicyDb(t, Config{})
+
+	// 未配置策略：未传Where时过滤已删除的数据
+	if got := names(t, db, FilterParams{}); len(got) != 1 || got[0] != "alive" {
+		t.Fatalf("no Where = %v, want [alive]", got)
+	}
+	// 传了Where时由调用方自行处理删除状态
+	if got := names(t, db, FilterParams{Where: where("delStatus = ?", 2)}); len(got) != 1 || got[0] != "gone" {
+		t.Fatalf("Where delStatus = 2 returned %v, want [gone]", got)
+	}
+	// 只传Or时同样过滤，已删除的数据不会从Or分支查出
+	if got := names(t, db, FilterParams{Or: where("name = ?", "gone")}); len(got) != 0 {
+		t.Fatalf("Or without Where returned %v, want none", got)
+	}
+
+	// 直接构造的Db对象，没有delStatus字段的表在传了Where时可以正常查询
+	if _, err := db.Exec("CREATE TABLE t_logs (id INTEGER PRIMARY KEY, msg TEXT, createdAt INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO t_logs (msg) VALUES ('hello')"); err != nil {
+		t.Fatal(err)
+	}
+	legacy := &Db{Prefix: "t_", Conn: db.Conn}
+	row, err := legacy.GetOne(FilterParams{Table: "logs", Where: where("msg = ?", "hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row["msg"] != "hello" {
+		t.Fatalf("legacy GetOne = %v", row)
+	}
+}
+
+func TestFilterWhereConfiguredPolicy(t *testing.T) {
+	db := newPolicyDb(t, Config{Policies: map[string]TablePolicy{"users": {}}})
+
+	// 配置了策略：传了Where也过滤已删除的数据
+	if got := names(t, db, FilterParams{Where: where("name <> ?", "")}); len(got) != 1 || got[0] != "alive" {
+		t.Fatalf("Where = %v, want [alive]", got)
+	}
+	// Where与Or作为整体与过滤条件组合
+	got := names(t, db, FilterParams{Where: where("name = ?", "alive"), Or: where("name = ?", "gone")})
+	if len(got) != 1 || got[0] != "alive" {
+		t.Fatalf("Where OR = %v, want [alive]", got)
+	}
+	if got := names(t, db.OnlyTrashed(), FilterParams{Where: where("name = ?", "alive"), Or: where("name = ?", "gone")}); len(got) != 1 || got[0] != "gone" {
+		t.Fatalf("OnlyTrashed Where OR = %v, want [gone]", got)
+	}
+	if got := names(t, db.WithTrashed(), FilterParams{}); len(got) != 2 {
+		t.Fatalf("WithTrashed = %v, want both rows", got)
+	}
+	// Not条件
+	if got := names(t, db.WithTrashed(), FilterParams{Not: where("name = ?", "alive")}); len(got) != 1 || got[0] != "gone" {
+		t.Fatalf("Not = %v, want [gone]", got)
+	}
+}
+
+func TestFilterWhereGroupsUserConditions(t *testing.T) {
+	db := newPolicyDb(t, Config{Policy: TablePolicy{DefaultOrder: OrderNone}})
+	conn, _ := db.FilterWhere(FilterParams{Table: "users", Where: where("name = ?", "a"), Or: where("name = ?", "b")})
+	sql := conn.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var rows []map[string]interface{}
+		return tx.Find(&rows)
+	})
+	want := "SELECT * FROM `t_users` WHERE (name = \"a\" OR name = \"b\") AND `t_users`.`delStatus` = 1"
+	if sql != want {
+		t.Fatalf("sql = %s\nwant  %s", sql, want)
+	}
+}
+
+// 按name读取删除状态及删除时间
+func deleteState(t *testing.T, db *Db, name string) (status, deletedAt int64) {
+	t.Helper()
+	var row struct {
+		DelStatus int64 `gorm:"column:delStatus"`
+		DeletedAt int64 `gorm:"column:deletedAt"`
+	}
+	if err := db.Conn.Raw("SELECT delStatus, deletedAt FROM t_users WHERE name = ?", name).Scan(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	return row.DelStatus, row.DeletedAt
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	db := newPolicyDb(t, Config{})
+	if _, err := db.Exec("ALTER TABLE t_users ADD COLUMN deletedAt INTEGER DEFAULT 0"); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := db.Delete(&User{}, FilterParams{Where: where("name = ?", "alive")}, false)
+	if err != nil || n != 1 {
+		t.Fatalf("Delete = %d, %v, want 1", n, err)
+	}
+	status, deletedAt := deleteState(t, db, "alive")
+	if status != 2 || deletedAt == 0 {
+		t.Fatalf("after Delete delStatus = %d deletedAt = %d", status, deletedAt)
+	}
+	if got := names(t, db, FilterParams{}); len(got) != 0 {
+		t.Fatalf("after Delete = %v, want none", got)
+	}
+	// 已删除的数据不重复更新，保留原删除时间
+	if n, err := db.Delete(&User{}, FilterParams{Where: where("name <> ?", "")}, false); err != nil || n != 0 {
+		t.Fatalf("Delete deleted rows = %d, %v, want 0", n, err)
+	}
+	if _, again := deleteState(t, db, "alive"); again != deletedAt {
+		t.Fatalf("deletedAt changed from %d to %d", deletedAt, again)
+	}
+
+	// 未指定表名且model为nil时无法确定数据表
+	if _, err := db.Restore(nil, FilterParams{Where: where("name = ?", "alive")}); err != ErrPolicyTable {
+		t.Fatalf("Restore(nil) = %v, want ErrPolicyTable", err)
+	}
+	if _, err := db.Delete(nil, FilterParams{Where: where("name = ?", "alive")}, false); err != ErrPolicyTable {
+		t.Fatalf("Delete(nil) = %v, want ErrPolicyTable", err)
+	}
+
+	n, err = db.Restore(&User{}, FilterParams{Where: where("name = ?", "alive")})
+	if err != nil || n != 1 {
+		t.Fatalf("Restore = %d, %v, want 1", n, err)
+	}
+	if status, deletedAt := deleteState(t, db, "alive"); status != 1 || deletedAt != 0 {
+		t.Fatalf("after Restore delStatus = %d deletedAt = %d", status, deletedAt)
+	}
+	if n, err := db.Restore(nil, FilterParams{Table: "users", Where: where("name = ?", "gone")}); err != nil || n != 1 {
+		t.Fatalf("Restore by table = %d, %v, want 1", n, err)
+	}
+	if got := names(t, db, FilterParams{}); len(got) != 2 {
+		t.Fatalf("after Restore = %v, want both rows", got)
+	}
+
+	// 未启用软删除的表
+	if err := db.SetPolicy(&User{}, TablePolicy{SoftDelete: SoftDeleteNone}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Delete(&User{}, FilterParams{Where: where("name = ?", "alive")}, false); err != ErrNoSoftDelete {
+		t.Fatalf("Delete without soft delete = %v, want ErrNoSoftDelete", err)
+	}
+	if _, err := db.Restore(&User{}, FilterParams{Where: where("name = ?", "alive")}); err != ErrNoSoftDelete {
+		t.Fatalf("Restore without soft delete = %v, want ErrNoSoftDelete", err)
+	}
+}
+
+func TestSoftDeleteDeletedAtPolicy(t *testing.T) {
+	db := newPolicyDb(t, Config{Policies: map[string]TablePolicy{
+		"logs": {SoftDelete: SoftDeleteDeletedAt, Column: "deleted_at", Normal: 0, DeletedValue: func() interface{} { return 7 }, DefaultOrder: "id"},
+	}})
+	for _, stmt := range []string{
+		"CREATE TABLE t_logs (id INTEGER PRIMARY KEY, msg TEXT, deleted_at INTEGER DEFAULT 0)",
+		"INSERT INTO t_logs (msg) VALUES ('a'), ('b')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs := func(db *Db) []string {
+		t.Helper()
+		rows, _, err := db.GetList(FilterParams{Table: "logs", Where: where("msg <> ?", "")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := []string{}
+		for _, row := range rows {
+			res = append(res, row["msg"].(string))
+		}
+		return res
+	}
+
+	if n, err := db.Delete("logs", FilterParams{Where: where("msg = ?", "a")}, false); err != nil || n != 1 {
+		t.Fatalf("Delete = %d, %v, want 1", n, err)
+	}
+	if got := msgs(db); len(got) != 1 || got[0] != "b" {
+		t.Fatalf("after Delete = %v, want [b]", got)
+	}
+	if got := msgs(db.OnlyTrashed()); len(got) != 1 || got[0] != "a" {
+		t.Fatalf("OnlyTrashed = %v, want [a]", got)
+	}
+	if n, err := db.Restore("logs", FilterParams{Where: where("msg = ?", "a")}); err != nil || n != 1 {
+		t.Fatalf("Restore = %d, %v, want 1", n, err)
+	}
+	if got := msgs(db); len(got) != 2 {
+		t.Fatalf("after Restore = %v, want both rows", got)
+	}
+}
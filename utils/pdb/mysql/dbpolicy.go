@@ -0,0 +1,250 @@
+// 数据表策略：软删除方式及默认排序
+// 未配置时沿用原有约定：delStatus=1 正常、delStatus=2 已删除并记录毫秒时间戳到deletedAt，未指定排序时按 createdAt desc 排序。
+// 可以通过 Config.Policy 修改所有表的默认策略，通过 Config.Policies 或 SetPolicy 为单个表(或模型)指定策略，例如：
+//
+//	db, err := mysql.New(mysql.Config{
+//		...
+//		Policies: map[string]mysql.TablePolicy{
+//			"articles": {SoftDelete: mysql.SoftDeleteDeletedAt, Column: "deleted_at", DefaultOrder: "id desc"},
+//			"configs":  {SoftDelete: mysql.SoftDeleteNone, DefaultOrder: mysql.OrderNone},
+//		},
+//	})
+//	db.SetPolicy(&Log{}, mysql.TablePolicy{SoftDelete: mysql.SoftDeleteNone})
+//
+// 为表配置了策略(Config.Policy、Config.Policies或SetPolicy)时，查询始终过滤已删除的数据，Where及Or条件作为整体与过滤条件AND组合；
+// 未配置时沿用原有约定，只在未指定Where条件时过滤，传了Where(包括 BindFilter 生成的条件)就会查出已删除的数据，
+// 对外提供查询的表(如 GET /users?name=x)务必配置策略。db.WithTrashed() 包含已删除的数据，db.OnlyTrashed() 只查询已删除的数据
+package mysql
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/perpower/goframe/funcs/ptime"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	SoftDeleteStatus    = "status"    // 状态字段软删除，如 delStatus=1 正常、2 已删除
+	SoftDeleteDeletedAt = "deletedAt" // 删除时间字段软删除，如 deleted_at IS NULL 为正常
+	SoftDeleteNone      = "none"      // 不使用软删除
+
+	OrderNone = "none" // 不使用默认排序
+)
+
+// 已删除数据的查询方式
+const (
+	trashedExclude = iota // 排除已删除的数据
+	trashedWith           // 包含已删除的数据
+	trashedOnly           // 只查询已删除的数据
+)
+
+// TablePolicy 数据表策略，零值字段使用默认值
+type TablePolicy struct {
+	SoftDelete   string             // 软删除方式 status | deletedAt | none，默认status
+	Column       string             // status方式为状态字段，默认delStatus；deletedAt方式为删除时间字段，默认deletedAt
+	Normal       interface{}        // 未删除时Column的值，status方式默认1，deletedAt方式默认nil(即 IS NULL)，时间戳字段可设为0
+	Deleted      interface{}        // status方式下已删除的状态值，默认2
+	TimeColumn   string             // status方式下同时记录删除时间的字段，默认deletedAt，恢复时置为0，none表示不记录
+	DeletedValue func() interface{} // 删除时间的值，status方式默认毫秒时间戳，deletedAt方式默认time.Now()
+	DefaultOrder string             // 未指定Order时的默认排序，默认 createdAt desc，none表示不排序
+}
+
+// policySet 已配置的策略，同一连接的所有视图及事务共用
+type policySet struct {
+	mu       sync.RWMutex
+	fallback TablePolicy
+	explicit bool // 是否配置了Config.Policy
+	tables   map[string]TablePolicy
+}
+
+var (
+	defaultStatusColumn    = "delStatus"
+	defaultDeletedAtColumn = "deletedAt"
+	defaultDefaultOrder    = "createdAt desc"
+
+	ErrNoSoftDelete = errors.New("mysql: 数据表未启用软删除")
+	ErrPolicyTable  = errors.New("mysql: 无法确定策略对应的数据表")
+)
+
+// SetPolicy 设置单个表的策略，覆盖 Config.Policy 中的默认策略
+// table: interface{} 表名(不含前缀亦可)，或数据表模型(传值需加地址符&)
+func (db *Db) SetPolicy(table interface{}, policy TablePolicy) error {
+	name, err := db.tableOf(table, "")
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return ErrPolicyTable
+	}
+	if db.policies == nil {
+		db.policies = &policySet{}
+	}
+	db.policies.mu.Lock()
+	defer db.policies.mu.Unlock()
+	if db.policies.tables == nil {
+		db.policies.tables = make(map[string]TablePolicy)
+	}
+	db.policies.tables[name] = policy
+	return nil
+}
+
+// WithTrashed 返回查询结果包含已删除数据的对象，未配置策略的表传了Where时本就包含已删除的数据
+func (db *Db) WithTrashed() *Db {
+	view := *db
+	view.trashed = trashedWith
+	return &view
+}
+
+// OnlyTrashed 返回只查询已删除数据的对象
+func (db *Db) OnlyTrashed() *Db {
+	view := *db
+	view.trashed = trashedOnly
+	return &view
+}
+
+// 表的策略，已填充默认值
+// table: string 表名，可带别名如 "users u"
+func (db *Db) policy(table string) TablePolicy {
+	var policy TablePolicy
+	if db.policies != nil {
+		db.policies.mu.RLock()
+		var ok bool
+		if policy, ok = db.policies.tables[db.table(baseTable(table))]; !ok {
+			policy = db.policies.fallback
+		}
+		db.policies.mu.RUnlock()
+	}
+	return policy.filled()
+}
+
+// 表是否显式配置了策略，未配置时查询沿用原有的过滤约定
+func (db *Db) configured(table string) bool {
+	if db.policies == nil {
+		return false
+	}
+	db.policies.mu.RLock()
+	defer db.policies.mu.RUnlock()
+	if _, ok := db.policies.tables[db.table(baseTable(table))]; ok {
+		return true
+	}
+	return db.policies.explicit
+}
+
+// 去掉表名中的别名，如 "users u"
+func baseTable(table string) string {
+	if fields := strings.Fields(table); len(fields) > 0 {
+		return fields[0]
+	}
+	return table
+}
+
+// 表名：优先使用params中的表名，否则按模型解析
+func (db *Db) tableOf(model interface{}, table string) (string, error) {
+	if table != "" {
+		return db.table(table), nil
+	}
+	switch v := model.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return db.table(v), nil
+	}
+	stmt := &gorm.Statement{DB: db.Conn}
+	if err := stmt.Parse(model); err != nil {
+		return "", err
+	}
+	return db.table(stmt.Schema.Table), nil
+}
+
+func (p TablePolicy) filled() TablePolicy {
+	if p.SoftDelete == "" {
+		p.SoftDelete = SoftDeleteStatus
+	}
+	switch p.SoftDelete {
+	case SoftDeleteStatus:
+		if p.Column == "" {
+			p.Column = defaultStatusColumn
+		}
+		if p.Normal == nil {
+			p.Normal = 1
+		}
+		if p.Deleted == nil {
+			p.Deleted = 2
+		}
+		if p.TimeColumn == "" {
+			p.TimeColumn = defaultDeletedAtColumn
+		}
+		if p.DeletedValue == nil {
+			p.DeletedValue = func() interface{} {
+				return ptime.TimestampMilli()
+			}
+		}
+	case SoftDeleteDeletedAt:
+		if p.Column == "" {
+			p.Column = defaultDeletedAtColumn
+		}
+		if p.DeletedValue == nil {
+			p.DeletedValue = func() interface{} {
+				return time.Now()
+			}
+		}
+	}
+	if p.DefaultOrder == "" {
+		p.DefaultOrder = defaultDefaultOrder
+	}
+	return p
+}
+
+func (p TablePolicy) softDelete() bool {
+	return p.SoftDelete == SoftDeleteStatus || p.SoftDelete == SoftDeleteDeletedAt
+}
+
+// 未删除数据的过滤条件
+// qualified: 是否以当前表名限定字段，避免Join时字段名冲突
+func (p TablePolicy) normal(qualified bool) clause.Expression {
+	return clause.Eq{Column: p.column(qualified), Value: p.Normal}
+}
+
+// 已删除数据的过滤条件
+func (p TablePolicy) deleted(qualified bool) clause.Expression {
+	if p.SoftDelete == SoftDeleteStatus {
+		return clause.Eq{Column: p.column(qualified), Value: p.Deleted}
+	}
+	return clause.Neq{Column: p.column(qualified), Value: p.Normal}
+}
+
+// 软删除时更新的字段
+func (p TablePolicy) deleteValues() map[string]interface{} {
+	values := map[string]interface{}{
+		p.Column: p.Deleted,
+	}
+	if p.SoftDelete == SoftDeleteDeletedAt {
+		values[p.Column] = p.DeletedValue()
+	} else if p.TimeColumn != SoftDeleteNone {
+		values[p.TimeColumn] = p.DeletedValue()
+	}
+	return values
+}
+
+// 恢复时更新的字段
+func (p TablePolicy) restoreValues() map[string]interface{} {
+	values := map[string]interface{}{
+		p.Column: p.Normal,
+	}
+	if p.SoftDelete == SoftDeleteStatus && p.TimeColumn != SoftDeleteNone {
+		values[p.TimeColumn] = 0
+	}
+	return values
+}
+
+func (p TablePolicy) column(qualified bool) clause.Column {
+	if qualified {
+		return clause.Column{Table: clause.CurrentTable, Name: p.Column}
+	}
+	return clause.Column{Name: p.Column}
+}
@@ -0,0 +1,178 @@
+// 数据库事务
+// 回调中的tx与Db用法一致，所有方法都在同一事务中执行(包括读操作，不会路由到从库)，例如：
+//
+//	err := db.Transaction(ctx, func(tx *mysql.Db) error {
+//		if _, err := tx.Update(mysql.FilterParams{Table: "account", Where: ...}, map[string]interface{}{...}); err != nil {
+//			return err
+//		}
+//		// 嵌套调用使用SAVEPOINT，内层返回error只回滚内层的修改
+//		if err := tx.Transaction(ctx, func(tx *mysql.Db) error {
+//			_, err := tx.Create(&Log{...})
+//			return err
+//		}); err != nil {
+//			log.Println(err)
+//		}
+//		return nil
+//	})
+//
+// 回调返回error或panic时事务回滚，panic会在回滚后继续抛出；
+// 遇到死锁或锁等待超时时按 Config.TxRetries 或 TxOptions.Retries 重新执行整个回调，因此回调中不要包含事务外的副作用。
+// MySQL在死锁时会回滚整个事务，内层返回这类错误后即使外层忽略该错误，整个事务也会回滚、重试并最终返回该错误
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/perpower/goframe/utils/prand"
+)
+
+// TxOptions 事务选项
+type TxOptions struct {
+	Isolation sql.IsolationLevel // 隔离级别，默认使用数据库的默认级别
+	ReadOnly  bool               // 只读事务
+	Retries   int                // 死锁或锁等待超时时的重试次数，0表示使用Config.TxRetries，负数表示不重试
+}
+
+// txState 事务内共享的状态
+type txState struct {
+	savepoints int   // 已创建的SAVEPOINT数量，用于生成唯一的名称
+	aborted    error // 内层遇到死锁或锁等待超时，数据库已回滚整个事务
+}
+
+var (
+	txRetryInterval    = 20 * time.Millisecond // 重试的初始等待时间，之后按指数退避
+	txMaxRetryInterval = time.Second
+
+	// 可重试的MySQL错误码：1213 死锁，1205 锁等待超时
+	txRetryErrors = map[uint16]struct{}{
+		1213: {},
+		1205: {},
+	}
+)
+
+// Transaction 执行事务，在事务内部调用时创建SAVEPOINT作为嵌套事务
+// ctx: context.Context 事务中所有语句使用的context，嵌套调用时沿用外层事务的context
+// fn: func(tx *Db) error 事务回调，返回nil时提交，返回error时回滚并原样返回该error
+// opts: TxOptions 事务选项，嵌套调用时忽略
+func (db *Db) Transaction(ctx context.Context, fn func(tx *Db) error, opts ...TxOptions) error {
+	if db.tx != nil {
+		return db.savepoint(fn)
+	}
+
+	opt := TxOptions{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	retries := db.txRetries
+	if opt.Retries != 0 {
+		retries = opt.Retries
+	}
+	var sqlOpt *sql.TxOptions
+	if opt.Isolation != sql.LevelDefault || opt.ReadOnly {
+		sqlOpt = &sql.TxOptions{Isolation: opt.Isolation, ReadOnly: opt.ReadOnly}
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := db.transaction(ctx, fn, sqlOpt)
+		if err == nil || attempt >= retries || !IsRetryable(err) {
+			return err
+		}
+
+		wait := txRetryInterval << attempt
+		if wait > txMaxRetryInterval || wait <= 0 {
+			wait = txMaxRetryInterval
+		}
+		wait += time.Duration(prand.Intn(int(wait)))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// 执行一次事务
+func (db *Db) transaction(ctx context.Context, fn func(tx *Db) error, opt *sql.TxOptions) (err error) {
+	var tx = db.Conn.WithContext(ctx)
+	if opt != nil {
+		tx = tx.Begin(opt)
+	} else {
+		tx = tx.Begin()
+	}
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	panicked := true
+	defer func() {
+		// panic、回调返回error或者提交失败时回滚
+		if panicked || err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	txDb := *db
+	txDb.Conn = tx
+	txDb.replicas = nil
+	txDb.tx = &txState{}
+	err = fn(&txDb)
+	if txDb.tx.aborted != nil {
+		// 外层忽略了内层的错误，事务已不可提交，返回该错误以便重试
+		err = txDb.tx.aborted
+	}
+	if err == nil {
+		err = tx.Commit().Error
+	}
+	panicked = false
+	return err
+}
+
+// 以SAVEPOINT执行嵌套事务，出错时只回滚到该SAVEPOINT；
+// 遇到死锁或锁等待超时时SAVEPOINT已随整个事务回滚，标记事务中止，由最外层重新执行
+func (db *Db) savepoint(fn func(tx *Db) error) (err error) {
+	if db.tx.aborted != nil {
+		return db.tx.aborted
+	}
+	db.tx.savepoints++
+	name := fmt.Sprintf("sp%d", db.tx.savepoints)
+	if err := db.Conn.SavePoint(name).Error; err != nil {
+		return err
+	}
+
+	panicked := true
+	defer func() {
+		if !panicked && IsRetryable(err) {
+			db.tx.aborted = err
+			return
+		}
+		if panicked || err != nil {
+			db.Conn.RollbackTo(name)
+		}
+	}()
+
+	err = fn(db)
+	panicked = false
+	return err
+}
+
+// InTransaction 是否为事务中的对象
+func (db *Db) InTransaction() bool {
+	return db.tx != nil
+}
+
+// IsRetryable 判断错误是否为死锁或锁等待超时，这类错误重新执行事务通常可以成功
+func IsRetryable(err error) bool {
+	var e *driver.MySQLError
+	if errors.As(err, &e) {
+		_, ok := txRetryErrors[e.Number]
+		return ok
+	}
+	return false
+}
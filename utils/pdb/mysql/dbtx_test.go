@@ -0,0 +1,141 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	driver "github.com/go-sql-driver/mysql"
+)
+
+func insertName(tx *Db, name string) error {
+	_, err := tx.Exec("INSERT INTO t_users (name) VALUES (?)", name)
+	return err
+}
+
+func TestTransactionCommit(t *testing.T) {
+	db, primary, _ := newSQLite(t)
+
+	err := db.Transaction(context.Background(), func(tx *Db) error {
+		if !tx.InTransaction() {
+			t.Fatal("tx should be in a transaction")
+		}
+		if err := insertName(tx, "a"); err != nil {
+			return err
+		}
+		return insertName(tx, "b")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(namesIn(t, primary), ","); got != "primary,a,b" {
+		t.Fatalf("rows = %s, want primary,a,b", got)
+	}
+	if db.InTransaction() {
+		t.Fatal("db outside the callback must not be in a transaction")
+	}
+}
+
+func TestTransactionRollbackOnError(t *testing.T) {
+	db, primary, _ := newSQLite(t)
+
+	failed := errors.New("failed")
+	err := db.Transaction(context.Background(), func(tx *Db) error {
+		if err := insertName(tx, "a"); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("Transaction = %v, want the callback error", err)
+	}
+	if got := strings.Join(namesIn(t, primary), ","); got != "primary" {
+		t.Fatalf("rows = %s, want the insert rolled back", got)
+	}
+}
+
+func TestTransactionRollbackOnPanic(t *testing.T) {
+	db, primary, _ := newSQLite(t)
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("recovered %v, want the panic to be re-raised", r)
+			}
+		}()
+		db.Transaction(context.Background(), func(tx *Db) error {
+			if err := insertName(tx, "a"); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+	if got := strings.Join(namesIn(t, primary), ","); got != "primary" {
+		t.Fatalf("rows = %s, want the insert rolled back", got)
+	}
+	// 回滚后连接归还连接池，之后的事务正常执行
+	if err := db.Transaction(context.Background(), func(tx *Db) error { return insertName(tx, "b") }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSavepointRollsBackInnerOnly(t *testing.T) {
+	db, primary, _ := newSQLite(t)
+
+	inner := errors.New("inner")
+	err := db.Transaction(context.Background(), func(tx *Db) error {
+		if err := insertName(tx, "outer"); err != nil {
+			return err
+		}
+		if err := tx.Transaction(context.Background(), func(tx *Db) error {
+			if err := insertName(tx, "inner"); err != nil {
+				return err
+			}
+			return inner
+		}); err != inner {
+			t.Fatalf("inner Transaction = %v, want the inner error", err)
+		}
+		return tx.Transaction(context.Background(), func(tx *Db) error {
+			return insertName(tx, "kept")
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(namesIn(t, primary), ","); got != "primary,outer,kept" {
+		t.Fatalf("rows = %s, want primary,outer,kept", got)
+	}
+}
+
+func TestSavepointDeadlockRetriesWholeTransaction(t *testing.T) {
+	db, primary, _ := newSQLite(t)
+
+	deadlock := &driver.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+	attempts := 0
+	err := db.Transaction(context.Background(), func(tx *Db) error {
+		attempts++
+		if err := insertName(tx, "outer"); err != nil {
+			return err
+		}
+		// 外层忽略内层的错误，死锁后事务已被数据库回滚，仍需整体重试
+		tx.Transaction(context.Background(), func(tx *Db) error {
+			return deadlock
+		})
+		if err := tx.Transaction(context.Background(), func(tx *Db) error {
+			return insertName(tx, "after")
+		}); err != deadlock {
+			t.Errorf("savepoint after the deadlock = %v, want the deadlock", err)
+		}
+		return nil
+	}, TxOptions{Retries: 2})
+	if err != deadlock {
+		t.Fatalf("Transaction = %v, want the deadlock", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("callback ran %d times, want 3", attempts)
+	}
+	if got := strings.Join(namesIn(t, primary), ","); got != "primary" {
+		t.Fatalf("rows = %s, want every attempt rolled back", got)
+	}
+}
@@ -3,6 +3,9 @@
 package redis
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
@@ -10,7 +13,14 @@ import (
 
 type Client struct {
 	config      *Config
-	conn        redis.Conn
+	topo        topology
+	exec        executor
+	ctx         context.Context
+	timeout     time.Duration // 单条命令超时时间
+	ns          namespace     // 键名前缀
+	hooks       *hookSet      // 命令钩子，所有视图共用
+	conn        commander
+	release     func() error // Pin()返回的独占连接对象使用，用于归还连接
 	Db          *Rdb
 	Scan        *Rscan
 	String      *Rstring
@@ -23,46 +33,139 @@ type Client struct {
 	Transaction *Rtransaction
 	Hyper       *Rhyper
 	Bit         *Rbit
+	Stream      *Rstream
+	Script      *Rscript
+	Function    *Rfunction
+}
+
+// 连接池状态统计
+type PoolStats = redis.PoolStats
+
+// commander 命令执行器，各类型操作对象统一通过它发送命令
+type commander interface {
+	Do(commandName string, args ...interface{}) (reply interface{}, err error)
+}
+
+// executor 支持context的底层命令执行器
+type executor interface {
+	do(ctx context.Context, commandName string, args ...interface{}) (reply interface{}, err error)
+}
+
+// connector 可提供独立连接的执行器，管道等需要在同一连接上批量收发命令的场景使用
+type connector interface {
+	acquire(ctx context.Context) (conn redis.Conn, release func() error, err error)
+}
+
+// batcher 自行处理批量命令的执行器，集群模式下按节点拆分管道
+type batcher interface {
+	batch(ctx context.Context, cmds []*queuedCmd) error
+}
+
+// pooled 每条命令从连接池中取出一个连接执行，执行完成后立即归还
+type pooled struct {
+	pool     *redis.Pool
+	failover func(ctx context.Context, err error) bool // 哨兵模式下检测主从切换，返回true表示命令可在新主节点上重试
+}
+
+// pinned 固定在单个连接上执行所有命令，用于事务等需要连接状态的场景
+type pinned struct {
+	conn redis.Conn
+}
+
+// bound 将客户端视图上的context及超时设置绑定到底层执行器
+type bound struct {
+	exec    executor
+	ctx     context.Context
+	timeout time.Duration
+	ns      namespace
+	hooks   *hookSet
 }
 
 var (
 	defaultFlushdbMode = "SYNC" // FLUSHDB 默认清空模式
 	defaultCursor      = 0      // SCAN默认起始游标
 	defaultScanNum     = 10     // 单次scan迭代数量
+	testOnBorrowIdle   = time.Minute
+
+	// 依赖连接状态的命令，不允许在连接池模式下直接执行
+	connStateCommands = map[string]struct{}{
+		"WATCH": {}, "UNWATCH": {}, "MULTI": {}, "EXEC": {}, "DISCARD": {}, "SELECT": {},
+	}
+
+	ErrPinRequired = errors.New("redis: 该命令依赖连接状态，需在Pin()返回的独占连接上执行")
 )
 
-// 返回指定name的客户端单例对象
+// New 创建客户端并通过PING检测连接可用性，认证失败、地址不可达等错误直接返回
+// 按config.Mode创建单机、哨兵或集群客户端
+func New(config Config) (*Client, error) {
+	c := Instance(config)
+	if err := c.Ping(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// 返回指定name的客户端单例对象，不检测连接可用性，连接错误在执行第一条命令时返回，推荐使用New
+// 按config.Mode创建单机、哨兵或集群客户端，各类型操作对象的用法在三种模式下一致
 func Instance(config Config) *Client {
 	redisConfig := SetConfig(config)
+
 	c := &Client{
 		config: &redisConfig,
+		ctx:    context.Background(),
+		hooks:  &hookSet{},
 	}
-
-	pool := &redis.Pool{
-		MaxIdle:     redisConfig.MaxIdle,
-		MaxActive:   redisConfig.MaxActive,
-		IdleTimeout: time.Duration(redisConfig.IdleTimeout) * time.Second,
-		Dial: func() (redis.Conn, error) {
-			return redis.Dial(
-				"tcp",
-				redisConfig.Address,
-				redis.DialDatabase(redisConfig.Database),
-				redis.DialPassword(redisConfig.Password),
-				redis.DialUseTLS(redisConfig.UseTLS),
-			)
-		},
+	switch redisConfig.Mode {
+	case ModeSentinel:
+		s := newSentinel(&redisConfig)
+		c.topo = s
+		c.exec = &pooled{pool: s.pool, failover: s.failover}
+	case ModeCluster:
+		redisConfig.Database = 0 // 集群只支持0号数据库
+		cl := newCluster(&redisConfig)
+		c.topo = cl
+		c.exec = cl
+	default:
+		s := newStandalone(&redisConfig)
+		c.topo = s
+		c.exec = &pooled{pool: s.pool}
 	}
+	c.bind()
 
-	//用完了就关闭这个链接
-	defer pool.Close()
+	return c
+}
 
-	//从pool连接池里取出一个链接
-	conn := pool.Get()
+// 按配置与指定地址建立连接
+func dial(ctx context.Context, conf *Config, address string) (redis.Conn, error) {
+	options := []redis.DialOption{
+		redis.DialDatabase(conf.Database),
+		redis.DialUsername(conf.Username),
+		redis.DialPassword(conf.Password),
+		redis.DialUseTLS(conf.UseTLS),
+	}
+	if conf.ConnectTimeout > 0 {
+		options = append(options, redis.DialConnectTimeout(time.Duration(conf.ConnectTimeout)*time.Millisecond))
+	}
+	if conf.ReadTimeout > 0 {
+		options = append(options, redis.DialReadTimeout(time.Duration(conf.ReadTimeout)*time.Millisecond))
+	}
+	if conf.WriteTimeout > 0 {
+		options = append(options, redis.DialWriteTimeout(time.Duration(conf.WriteTimeout)*time.Millisecond))
+	}
+	return redis.DialContext(ctx, "tcp", address, options...)
+}
 
+// 将各类型操作对象绑定到当前视图的命令执行器上
+func (c *Client) bind() {
+	conn := &bound{
+		exec:    c.exec,
+		ctx:     c.ctx,
+		timeout: c.timeout,
+		ns:      c.ns,
+		hooks:   c.hooks,
+	}
 	c.conn = conn
-
-	auth(c) // 身份校验
-
 	c.Db = &Rdb{
 		conn: conn,
 	}
@@ -99,27 +202,150 @@ func Instance(config Config) *Client {
 	c.Bit = &Rbit{
 		conn: conn,
 	}
-
-	return c
+	c.Stream = &Rstream{
+		conn: conn,
+	}
+	c.Script = &Rscript{
+		conn: conn,
+	}
+	c.Function = &Rfunction{
+		conn: conn,
+	}
 }
 
-// 身份校验
-func auth(c *Client) {
-	//链接redis服务，进行身份验证
-	if c.config.Username != "" {
-		_, err := c.conn.Do("AUTH", c.config.Username, c.config.Password)
-		if err != nil {
-			panic(err)
-		}
-	} else {
-		_, err := c.conn.Do("AUTH", c.config.Password)
-		if err != nil {
-			panic(err)
-		}
+// 复制出一个新的客户端视图，连接池与配置共用，modify用于调整新视图的执行器、context等设置
+func (c *Client) derive(modify func(view *Client)) *Client {
+	view := &Client{
+		config:  c.config,
+		topo:    c.topo,
+		exec:    c.exec,
+		ctx:     c.ctx,
+		timeout: c.timeout,
+		ns:      c.ns,
+		hooks:   c.hooks,
+		release: c.release,
 	}
+	modify(view)
+	view.bind()
+	return view
 }
 
 // 通用Do方法保留
 func (c *Client) Do(commandName string, args ...interface{}) (reply interface{}, err error) {
 	return c.conn.Do(commandName, args...)
 }
+
+// WithContext 返回绑定指定context的客户端视图，所有命令都会响应该context的取消及截止时间
+// 例如在gin中使用：client.WithContext(c.Request.Context()).String.Get(key)
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return c.derive(func(view *Client) {
+		view.ctx = ctx
+	})
+}
+
+// WithTimeout 返回设置了单条命令超时时间的客户端视图，每条命令独立计时，与WithContext可叠加使用
+func (c *Client) WithTimeout(timeout time.Duration) *Client {
+	return c.derive(func(view *Client) {
+		view.timeout = timeout
+	})
+}
+
+// Ping 检测连接可用性
+func (c *Client) Ping() error {
+	_, err := redis.String(c.conn.Do("PING"))
+	return err
+}
+
+// Context 返回当前视图绑定的context
+func (c *Client) Context() context.Context {
+	return c.ctx
+}
+
+// Pin 从连接池中取出一个连接并独占，返回的客户端对象所有命令都在该连接上执行，
+// 事务(WATCH/MULTI/EXEC)、SELECT等依赖连接状态的命令必须通过此方式执行，使用完毕必须调用Close()归还连接
+// key: 集群模式下必须指定，连接建立在该key所在槽位的主节点上，其他模式忽略
+func (c *Client) Pin(key ...string) (*Client, error) {
+	routeKey := ""
+	if len(key) > 0 {
+		routeKey = string(c.ns) + key[0]
+	}
+	conn, err := c.topo.pin(c.ctx, routeKey)
+	if err != nil {
+		return nil, err
+	}
+	return c.derive(func(view *Client) {
+		view.exec = &pinned{conn: conn}
+		view.release = conn.Close
+	}), nil
+}
+
+// Stats 返回连接池状态统计，集群模式下为各节点连接池之和
+func (c *Client) Stats() PoolStats {
+	return c.topo.stats()
+}
+
+// Close 对于Pin()返回的对象，归还独占的连接；否则关闭整个连接池
+func (c *Client) Close() error {
+	if c.release != nil {
+		return c.release()
+	}
+	return c.topo.close()
+}
+
+func (p *pooled) do(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	if _, ok := connStateCommands[strings.ToUpper(commandName)]; ok {
+		return nil, ErrPinRequired
+	}
+	reply, err := p.once(ctx, commandName, args...)
+	if err != nil && p.failover != nil && p.failover(ctx, err) {
+		reply, err = p.once(ctx, commandName, args...)
+	}
+	return reply, err
+}
+
+func (p *pooled) once(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	conn, err := p.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return redis.DoContext(conn, ctx, commandName, args...)
+}
+
+func (p *pooled) acquire(ctx context.Context) (redis.Conn, func() error, error) {
+	conn, err := p.pool.GetContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, conn.Close, nil
+}
+
+func (p *pinned) do(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	return redis.DoContext(p.conn, ctx, commandName, args...)
+}
+
+func (p *pinned) acquire(ctx context.Context) (redis.Conn, func() error, error) {
+	// 独占连接由Pin()的调用方负责归还
+	return p.conn, func() error { return nil }, nil
+}
+
+func (b *bound) Do(commandName string, args ...interface{}) (interface{}, error) {
+	ctx, cancel := b.context()
+	defer cancel()
+	args = b.ns.args(commandName, args)
+	reply, err := b.hooks.do(ctx, commandName, args, func(ctx context.Context) (interface{}, error) {
+		return b.exec.do(ctx, commandName, args...)
+	})
+	return b.ns.reply(commandName, reply), err
+}
+
+// 返回绑定的context，供单条命令使用，包含超时设置
+func (b *bound) context() (context.Context, context.CancelFunc) {
+	if b.timeout > 0 {
+		return context.WithTimeout(b.ctx, b.timeout)
+	}
+	return b.ctx, func() {}
+}
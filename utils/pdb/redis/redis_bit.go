@@ -2,12 +2,14 @@
 package redis
 
 import (
+	"errors"
+
 	"github.com/gomodule/redigo/redis"
 	"github.com/perpower/goframe/funcs/normal"
 )
 
 type Rbit struct {
-	conn redis.Conn
+	conn commander
 }
 
 // SETBIT 对 key 所储存的字符串值，设置或清除指定偏移量上的位(bit), 位的设置或清除取决于 value 参数，
@@ -105,3 +107,109 @@ func (b *Rbit) Bitop(operation, destkey string, keys []string) (int, error) {
 	}
 	return redis.Int(b.conn.Do("BITOP", args...))
 }
+
+// BitfieldOp BITFIELD 子命令
+type BitfieldOp struct {
+	Op       string // 子命令，取值：GET | SET | INCRBY
+	Type     string // 整数类型，i表示有符号，u表示无符号，后跟位数，如 i8、u16，有符号最大i64，无符号最大u63
+	Offset   string // 偏移量，数字表示位偏移，"#N"表示按类型宽度的第N个整数
+	Value    int64  // SET的新值或INCRBY的增量，GET忽略
+	Overflow string // SET/INCRBY的溢出处理方式，取值：WRAP(回绕，默认) | SAT(饱和) | FAIL(不执行)，不指定传空
+}
+
+var ErrBitfieldOp = errors.New("redis: BITFIELD子命令不正确，Op取值 GET | SET | INCRBY，Overflow取值 WRAP | SAT | FAIL")
+
+// BITFIELD 将字符串视为位数组，对其中任意宽度、任意偏移的整数进行读取、设置及自增，多个子命令按顺序原子执行
+// key: string 键名
+// ops: []BitfieldOp 子命令数组
+// return:
+//
+//	reply: []*int64 与子命令一一对应的结果，GET返回当前值，SET返回旧值，INCRBY返回新值；
+//	OVERFLOW FAIL 时因溢出未执行的子命令对应nil
+//
+// link: https://redis.io/commands/bitfield/
+func (b *Rbit) Bitfield(key string, ops []BitfieldOp) ([]*int64, error) {
+	if len(ops) == 0 {
+		return []*int64{}, nil
+	}
+	args := make([]interface{}, 0, len(ops)*4+1)
+	args = append(args, key)
+	for _, op := range ops {
+		if op.Overflow != "" {
+			if !normal.InArray(op.Overflow, []string{"WRAP", "SAT", "FAIL"}) || op.Op == "GET" {
+				return []*int64{}, ErrBitfieldOp
+			}
+			args = append(args, "OVERFLOW", op.Overflow)
+		}
+		switch op.Op {
+		case "GET":
+			args = append(args, op.Op, op.Type, op.Offset)
+		case "SET", "INCRBY":
+			args = append(args, op.Op, op.Type, op.Offset, op.Value)
+		default:
+			return []*int64{}, ErrBitfieldOp
+		}
+	}
+
+	values, err := redis.Values(b.conn.Do("BITFIELD", args...))
+	if err != nil {
+		return []*int64{}, err
+	}
+	res := make([]*int64, 0, len(values))
+	for _, v := range values {
+		if v == nil {
+			res = append(res, nil)
+			continue
+		}
+		n, err := redis.Int64(v, nil)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, &n)
+	}
+	return res, nil
+}
+
+// BITFIELD GET 读取指定类型及偏移量的整数
+// key: string 键名
+// typ: string 整数类型，如 i8、u16
+// offset: string 偏移量，数字表示位偏移，"#N"表示按类型宽度的第N个整数
+// return: int64
+// link: https://redis.io/commands/bitfield/
+func (b *Rbit) BitfieldGet(key, typ, offset string) (int64, error) {
+	return bitfieldOne(b.Bitfield(key, []BitfieldOp{{Op: "GET", Type: typ, Offset: offset}}))
+}
+
+// BITFIELD SET 设置指定类型及偏移量的整数
+// key: string 键名
+// typ: string 整数类型，如 i8、u16
+// offset: string 偏移量，数字表示位偏移，"#N"表示按类型宽度的第N个整数
+// value: int64 新值
+// overflow: string 取值：WRAP | SAT | FAIL，不指定传空(WRAP)
+// return: int64 旧值，overflow为FAIL且溢出时返回 redis.ErrNil
+// link: https://redis.io/commands/bitfield/
+func (b *Rbit) BitfieldSet(key, typ, offset string, value int64, overflow string) (int64, error) {
+	return bitfieldOne(b.Bitfield(key, []BitfieldOp{{Op: "SET", Type: typ, Offset: offset, Value: value, Overflow: overflow}}))
+}
+
+// BITFIELD INCRBY 对指定类型及偏移量的整数进行自增，increment可以为负数
+// key: string 键名
+// typ: string 整数类型，如 i8、u16
+// offset: string 偏移量，数字表示位偏移，"#N"表示按类型宽度的第N个整数
+// increment: int64 增量
+// overflow: string 取值：WRAP | SAT | FAIL，不指定传空(WRAP)
+// return: int64 新值，overflow为FAIL且溢出时返回 redis.ErrNil
+// link: https://redis.io/commands/bitfield/
+func (b *Rbit) BitfieldIncrBy(key, typ, offset string, increment int64, overflow string) (int64, error) {
+	return bitfieldOne(b.Bitfield(key, []BitfieldOp{{Op: "INCRBY", Type: typ, Offset: offset, Value: increment, Overflow: overflow}}))
+}
+
+func bitfieldOne(res []*int64, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	if len(res) == 0 || res[0] == nil {
+		return 0, redis.ErrNil
+	}
+	return *res[0], nil
+}
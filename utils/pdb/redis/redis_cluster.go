@@ -0,0 +1,555 @@
+// Redis Cluster 集群模式
+// 通过 CLUSTER SLOTS 获取槽位与主节点的对应关系，命令按key所在槽位发送到对应主节点，
+// 收到MOVED时更新槽位并在后台刷新整个槽位表，收到ASK时在目标节点上先发送ASKING再执行命令，
+// 节点不可达时刷新槽位表后重试，以此跟随扩缩容及故障转移。
+//
+// 特别说明：
+//  1. 多key命令、事务、脚本涉及的key必须位于同一槽位，可使用hash tag，如 {user:1}:name、{user:1}:age
+//  2. 事务需通过 Pin(key) 或 Tx(keys, fn) 在key所在节点上执行
+//  3. KEYS、DBSIZE等无key命令只在任意一个主节点上执行；FLUSHDB、FLUSHALL、SCRIPT、FUNCTION会在所有主节点上执行
+//  4. SCAN游标只对单个节点有效，Scan.ScanOnce 返回 ErrClusterScanCursor，遍历全部键请使用 Scan.ScanIter、Scan.ScanAllE 或 Scan.Delete，
+//     它们会依次遍历所有主节点
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const clusterSlots = 16384
+
+type cluster struct {
+	conf       *Config
+	seeds      []string
+	mu         sync.RWMutex
+	slots      [clusterSlots]string   // 槽位 → 主节点地址
+	pools      map[string]*redis.Pool // 节点地址 → 连接池
+	refreshMu  sync.Mutex
+	refreshed  time.Time // 上次刷新槽位表的时间
+	refreshing int32     // 后台刷新进行中
+}
+
+var (
+	defaultMaxRedirects  = 5
+	clusterRetryWait     = 100 * time.Millisecond // TRYAGAIN、CLUSTERDOWN时的重试间隔
+	clusterRefreshPeriod = 100 * time.Millisecond // 两次刷新槽位表的最小间隔
+
+	// 不含key的命令，发送到任意主节点
+	keylessCommands = map[string]struct{}{
+		"PING": {}, "ECHO": {}, "INFO": {}, "TIME": {}, "DBSIZE": {}, "RANDOMKEY": {}, "SCAN": {}, "KEYS": {},
+		"CLUSTER": {}, "CONFIG": {}, "COMMAND": {}, "CLIENT": {}, "ROLE": {}, "LASTSAVE": {}, "WAIT": {},
+		"PUBLISH": {}, "PUBSUB": {}, "SAVE": {}, "BGSAVE": {}, "BGREWRITEAOF": {}, "SLOWLOG": {}, "LATENCY": {},
+	}
+	// 需要在所有主节点上执行的命令
+	broadcastCommands = map[string]struct{}{
+		"FLUSHDB": {}, "FLUSHALL": {}, "SCRIPT": {}, "FUNCTION": {},
+	}
+
+	ErrClusterKeyRequired = errors.New("redis: 集群模式下需指定key以确定连接所在节点")
+	ErrClusterDown        = errors.New("redis: 集群槽位未完全分配")
+	ErrTooManyRedirects   = errors.New("redis: 集群重定向次数过多")
+	ErrClusterScanCursor  = errors.New("redis: 集群模式下SCAN游标只对单个节点有效，请使用 Scan.ScanIter、ScanAllE 或 Delete")
+)
+
+func newCluster(conf *Config) *cluster {
+	seeds := append([]string{}, conf.Addrs...)
+	if len(seeds) == 0 && conf.Address != "" {
+		seeds = append(seeds, conf.Address)
+	}
+	return &cluster{
+		conf:  conf,
+		seeds: seeds,
+		pools: map[string]*redis.Pool{},
+	}
+}
+
+// KeySlot 计算key所在的槽位，key中包含hash tag({...})时只对花括号内的部分计算
+func KeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % clusterSlots)
+}
+
+// CRC16-CCITT(XMODEM)
+func crc16(data string) uint16 {
+	var crc uint16
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// 返回命令中用于路由的第一个key
+func commandKey(commandName string, args []interface{}) (string, bool) {
+	pos := 0
+	switch commandName {
+	case "EVAL", "EVALSHA", "EVAL_RO", "EVALSHA_RO", "FCALL", "FCALL_RO":
+		if len(args) < 3 {
+			return "", false
+		}
+		if n, err := intArg(args[1]); err != nil || n <= 0 {
+			return "", false
+		}
+		pos = 2
+	case "BITOP", "OBJECT", "MEMORY", "XGROUP", "XINFO",
+		"ZUNION", "ZINTER", "ZDIFF", "ZINTERCARD", "SINTERCARD", "LMPOP", "ZMPOP":
+		pos = 1
+	case "BLMPOP", "BZMPOP":
+		pos = 2
+	case "XREAD", "XREADGROUP":
+		pos = -1
+		for i, arg := range args {
+			if s, ok := arg.(string); ok && strings.EqualFold(s, "STREAMS") {
+				pos = i + 1
+				break
+			}
+		}
+	}
+	if pos < 0 || pos >= len(args) {
+		return "", false
+	}
+	switch v := args[pos].(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
+
+// 将命令参数转换为int，参数可以是Go整数类型或者数字字符串
+func intArg(arg interface{}) (int, error) {
+	switch v := arg.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case int32:
+		return int(v), nil
+	default:
+		return redis.Int(arg, nil)
+	}
+}
+
+// 解析重定向错误，返回类型(MOVED|ASK)、槽位及目标节点
+func parseRedirect(err error) (kind string, slot int, addr string) {
+	e, ok := err.(redis.Error)
+	if !ok {
+		return "", 0, ""
+	}
+	parts := strings.Fields(string(e))
+	if len(parts) != 3 || (parts[0] != "MOVED" && parts[0] != "ASK") {
+		return "", 0, ""
+	}
+	slot, _ = strconv.Atoi(parts[1])
+	return parts[0], slot, parts[2]
+}
+
+// 是否为集群暂不可用的错误，稍后重试即可
+func isClusterRetry(err error) bool {
+	e, ok := err.(redis.Error)
+	return ok && (strings.HasPrefix(string(e), "TRYAGAIN") || strings.HasPrefix(string(e), "CLUSTERDOWN"))
+}
+
+// 返回节点的连接池，不存在时创建
+func (c *cluster) pool(addr string) *redis.Pool {
+	c.mu.RLock()
+	pool, ok := c.pools[addr]
+	c.mu.RUnlock()
+	if ok {
+		return pool
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pool, ok = c.pools[addr]; !ok {
+		pool = newPool(c.conf, func(ctx context.Context) (redis.Conn, error) {
+			return dial(ctx, c.conf, addr)
+		}, nil)
+		c.pools[addr] = pool
+	}
+	return pool
+}
+
+// 刷新槽位表，依次向已知节点及种子节点查询 CLUSTER SLOTS
+func (c *cluster) refresh(ctx context.Context) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if time.Since(c.refreshed) < clusterRefreshPeriod {
+		return nil
+	}
+
+	candidates := c.masters()
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	candidates = append(candidates, c.seeds...)
+
+	lastErr := errors.New("redis: 未配置集群节点地址")
+	for _, addr := range candidates {
+		slots, err := c.querySlots(ctx, addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		c.mu.Lock()
+		c.slots = *slots
+		used := map[string]struct{}{}
+		for _, node := range c.slots {
+			used[node] = struct{}{}
+		}
+		// 关闭已移出集群的节点的连接池
+		for node, pool := range c.pools {
+			if _, ok := used[node]; !ok {
+				pool.Close()
+				delete(c.pools, node)
+			}
+		}
+		c.mu.Unlock()
+		c.refreshed = time.Now()
+		return nil
+	}
+	return lastErr
+}
+
+// 后台刷新槽位表，已有刷新在进行时直接返回
+func (c *cluster) refreshAsync() {
+	if !atomic.CompareAndSwapInt32(&c.refreshing, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&c.refreshing, 0)
+		c.refresh(context.Background())
+	}()
+}
+
+// CLUSTER SLOTS 查询槽位分配
+func (c *cluster) querySlots(ctx context.Context, addr string) (*[clusterSlots]string, error) {
+	conn, err := c.pool(addr).GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	res, err := redis.Values(redis.DoContext(conn, ctx, "CLUSTER", "SLOTS"))
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	slots := &[clusterSlots]string{}
+	for _, item := range res {
+		fields, err := redis.Values(item, nil)
+		if err != nil || len(fields) < 3 {
+			continue
+		}
+		start, _ := redis.Int(fields[0], nil)
+		end, _ := redis.Int(fields[1], nil)
+		node, _ := redis.Values(fields[2], nil)
+		if len(node) < 2 || start < 0 || end >= clusterSlots {
+			continue
+		}
+		ip, _ := redis.String(node[0], nil)
+		port, _ := redis.Int(node[1], nil)
+		if ip == "" || ip == "?" {
+			// 节点未声明ip时与被查询的节点相同
+			ip = host
+		}
+		master := net.JoinHostPort(ip, strconv.Itoa(port))
+		for i := start; i <= end; i++ {
+			slots[i] = master
+		}
+	}
+	return slots, nil
+}
+
+// 当前槽位表中的全部主节点
+func (c *cluster) masters() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	seen := map[string]struct{}{}
+	arr := make([]string, 0)
+	for _, node := range c.slots {
+		if _, ok := seen[node]; node == "" || ok {
+			continue
+		}
+		seen[node] = struct{}{}
+		arr = append(arr, node)
+	}
+	return arr
+}
+
+// nodes 返回全部主节点地址，槽位表为空时先刷新
+func (c *cluster) nodes(ctx context.Context) ([]string, error) {
+	if _, err := c.node(ctx, 0); err != nil {
+		return nil, err
+	}
+	return c.masters(), nil
+}
+
+// doNode 在指定节点上执行命令，不处理重定向
+func (c *cluster) doNode(ctx context.Context, addr string, commandName string, args ...interface{}) (interface{}, error) {
+	reply, err, _ := c.send(ctx, addr, false, commandName, args)
+	return reply, err
+}
+
+// 返回槽位所在的主节点，slot小于0时随机选择一个主节点
+func (c *cluster) node(ctx context.Context, slot int) (string, error) {
+	if slot < 0 {
+		slot = rand.Intn(clusterSlots)
+	}
+	for i := 0; i < 2; i++ {
+		c.mu.RLock()
+		addr := c.slots[slot]
+		c.mu.RUnlock()
+		if addr != "" {
+			return addr, nil
+		}
+		if i == 0 {
+			if err := c.refresh(ctx); err != nil {
+				return "", err
+			}
+		}
+	}
+	return "", ErrClusterDown
+}
+
+// 收到MOVED时更新单个槽位，并在后台刷新整个槽位表
+func (c *cluster) moved(slot int, addr string) {
+	if slot >= 0 && slot < clusterSlots {
+		c.mu.Lock()
+		c.slots[slot] = addr
+		c.mu.Unlock()
+	}
+	c.refreshAsync()
+}
+
+func (c *cluster) maxRedirects() int {
+	if c.conf.MaxRedirects > 0 {
+		return c.conf.MaxRedirects
+	}
+	return defaultMaxRedirects
+}
+
+func (c *cluster) do(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	upper := strings.ToUpper(commandName)
+	if _, ok := connStateCommands[upper]; ok {
+		return nil, ErrPinRequired
+	}
+	if _, ok := broadcastCommands[upper]; ok {
+		return c.broadcast(ctx, commandName, args)
+	}
+	slot := -1
+	if _, ok := keylessCommands[upper]; !ok {
+		if key, ok := commandKey(upper, args); ok {
+			slot = KeySlot(key)
+		}
+	}
+	return c.route(ctx, slot, commandName, args)
+}
+
+// 将命令发送到槽位所在节点，处理MOVED/ASK重定向及故障转移
+func (c *cluster) route(ctx context.Context, slot int, commandName string, args []interface{}) (interface{}, error) {
+	addr := ""
+	asking := false
+	var reply interface{}
+	var err error
+	for i := 0; i <= c.maxRedirects(); i++ {
+		if addr == "" {
+			if addr, err = c.node(ctx, slot); err != nil {
+				return nil, err
+			}
+		}
+
+		var sent bool
+		reply, err, sent = c.send(ctx, addr, asking, commandName, args)
+		if err == nil || ctx.Err() != nil {
+			return reply, err
+		}
+		if !sent {
+			// 节点不可达，可能已发生故障转移，刷新槽位表后重试
+			c.refresh(ctx)
+			addr, asking = "", false
+			continue
+		}
+
+		kind, movedSlot, target := parseRedirect(err)
+		switch {
+		case kind == "MOVED":
+			c.moved(movedSlot, target)
+			addr, asking = target, false
+		case kind == "ASK":
+			addr, asking = target, true
+		case isClusterRetry(err):
+			timer := time.NewTimer(clusterRetryWait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+			addr, asking = "", false
+		default:
+			if _, ok := err.(redis.Error); !ok {
+				// 命令已发送后连接出错，是否已执行无法确定，不重试
+				c.refreshAsync()
+			}
+			return reply, err
+		}
+	}
+	if _, ok := err.(redis.Error); ok {
+		return nil, fmt.Errorf("%w: %v", ErrTooManyRedirects, err)
+	}
+	return nil, err
+}
+
+// 在指定节点上执行命令
+// return: sent 命令是否已发送，未发送说明无法取得连接
+func (c *cluster) send(ctx context.Context, addr string, asking bool, commandName string, args []interface{}) (reply interface{}, err error, sent bool) {
+	conn, err := c.pool(addr).GetContext(ctx)
+	if err != nil {
+		return nil, err, false
+	}
+	defer conn.Close()
+	if asking {
+		if err := conn.Send("ASKING"); err != nil {
+			return nil, err, false
+		}
+	}
+	reply, err = redis.DoContext(conn, ctx, commandName, args...)
+	return reply, err, true
+}
+
+// 在所有主节点上执行命令，返回第一个节点的回复或第一个错误
+func (c *cluster) broadcast(ctx context.Context, commandName string, args []interface{}) (interface{}, error) {
+	if _, err := c.node(ctx, 0); err != nil {
+		return nil, err
+	}
+	var first interface{}
+	for i, addr := range c.masters() {
+		reply, err, _ := c.send(ctx, addr, false, commandName, args)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			first = reply
+		}
+	}
+	return first, nil
+}
+
+// 管道命令按节点分组并发发送，从未发送(ErrNotExecuted)及被重定向的命令再单独执行；
+// 已写入连接后出错的命令可能已执行，直接返回连接错误，不重新执行
+func (c *cluster) batch(ctx context.Context, cmds []*queuedCmd) error {
+	groups := map[string][]*queuedCmd{}
+	for _, cmd := range cmds {
+		upper := strings.ToUpper(cmd.commandName)
+		_, keyless := keylessCommands[upper]
+		_, bcast := broadcastCommands[upper]
+		key, ok := commandKey(upper, cmd.args)
+		if keyless || bcast || !ok {
+			// 无法确定节点的命令之后单独执行
+			continue
+		}
+		addr, err := c.node(ctx, KeySlot(key))
+		if err != nil {
+			cmd.reply.set(nil, err)
+			continue
+		}
+		groups[addr] = append(groups[addr], cmd)
+	}
+
+	var wg sync.WaitGroup
+	for addr, group := range groups {
+		wg.Add(1)
+		go func(addr string, group []*queuedCmd) {
+			defer wg.Done()
+			conn, err := c.pool(addr).GetContext(ctx)
+			if err != nil {
+				// 节点不可达，命令保持未执行状态，单条执行时刷新槽位表后重试
+				return
+			}
+			defer conn.Close()
+			// 出错时已发送的命令带有连接错误，未发送的命令保持未执行状态
+			pipe(ctx, conn, group)
+		}(addr, group)
+	}
+	wg.Wait()
+
+	for _, cmd := range cmds {
+		if cmd.reply.err == ErrNotExecuted || c.retryable(cmd.reply.err) {
+			cmd.reply.set(c.do(ctx, cmd.commandName, cmd.args...))
+		}
+	}
+	return nil
+}
+
+// 管道中可单独重新执行的错误：重定向、集群暂不可用
+func (c *cluster) retryable(err error) bool {
+	kind, _, _ := parseRedirect(err)
+	return kind != "" || isClusterRetry(err)
+}
+
+func (c *cluster) pin(ctx context.Context, key string) (redis.Conn, error) {
+	if key == "" {
+		return nil, ErrClusterKeyRequired
+	}
+	addr, err := c.node(ctx, KeySlot(key))
+	if err != nil {
+		return nil, err
+	}
+	return c.pool(addr).GetContext(ctx)
+}
+
+func (c *cluster) addr(ctx context.Context) (string, error) {
+	return c.node(ctx, -1)
+}
+
+// 各节点连接池状态之和
+func (c *cluster) stats() PoolStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	total := PoolStats{}
+	for _, pool := range c.pools {
+		s := pool.Stats()
+		total.ActiveCount += s.ActiveCount
+		total.IdleCount += s.IdleCount
+		total.WaitCount += s.WaitCount
+		total.WaitDuration += s.WaitDuration
+	}
+	return total
+}
+
+func (c *cluster) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	for addr, pool := range c.pools {
+		if e := pool.Close(); e != nil && err == nil {
+			err = e
+		}
+		delete(c.pools, addr)
+	}
+	return err
+}
@@ -0,0 +1,402 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/perpower/goframe/utils/pdb/redis/redistest"
+)
+
+// standinCluster 由多个standin节点组成的集群，节点按槽位表校验key，
+// 不属于本节点时返回MOVED，迁移中的槽位由原节点返回ASK
+type standinCluster struct {
+	t     *testing.T
+	nodes []*redistest.Server
+	extra func(i int, c *redistest.Conn, args []string) (interface{}, bool) // 在槽位校验之前调用
+
+	mu        sync.Mutex
+	owners    [clusterSlots]int // 槽位 → 节点序号
+	migrating map[int]int       // 迁移中的槽位 → 目标节点序号
+	redirects int32             // 返回MOVED/ASK的次数
+}
+
+func newStandinCluster(t *testing.T, n int, setup ...func(sc *standinCluster)) *standinCluster {
+	t.Helper()
+	sc := &standinCluster{t: t, migrating: map[int]int{}}
+	for slot := range sc.owners {
+		sc.owners[slot] = slot * n / clusterSlots
+	}
+	for _, fn := range setup {
+		fn(sc)
+	}
+	for i := 0; i < n; i++ {
+		i := i
+		sc.nodes = append(sc.nodes, redistest.NewServer(t, func(s *redistest.Server) {
+			s.Hook = func(c *redistest.Conn, args []string) (interface{}, bool) {
+				return sc.hook(i, c, args)
+			}
+		}))
+	}
+	return sc
+}
+
+func (sc *standinCluster) addrs() []string {
+	addrs := make([]string, 0, len(sc.nodes))
+	for _, node := range sc.nodes {
+		addrs = append(addrs, node.Addr())
+	}
+	return addrs
+}
+
+// 当前负责key所在槽位的节点序号
+func (sc *standinCluster) owner(key string) int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.owners[KeySlot(key)]
+}
+
+// 将槽位分配给指定节点，不通知客户端
+func (sc *standinCluster) move(slot, to int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.owners[slot] = to
+}
+
+// CLUSTER SLOTS 回复
+func (sc *standinCluster) slotsReply() []interface{} {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	ranges := []interface{}{}
+	for start := 0; start < clusterSlots; {
+		end := start
+		for end+1 < clusterSlots && sc.owners[end+1] == sc.owners[start] {
+			end++
+		}
+		host, port, _ := net.SplitHostPort(sc.nodes[sc.owners[start]].Addr())
+		p, _ := strconv.Atoi(port)
+		ranges = append(ranges, []interface{}{int64(start), int64(end), []interface{}{host, int64(p)}})
+		start = end + 1
+	}
+	return ranges
+}
+
+func (sc *standinCluster) hook(i int, c *redistest.Conn, args []string) (interface{}, bool) {
+	if sc.extra != nil {
+		if reply, ok := sc.extra(i, c, args); ok {
+			return reply, true
+		}
+	}
+	name := strings.ToUpper(args[0])
+	if name == "CLUSTER" && len(args) > 1 && strings.ToUpper(args[1]) == "SLOTS" {
+		return sc.slotsReply(), true
+	}
+	if _, ok := keylessCommands[name]; ok || name == "SELECT" || name == "AUTH" || name == "ASKING" {
+		return nil, false
+	}
+	if name == "SUBSCRIBE" || name == "UNSUBSCRIBE" || len(args) < 2 {
+		return nil, false
+	}
+
+	slot := KeySlot(args[1])
+	switch name {
+	case "DEL", "UNLINK", "EXISTS", "MGET":
+		for _, key := range args[2:] {
+			if KeySlot(key) != slot {
+				return redistest.Error("CROSSSLOT Keys in request don't hash to the same slot"), true
+			}
+		}
+	}
+	sc.mu.Lock()
+	owner := sc.owners[slot]
+	target, migrating := sc.migrating[slot]
+	sc.mu.Unlock()
+	switch {
+	case migrating && i == owner:
+		atomic.AddInt32(&sc.redirects, 1)
+		return redistest.Error(fmt.Sprintf("ASK %d %s", slot, sc.nodes[target].Addr())), true
+	case migrating && i == target && c.Asking():
+		return nil, false
+	case i != owner:
+		atomic.AddInt32(&sc.redirects, 1)
+		return redistest.Error(fmt.Sprintf("MOVED %d %s", slot, sc.nodes[owner].Addr())), true
+	}
+	return nil, false
+}
+
+// 模拟槽位迁移后服务端主动退订分片频道
+func (sc *standinCluster) kick(i int, channel string) {
+	sc.nodes[i].Kick(channel)
+}
+
+// 返回第n个(从0开始)落在指定节点上的key
+func (sc *standinCluster) keyOn(node int, prefix string, n int) string {
+	for i := 0; ; i++ {
+		key := prefix + strconv.Itoa(i)
+		if sc.owner(key) == node {
+			if n == 0 {
+				return key
+			}
+			n--
+		}
+	}
+}
+
+func newClusterClient(t *testing.T, sc *standinCluster, conf ...Config) *Client {
+	t.Helper()
+	config := Config{}
+	if len(conf) > 0 {
+		config = conf[0]
+	}
+	config.Mode = ModeCluster
+	config.Addrs = sc.addrs()
+	client, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestKeySlot(t *testing.T) {
+	cases := map[string]int{
+		"":                     0,
+		"foo":                  12182,
+		"bar":                  5061,
+		"123456789":            12739,
+		"{user1000}.following": KeySlot("user1000"),
+		"{user1000}.followers": KeySlot("user1000"),
+		"foo{}{bar}":           KeySlot("foo{}{bar}"),
+		"foo{{bar}}zap":        KeySlot("{bar"),
+		"foo{bar}{zap}":        KeySlot("bar"),
+	}
+	for key, want := range cases {
+		if got := KeySlot(key); got != want {
+			t.Errorf("KeySlot(%q) = %d, want %d", key, got, want)
+		}
+	}
+	// 空的hash tag不生效，按整个key计算
+	if KeySlot("foo{}{bar}") == KeySlot("bar") {
+		t.Error("an empty hash tag must not be used")
+	}
+}
+
+func TestClusterMovedAndAsk(t *testing.T) {
+	sc := newStandinCluster(t, 3)
+	client := newClusterClient(t, sc)
+
+	key := sc.keyOn(0, "moved:", 0)
+	if _, err := client.String.Set(key, "v1", "", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := sc.nodes[0].Do("GET", key).(string)
+	if stored != "v1" {
+		t.Fatalf("key not stored on its owner")
+	}
+
+	// 槽位迁移到节点2，客户端收到MOVED后跟随并更新槽位表
+	sc.move(KeySlot(key), 2)
+	if _, err := client.String.Set(key, "v2", "", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := client.String.Get(key); err != nil || got != "v2" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	if n := atomic.LoadInt32(&sc.redirects); n != 1 {
+		t.Fatalf("redirects = %d, want 1 (the slot table should be updated after MOVED)", n)
+	}
+	stored, _ = sc.nodes[2].Do("GET", key).(string)
+	if stored != "v2" {
+		t.Fatalf("key not stored on the new owner")
+	}
+
+	// 迁移中的槽位：原节点返回ASK，客户端在目标节点上先发送ASKING，槽位表不变
+	askKey := sc.keyOn(1, "ask:", 0)
+	sc.mu.Lock()
+	sc.migrating[KeySlot(askKey)] = 0
+	sc.mu.Unlock()
+	atomic.StoreInt32(&sc.redirects, 0)
+	for i := 0; i < 2; i++ {
+		if _, err := client.String.Set(askKey, "asked", "", "", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&sc.redirects); n != 2 {
+		t.Fatalf("redirects = %d, want 2 (ASK must not update the slot table)", n)
+	}
+	stored, _ = sc.nodes[0].Do("GET", askKey).(string)
+	if stored != "asked" {
+		t.Fatalf("ASK target did not receive the command")
+	}
+}
+
+func TestClusterPipelineSplit(t *testing.T) {
+	sc := newStandinCluster(t, 3)
+	client := newClusterClient(t, sc)
+
+	var keys []string
+	for node := 0; node < 3; node++ {
+		for n := 0; n < 4; n++ {
+			keys = append(keys, sc.keyOn(node, "pipe:", n))
+		}
+	}
+	// 其中一个槽位已迁移而客户端不知道，该命令收到MOVED后单独重试
+	sc.move(KeySlot(keys[0]), 1)
+
+	for i := range sc.nodes {
+		sc.nodes[i].ResetCommands()
+	}
+	p := client.Pipeline()
+	results := make([]*Cmd[int], 0, len(keys))
+	for _, key := range keys {
+		results = append(results, Queue(p, func() (int, error) { return p.String.Incr(key) }))
+	}
+	if _, err := p.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range results {
+		if v, err := r.Result(); err != nil || v != 1 {
+			t.Fatalf("%s: %d, %v", keys[i], v, err)
+		}
+	}
+	for node := range sc.nodes {
+		if n := sc.nodes[node].Commands(); n < 4 {
+			t.Fatalf("node %d received %d commands, want at least 4", node, n)
+		}
+		for _, key := range sc.nodes[node].Keys() {
+			if sc.owner(key) != node {
+				t.Errorf("node %d stored %s which belongs to node %d", node, key, sc.owner(key))
+			}
+		}
+	}
+}
+
+func TestClusterPipelineDoesNotRerunSentCommands(t *testing.T) {
+	var incrs int32
+	sc := newStandinCluster(t, 1, func(sc *standinCluster) {
+		sc.extra = func(i int, c *redistest.Conn, args []string) (interface{}, bool) {
+			// 第一条INCR阻塞读取，使客户端写入剩余的大命令时超时
+			if args[0] == "INCR" && atomic.AddInt32(&incrs, 1) == 1 {
+				time.Sleep(500 * time.Millisecond)
+			}
+			return nil, false
+		}
+	})
+	client := newClusterClient(t, sc, Config{WriteTimeout: 100})
+
+	p := client.Pipeline()
+	counter := Queue(p, func() (int, error) { return p.String.Incr("counter") })
+	p.String.Set("big", strings.Repeat("x", 32<<20), "", "", 0)
+	if _, err := p.Exec(); err == nil {
+		t.Fatal("Exec should fail when the write times out")
+	}
+	if counter.Err() == nil || counter.Err() == ErrNotExecuted {
+		t.Fatalf("counter err = %v, want the connection error", counter.Err())
+	}
+
+	// 等待服务端处理完已发送的命令，已发送的INCR只能执行一次
+	time.Sleep(700 * time.Millisecond)
+	value, _ := sc.nodes[0].Do("GET", "counter").(string)
+	if value != "1" {
+		t.Fatalf("counter = %q, want 1 (a sent command must not be re-run)", value)
+	}
+}
+
+func TestClusterScan(t *testing.T) {
+	sc := newStandinCluster(t, 2)
+	client := newClusterClient(t, sc)
+
+	var want []string
+	for node := 0; node < 2; node++ {
+		for n := 0; n < 5; n++ {
+			key := sc.keyOn(node, "scan:", n)
+			sc.nodes[node].Do("SET", key, "1")
+			want = append(want, key)
+		}
+	}
+	sc.nodes[0].Do("SET", "other", "1")
+	sort.Strings(want)
+
+	// 游标只对单个节点有效，集群模式下不能直接使用
+	if _, _, err := client.Scan.ScanOnce(0, "scan:*", 3, ""); err != ErrClusterScanCursor {
+		t.Fatalf("ScanOnce: %v, want ErrClusterScanCursor", err)
+	}
+
+	got, err := client.Scan.ScanAllE("scan:*", 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("ScanAllE = %v, want %v", got, want)
+	}
+
+	// 每批键分布在两个节点的多个槽位上，按槽位拆分后删除
+	n, err := client.Scan.ScanDelE("scan:*", 4, "")
+	if err != nil || n != len(want) {
+		t.Fatalf("ScanDelE = %d, %v, want %d", n, err, len(want))
+	}
+	for node := range sc.nodes {
+		for _, key := range sc.nodes[node].Keys() {
+			if key != "other" {
+				t.Errorf("node %d still has %s", node, key)
+			}
+		}
+	}
+	if keys := sc.nodes[0].Keys(); len(keys) != 1 {
+		t.Fatalf("node 0 keys = %v, want [other]", keys)
+	}
+}
+
+func TestClusterShardSubscribe(t *testing.T) {
+	sc := newStandinCluster(t, 2)
+	client := newClusterClient(t, sc)
+	first, second := sc.keyOn(0, "shard:", 0), sc.keyOn(1, "shard:", 0)
+
+	var reconnects int32
+	sub := client.NewSubscriber(SubscribeOptions{
+		ShardChannels: []string{first, second},
+		OnReconnect:   func() { atomic.AddInt32(&reconnects, 1) },
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs := sub.Channel(ctx, 10)
+
+	spublish := func(channel, payload string) {
+		t.Helper()
+		waitFor(t, "shard subscriber of "+channel, func() bool {
+			n, err := client.Spublish(channel, payload)
+			return err == nil && n == 1
+		})
+		select {
+		case msg := <-msgs:
+			if msg.Kind != "smessage" || msg.Channel != channel || msg.Payload != payload {
+				t.Fatalf("got %+v", msg)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("message not delivered")
+		}
+	}
+	// 每个分片频道在所属节点上订阅
+	spublish(first, "a")
+	spublish(second, "b")
+
+	// 槽位迁移到节点1，原节点退订该频道；客户端按旧槽位表订阅时收到MOVED，随后在新节点上订阅
+	sc.move(KeySlot(first), 1)
+	sc.kick(0, first)
+	spublish(first, "c")
+	spublish(second, "d")
+	if atomic.LoadInt32(&reconnects) == 0 {
+		t.Fatal("OnReconnect should be called after resubscribing")
+	}
+	if subs := sc.nodes[0].Subscriptions(); len(subs) > 0 {
+		t.Fatalf("node 0 still has subscriptions %v", subs)
+	}
+}
@@ -0,0 +1,81 @@
+// 管道及事务中排队命令的类型化结果
+// 通过 Queue 调用各类型操作对象的方法排队命令，Exec之后由该方法自身对回复做类型转换，例如：
+//
+//	p := client.Pipeline()
+//	name := redis.Queue(p, func() (string, error) { return p.Hash.Hget("user:1", "name") })
+//	visits := redis.Queue(p, func() (int, error) { return p.String.Incr("visits") })
+//	top := redis.Queue(p, func() ([]redis.ZMember, error) { return p.Zset.ZpopMax("rank", 3) })
+//	if _, err := p.Exec(); err != nil {
+//		return err
+//	}
+//	n, err := visits.Result()
+//
+// 排队时方法的回调会执行一次以记录命令，Exec之后再执行一次，此时方法发出的命令直接得到对应的回复，
+// 因此回调中只应调用一个管道(或事务)上的方法，不要包含其他副作用
+package redis
+
+// Cmd 排队命令的类型化结果，Exec之前读取时返回 ErrNotExecuted
+type Cmd[T any] struct {
+	val T
+	err error
+}
+
+// Batch 可以排队命令的管道或事务
+type Batch interface {
+	cmdQueue() *cmdQueue
+}
+
+// Queue 通过类型化方法向管道或事务中排队命令，返回该方法的类型化结果
+// b: Batch 管道(*Pipeline)或事务(*Tx)
+// fn: func() (T, error) 调用b上的一个类型化方法，如 func() (int, error) { return p.String.Incr("k") }
+func Queue[T any](b Batch, fn func() (T, error)) *Cmd[T] {
+	q := b.cmdQueue()
+	start := len(q.cmds)
+	val, err := fn()
+
+	cmd := &Cmd[T]{err: ErrNotExecuted}
+	if len(q.cmds) == start {
+		// 方法未发出命令(如参数校验失败)，直接使用其返回值
+		cmd.val, cmd.err = val, err
+		return cmd
+	}
+
+	replies := make([]*Reply, 0, len(q.cmds)-start)
+	for _, c := range q.cmds[start:] {
+		replies = append(replies, c.reply)
+	}
+	q.decoders = append(q.decoders, func() {
+		q.replay, q.replaying = replies, true
+		defer func() {
+			q.replay, q.replaying = nil, false
+		}()
+		cmd.val, cmd.err = fn()
+	})
+	return cmd
+}
+
+// Result 返回转换后的结果及错误
+func (c *Cmd[T]) Result() (T, error) {
+	return c.val, c.err
+}
+
+// Val 返回转换后的结果，出错时为零值
+func (c *Cmd[T]) Val() T {
+	return c.val
+}
+
+// Err 返回该命令的执行或转换错误
+func (c *Cmd[T]) Err() error {
+	return c.err
+}
+
+func (q *queued) cmdQueue() *cmdQueue {
+	return q.queue
+}
+
+// 依次调用排队时登记的解码函数，将回复转换为各自的类型化结果
+func (q *cmdQueue) decode(decoders []func()) {
+	for _, fn := range decoders {
+		fn()
+	}
+}
@@ -1,49 +1,55 @@
 package redis
 
-import (
-	"reflect"
-)
-
 type Config struct {
-	Address     string // 地址 ip:port
-	Username    string // redis6.0版本以上开始提供Redis ACL,用户名+密码一起使用
-	Password    string // 密码
-	Database    int    // 数据库
-	UseTLS      bool   // 是否启用tls
-	MaxIdle     int    // 允许闲置的最大连接数(0表示不限制)
-	MaxActive   int    // 最大连接数量限制(0表示不限制)
-	IdleTimeout int    // 连接最大空闲时间
+	Mode             string   // 部署模式 standalone(默认，单机)|sentinel(哨兵)|cluster(集群)
+	Address          string   // 地址 ip:port，standalone模式使用
+	Addrs            []string // sentinel模式为哨兵地址列表，cluster模式为种子节点地址列表
+	MasterName       string   // sentinel模式哨兵监控的主节点名称
+	SentinelUsername string   // 哨兵的ACL用户名，未设置时不认证
+	SentinelPassword string   // 哨兵的密码
+	MaxRedirects     int      // cluster模式MOVED/ASK最大重定向次数(0表示使用默认值5)
+	Username         string   // redis6.0版本以上开始提供Redis ACL,用户名+密码一起使用
+	Password         string   // 密码
+	Database         int      // 数据库
+	UseTLS           bool     // 是否启用tls
+	MaxIdle          int      // 连接池保留的最大空闲连接数(0表示使用默认值10，负数表示不保留空闲连接)
+	MaxActive        int      // 最大连接数量限制(0表示使用默认值1000，负数表示不限制)
+	IdleTimeout      int      // 连接最大空闲时间，单位s(0表示使用默认值10，负数表示不限制)
+	MaxConnLifetime  int      // 连接最长存活时间，单位s(0表示不限制)
+	Wait             bool     // 连接数达到MaxActive时是否等待空闲连接，false则直接返回错误
+	ConnectTimeout   int      // 建立连接超时时间，单位ms(0表示不限制)
+	ReadTimeout      int      // 读取命令回复超时时间，单位ms(0表示不限制)
+	WriteTimeout     int      // 写入命令超时时间，单位ms(0表示不限制)
+	TxRetries        int      // Tx事务乐观锁冲突时的自动重试次数(0表示使用默认值3)
 }
 
 var (
-	defaultDatabase    = 0     // 默认数据库
-	defaultUseTLS      = false // 默认是否使用TLS
 	defaultMaxIdle     = 10
 	defaultMaxActive   = 1000
 	defaultIdleTimeout = 10
 )
 
+// SetConfig 为未设置的连接池参数填充默认值，负数转换为redigo中表示不保留或不限制的0
 func SetConfig(conf Config) Config {
-	cnf := reflect.TypeOf(conf)
-
-	if _, ok := cnf.FieldByName("Database"); !ok {
-		conf.Database = defaultDatabase
-	}
-
-	if _, ok := cnf.FieldByName("UseTLS"); !ok {
-		conf.UseTLS = defaultUseTLS
-	}
-
-	if _, ok := cnf.FieldByName("MaxIdle"); !ok {
+	switch {
+	case conf.MaxIdle == 0:
 		conf.MaxIdle = defaultMaxIdle
+	case conf.MaxIdle < 0:
+		conf.MaxIdle = 0
 	}
 
-	if _, ok := cnf.FieldByName("MaxActive"); !ok {
+	switch {
+	case conf.MaxActive == 0:
 		conf.MaxActive = defaultMaxActive
+	case conf.MaxActive < 0:
+		conf.MaxActive = 0
 	}
 
-	if _, ok := cnf.FieldByName("IdleTimeout"); !ok {
+	switch {
+	case conf.IdleTimeout == 0:
 		conf.IdleTimeout = defaultIdleTimeout
+	case conf.IdleTimeout < 0:
+		conf.IdleTimeout = 0
 	}
 
 	return conf
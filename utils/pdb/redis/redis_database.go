@@ -1,10 +1,13 @@
 // Redis Database相关操作
 package redis
 
-import "github.com/gomodule/redigo/redis"
+import (
+	"github.com/gomodule/redigo/redis"
+	"github.com/perpower/goframe/funcs/normal"
+)
 
 type Rdb struct {
-	conn redis.Conn
+	conn commander
 }
 
 // 返回指定的键存在的数量
@@ -18,22 +21,26 @@ func (c *Rdb) Exists(keys []string) (reply int, err error) {
 	return redis.Int(c.conn.Do("EXISTS", args...))
 }
 
-// 删除当前数据库中的所有键，次命令永不失败，慎用！
+// 删除当前数据库中的所有键，慎用！
 // mode: string 刷新模式  SYNC(同步)|ASYNC(异步)
-func (c *Rdb) FlushDb(mode string) {
-	if mode == "" {
+// return: 始终返回"OK"
+// link: https://redis.io/commands/flushdb/
+func (c *Rdb) FlushDb(mode string) (string, error) {
+	if !normal.InArray(mode, []string{"SYNC", "ASYNC"}) {
 		mode = defaultFlushdbMode
 	}
-	c.conn.Do("FLUSHDB", mode)
+	return redis.String(c.conn.Do("FLUSHDB", mode))
 }
 
-// 删除所有数据库中的所有键，此命令永不失败，慎用！
+// 删除所有数据库中的所有键，慎用！
 // mode: string 刷新模式  SYNC(同步)|ASYNC(异步)
-func (c *Rdb) FlushAll(mode string) {
-	if mode == "" {
+// return: 始终返回"OK"
+// link: https://redis.io/commands/flushall/
+func (c *Rdb) FlushAll(mode string) (string, error) {
+	if !normal.InArray(mode, []string{"SYNC", "ASYNC"}) {
 		mode = defaultFlushdbMode
 	}
-	c.conn.Do("FLUSHDB", mode)
+	return redis.String(c.conn.Do("FLUSHALL", mode))
 }
 
 // 移除指定的key，同步删除，阻塞式，删除小体量简单数据时推荐优先使用该方式
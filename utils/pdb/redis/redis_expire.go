@@ -4,7 +4,7 @@ package redis
 import "github.com/gomodule/redigo/redis"
 
 type Rexpire struct {
-	conn redis.Conn
+	conn commander
 }
 
 // 设置指定key的生命周期
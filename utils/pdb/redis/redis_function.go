@@ -0,0 +1,142 @@
+// Redis Functions 服务端函数
+// since: 7.0.0
+package redis
+
+import (
+	"github.com/gomodule/redigo/redis"
+	"github.com/perpower/goframe/funcs/normal"
+)
+
+type Rfunction struct {
+	conn commander
+}
+
+// 函数库信息
+type FunctionLibrary struct {
+	Name      string         // 库名
+	Engine    string         // 引擎，目前仅支持LUA
+	Functions []FunctionInfo // 库中定义的函数
+	Code      string         // 库源码，仅WITHCODE时返回
+}
+
+// 函数信息
+type FunctionInfo struct {
+	Name        string   // 函数名
+	Description string   // 描述
+	Flags       []string // 标记，如 no-writes
+}
+
+// FUNCTION LOAD 加载函数库，代码首行需为 #!lua name=<库名>
+// code: string 函数库代码
+// replace: bool 库已存在时是否替换
+// return: string 库名
+// link: https://redis.io/commands/function-load/
+func (f *Rfunction) Load(code string, replace bool) (string, error) {
+	if replace {
+		return redis.String(f.conn.Do("FUNCTION", "LOAD", "REPLACE", code))
+	}
+	return redis.String(f.conn.Do("FUNCTION", "LOAD", code))
+}
+
+// FUNCTION DELETE 删除函数库
+// library: string 库名
+// return: 始终返回"OK"
+// link: https://redis.io/commands/function-delete/
+func (f *Rfunction) Delete(library string) (string, error) {
+	return redis.String(f.conn.Do("FUNCTION", "DELETE", library))
+}
+
+// FUNCTION FLUSH 删除所有函数库
+// mode: string 清空模式  SYNC(同步)|ASYNC(异步)
+// return: 始终返回"OK"
+// link: https://redis.io/commands/function-flush/
+func (f *Rfunction) Flush(mode string) (string, error) {
+	if !normal.InArray(mode, []string{"SYNC", "ASYNC"}) {
+		mode = defaultFlushdbMode
+	}
+	return redis.String(f.conn.Do("FUNCTION", "FLUSH", mode))
+}
+
+// FUNCTION LIST 返回函数库列表
+// pattern: string 库名匹配规则，不指定传空
+// withCode: bool 是否同时返回库源码
+// return: []FunctionLibrary
+// link: https://redis.io/commands/function-list/
+func (f *Rfunction) List(pattern string, withCode bool) ([]FunctionLibrary, error) {
+	args := make([]interface{}, 0)
+	args = append(args, "LIST")
+	if pattern != "" {
+		args = append(args, "LIBRARYNAME", pattern)
+	}
+	if withCode {
+		args = append(args, "WITHCODE")
+	}
+	res, err := redis.Values(f.conn.Do("FUNCTION", args...))
+	if err != nil {
+		return []FunctionLibrary{}, err
+	}
+
+	libs := make([]FunctionLibrary, 0, len(res))
+	for _, v := range res {
+		fields, _ := redis.Values(v, nil)
+		lib := FunctionLibrary{}
+		for i := 0; i+1 < len(fields); i += 2 {
+			name, _ := redis.String(fields[i], nil)
+			switch name {
+			case "library_name":
+				lib.Name, _ = redis.String(fields[i+1], nil)
+			case "engine":
+				lib.Engine, _ = redis.String(fields[i+1], nil)
+			case "library_code":
+				lib.Code, _ = redis.String(fields[i+1], nil)
+			case "functions":
+				lib.Functions = parseFunctions(fields[i+1])
+			}
+		}
+		libs = append(libs, lib)
+	}
+	return libs, err
+}
+
+// 解析 FUNCTION LIST 返回的函数列表
+func parseFunctions(reply interface{}) []FunctionInfo {
+	items, _ := redis.Values(reply, nil)
+	arr := make([]FunctionInfo, 0, len(items))
+	for _, item := range items {
+		fields, _ := redis.Values(item, nil)
+		fn := FunctionInfo{}
+		for i := 0; i+1 < len(fields); i += 2 {
+			name, _ := redis.String(fields[i], nil)
+			switch name {
+			case "name":
+				fn.Name, _ = redis.String(fields[i+1], nil)
+			case "description":
+				fn.Description, _ = redis.String(fields[i+1], nil)
+			case "flags":
+				fn.Flags, _ = redis.Strings(fields[i+1], nil)
+			}
+		}
+		arr = append(arr, fn)
+	}
+	return arr
+}
+
+// FCALL 调用服务端函数
+// function: string 函数名
+// keys: []string 键名数组
+// args: ...interface{} 附加参数
+// return: *Reply 函数返回值，通过Reply的方法转换为所需类型
+// link: https://redis.io/commands/fcall/
+func (f *Rfunction) Fcall(function string, keys []string, args ...interface{}) *Reply {
+	reply := newReply("FCALL")
+	reply.set(f.conn.Do("FCALL", scriptArgs(function, keys, args)...))
+	return reply
+}
+
+// FCALL_RO 以只读方式调用服务端函数，函数需声明 no-writes 标记
+// link: https://redis.io/commands/fcall_ro/
+func (f *Rfunction) FcallRo(function string, keys []string, args ...interface{}) *Reply {
+	reply := newReply("FCALL_RO")
+	reply.set(f.conn.Do("FCALL_RO", scriptArgs(function, keys, args)...))
+	return reply
+}
@@ -9,7 +9,7 @@ import (
 )
 
 type Rgeo struct {
-	conn redis.Conn
+	conn commander
 }
 
 // GEOADD, 将指定的地理空间项（经度、纬度、名称）添加到指定的键
@@ -115,6 +115,9 @@ func (c *Rgeo) GeoPos(key string, members []string) (arr []map[string]string, er
 		args = append(args, v)
 	}
 	res, err := redis.Values(c.conn.Do("GEOPOS", args...))
+	if err != nil {
+		return []map[string]string{}, err
+	}
 	for k, v := range members {
 		poi, _ := redis.Strings(res[k], nil)
 		if len(poi) > 0 {
@@ -2,13 +2,11 @@
 package redis
 
 import (
-	"github.com/perpower/goframe/funcs/convert"
-
 	"github.com/gomodule/redigo/redis"
 )
 
 type Rhash struct {
-	conn redis.Conn
+	conn commander
 }
 
 // HSET,可同时设置多个field=>value
@@ -167,6 +165,9 @@ func (c *Rhash) ScanOnce(key string, cursor int, pattern string, count int) (int
 	args = append(args, key, cursor, "MATCH", pattern, "COUNT", count)
 
 	res, err := redis.Values(c.conn.Do("HSCAN", args...))
+	if err != nil {
+		return 0, [][2]string{}, err
+	}
 	cur, _ := redis.Int(res[0], nil)
 	arr, _ := redis.Strings(res[1], nil)
 
@@ -182,81 +183,79 @@ func (c *Rhash) ScanOnce(key string, cursor int, pattern string, count int) (int
 }
 
 // 根据条件迭代指定key中所有满足条件的键值对
+//
+// Deprecated: 该方法会忽略迭代过程中的错误，请使用 ScanAllE
+func (c *Rhash) ScanAll(key string, pattern string, count int) [][2]string {
+	arr, _ := c.ScanAllE(key, pattern, count)
+	return arr
+}
+
+// 根据条件迭代指定key中所有满足条件的键值对，出错时停止迭代，数据量较多时请使用 ScanIter 逐个读取
 // key: string 键名
 // pattern: string 正则表达式
 // count: int 单次迭代键值对的数量
-// return: arr [][2]string
+// return:
+//
+//	arr: [][2]string 出错前已迭代到的键值对
+//	err: error
+//
 // link：https://redis.io/commands/hscan/
-func (c *Rhash) ScanAll(key string, pattern string, count int) [][2]string {
-	if count < 1 {
-		count = defaultScanNum
-	}
+func (c *Rhash) ScanAllE(key string, pattern string, count int) ([][2]string, error) {
+	arr := make([][2]string, 0)
 	cursor := defaultCursor
-	resArr := make([][2]string, 0)
-	var i int
 	for {
-		args := make([]interface{}, 0)
-		args = append(args, key, cursor, "MATCH", pattern, "COUNT", count)
-
-		res, err := redis.Values(c.conn.Do("HSCAN", args...))
-		if err == nil {
-			curs, _ := redis.Int(res[0], nil)
-			cursor = curs
-			lists, _ := redis.Strings(res[1], nil)
-			if len(lists) > 0 {
-				// 将键值对数组进行拆分
-				for i = 0; i < len(lists); i++ {
-					resArr = append(resArr, [2]string{lists[i], lists[i+1]})
-					i++
-				}
-			}
+		cur, lists, err := c.ScanOnce(key, cursor, pattern, count)
+		if err != nil {
+			return arr, err
 		}
+		arr = append(arr, lists...)
+		cursor = cur
 		if cursor == defaultCursor {
-			break
+			return arr, nil
 		}
 	}
-	return resArr
 }
 
 // 根据条件迭代指定key中所有满足条件的键值对并删除
+//
+// Deprecated: 该方法会忽略迭代及删除过程中的错误，请使用 ScanDelE
+func (c *Rhash) ScanDel(key string, pattern string, count int) (nums int) {
+	nums, _ = c.ScanDelE(key, pattern, count)
+	return nums
+}
+
+// 根据条件迭代指定key中所有满足条件的键值对并删除，出错时停止
 // key: string 键名
 // pattern: string 正则表达式
 // count: int 单次迭代键值对的数量
-// return: nums int 本次迭代删除的键值对数量
+// return:
+//
+//	nums: int 出错前已删除的键值对数量
+//	err: error
+//
 // link：https://redis.io/commands/hscan/
-func (c *Rhash) ScanDel(key string, pattern string, count int) (nums int) {
-	if count < 1 {
-		count = defaultScanNum
-	}
+func (c *Rhash) ScanDelE(key string, pattern string, count int) (int, error) {
+	nums := 0
 	cursor := defaultCursor
-	nums = 0
-	var i int
 	for {
-		args := make([]interface{}, 0)
-		args = append(args, key, cursor, "MATCH", pattern, "COUNT", count)
-
-		res, err := redis.Values(c.conn.Do("HSCAN", args...))
-		if err == nil {
-			curs, _ := redis.Int(res[0], nil)
-			cursor = curs
-			lists, _ := redis.Strings(res[1], nil)
-
-			//执行删除
-			if len(lists) > 0 {
-				delFields := make([]string, 0)
-				for i = 0; i < len(lists); i++ {
-					if i%2 == 0 {
-						delFields = append(delFields, convert.String(lists[i]))
-					}
-				}
-				num, _ := c.Hdel(key, delFields)
-				nums += num
+		cur, lists, err := c.ScanOnce(key, cursor, pattern, count)
+		if err != nil {
+			return nums, err
+		}
+		if len(lists) > 0 {
+			fields := make([]string, 0, len(lists))
+			for _, v := range lists {
+				fields = append(fields, v[0])
+			}
+			num, err := c.Hdel(key, fields)
+			if err != nil {
+				return nums, err
 			}
+			nums += num
 		}
-
+		cursor = cur
 		if cursor == defaultCursor {
-			break
+			return nums, nil
 		}
 	}
-	return nums
 }
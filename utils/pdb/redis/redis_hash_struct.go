@@ -0,0 +1,340 @@
+// 哈希表与结构体互相转换
+// 通过 redis 标签指定字段名，未设置标签时使用结构体字段名，例如：
+//
+//	type User struct {
+//		ID        int64     `redis:"id"`
+//		Name      string    `redis:"name"`
+//		Vip       bool      `redis:"vip,omitempty"`
+//		CreatedAt time.Time `redis:"created_at"`
+//		Password  string    `redis:"-"`
+//	}
+//
+//	client.Hash.HsetStruct("user:1", user)
+//	client.Hash.HgetAllInto("user:1", &user)
+//	client.Hash.HgetAllInto("user:1", &user, "name", "vip") // 只读取部分字段
+//
+// 字段类型转换规则：
+//  1. 字符串、整数、浮点数、布尔值按字面值存储
+//  2. time.Time、ptime.Time 按RFC3339Nano格式存储，读取时通过ptime解析，同时兼容时间戳及常见日期格式
+//  3. 实现了 encoding.TextMarshaler/TextUnmarshaler 的类型使用其文本格式
+//  4. 匿名嵌入的结构体字段展开到同一层，其他结构体、切片、map按JSON存储；
+//     嵌入的未导出类型结构体指针为nil时无法自动分配，读取时跳过其字段
+//  5. 指针字段为nil时存储空字符串，读取时自动分配
+package redis
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/perpower/goframe/funcs/ptime"
+)
+
+// 结构体字段与哈希表字段的对应关系
+type structField struct {
+	name      string
+	index     []int
+	omitempty bool
+}
+
+var (
+	structFieldsCache sync.Map // reflect.Type → []structField
+
+	timeType          = reflect.TypeOf(time.Time{})
+	ptimeType         = reflect.TypeOf(ptime.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	ErrNotStruct = errors.New("redis: 参数需为结构体或结构体指针")
+)
+
+// HsetStruct 将结构体字段写入哈希表
+// key: string 键名
+// v: interface{} 结构体或结构体指针
+// return: reply int 新增的字段数
+// link: https://redis.io/commands/hset/
+func (c *Rhash) HsetStruct(key string, v interface{}) (int, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return 0, ErrNotStruct
+	}
+
+	args := make([]interface{}, 0)
+	args = append(args, key)
+	for _, f := range cachedStructFields(rv.Type()) {
+		fv, err := rv.FieldByIndexErr(f.index)
+		if err != nil {
+			// 嵌入的结构体指针为nil
+			continue
+		}
+		if f.omitempty && fv.IsZero() {
+			continue
+		}
+		s, err := formatField(fv)
+		if err != nil {
+			return 0, fmt.Errorf("redis: 字段 %s 转换失败: %w", f.name, err)
+		}
+		args = append(args, f.name, s)
+	}
+	if len(args) == 1 {
+		return 0, nil
+	}
+	return redis.Int(c.conn.Do("HSET", args...))
+}
+
+// HgetAllInto 读取哈希表并写入结构体
+// key: string 键名
+// dst: interface{} 结构体指针
+// fields: ...string 只读取指定的字段(HMGET)，字段名与标签一致，不指定时读取全部字段(HGETALL)
+// return: key不存在或指定字段均不存在时返回 redis.ErrNil
+// link: https://redis.io/commands/hgetall/
+func (c *Rhash) HgetAllInto(key string, dst interface{}, fields ...string) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrNotStruct
+	}
+	rv = rv.Elem()
+
+	byName := map[string]structField{}
+	for _, f := range cachedStructFields(rv.Type()) {
+		byName[f.name] = f
+	}
+
+	values := map[string]string{}
+	if len(fields) == 0 {
+		m, err := c.HgetAll(key)
+		if err != nil {
+			return err
+		}
+		values = m
+	} else {
+		for _, name := range fields {
+			if _, ok := byName[name]; !ok {
+				return fmt.Errorf("redis: 结构体中不存在字段 %s", name)
+			}
+		}
+		args := make([]interface{}, 0, len(fields)+1)
+		args = append(args, key)
+		for _, name := range fields {
+			args = append(args, name)
+		}
+		res, err := redis.Values(c.conn.Do("HMGET", args...))
+		if err != nil {
+			return err
+		}
+		for i, v := range res {
+			if v != nil && i < len(fields) {
+				values[fields[i]], _ = redis.String(v, nil)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return redis.ErrNil
+	}
+
+	for name, s := range values {
+		f, ok := byName[name]
+		if !ok {
+			continue
+		}
+		fv, ok := fieldByIndexAlloc(rv, f.index)
+		if !ok {
+			continue
+		}
+		if err := parseField(fv, s); err != nil {
+			return fmt.Errorf("redis: 字段 %s 转换失败: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// 解析结构体字段，结果按类型缓存
+func cachedStructFields(t reflect.Type) []structField {
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.([]structField)
+	}
+	fields := parseStructFields(t, nil)
+	structFieldsCache.Store(t, fields)
+	return fields
+}
+
+func parseStructFields(t reflect.Type, parent []int) []structField {
+	fields := make([]structField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("redis")
+		if tag == "-" {
+			continue
+		}
+		index := append(append([]int{}, parent...), i)
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		// 匿名嵌入且未设置标签的结构体展开到同一层
+		if sf.Anonymous && tag == "" && ft.Kind() == reflect.Struct && !isScalarStruct(ft) {
+			fields = append(fields, parseStructFields(ft, index)...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, structField{
+			name:      name,
+			index:     index,
+			omitempty: opts == "omitempty",
+		})
+	}
+	return fields
+}
+
+// 按单个值存储的结构体类型
+func isScalarStruct(t reflect.Type) bool {
+	return t == timeType || t == ptimeType || reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+// 按索引取字段，途经的nil结构体指针自动分配
+// return: 途经未导出类型的nil嵌入指针(无法通过反射分配)时返回false
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return v, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// 字段值转换为字符串
+func formatField(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	switch v.Type() {
+	case timeType:
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	case ptimeType:
+		return v.Interface().(ptime.Time).Time.Format(time.RFC3339Nano), nil
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+	if v.CanAddr() {
+		if m, ok := v.Addr().Interface().(encoding.TextMarshaler); ok {
+			b, err := m.MarshalText()
+			return string(b), err
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+	b, err := json.Marshal(v.Interface())
+	return string(b), err
+}
+
+// 字符串转换为字段值
+func parseField(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if s == "" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	switch v.Type() {
+	case timeType, ptimeType:
+		t, err := ptime.StrToTime(s)
+		if err != nil {
+			return err
+		}
+		if v.Type() == timeType {
+			v.Set(reflect.ValueOf(t.Time))
+		} else {
+			v.Set(reflect.ValueOf(*t))
+		}
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s))
+			return nil
+		}
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package redis
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/perpower/goframe/utils/pdb/redis/redistest"
+)
+
+type hashBase struct {
+	ID        int64     `redis:"id"`
+	CreatedAt time.Time `redis:"created_at"`
+}
+
+type HashMeta struct {
+	Source string `redis:"source"`
+}
+
+type hashOwner struct {
+	Owner string `redis:"owner"`
+}
+
+type hashUser struct {
+	hashBase
+	*HashMeta
+	Name     string         `redis:"name"`
+	Vip      bool           `redis:"vip,omitempty"`
+	Score    float64        `redis:"score"`
+	Age      *int           `redis:"age"`
+	Nick     *string        `redis:"nick"`
+	IP       net.IP         `redis:"ip"`
+	Tags     []string       `redis:"tags"`
+	Extra    map[string]int `redis:"extra,omitempty"`
+	Password string         `redis:"-"`
+	Untagged uint8
+	hidden   string
+}
+
+func TestHashStruct(t *testing.T) {
+	s := redistest.NewServer(t)
+	client, err := New(Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	h := client.Hash
+
+	created := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	age := 30
+	in := hashUser{
+		hashBase: hashBase{ID: 1, CreatedAt: created},
+		HashMeta: &HashMeta{Source: "app"},
+		Name:     "alice",
+		Score:    1.5,
+		Age:      &age,
+		IP:       net.ParseIP("10.0.0.1"),
+		Tags:     []string{"a", "b"},
+		Password: "secret",
+		Untagged: 7,
+		hidden:   "x",
+	}
+	if n, err := h.HsetStruct("user:1", &in); err != nil || n != 10 {
+		t.Fatalf("HsetStruct = %d, %v, want 10 fields", n, err)
+	}
+	want := map[string]string{
+		"id":         "1",
+		"created_at": "2024-05-06T07:08:09.123456789Z",
+		"source":     "app",
+		"name":       "alice",
+		"score":      "1.5",
+		"age":        "30",
+		"nick":       "",
+		"ip":         "10.0.0.1",
+		"tags":       `["a","b"]`,
+		"Untagged":   "7",
+	}
+	got, err := h.HgetAll("user:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// omitempty的零值及redis:"-"、未导出的字段不写入
+	if len(got) != len(want) {
+		t.Fatalf("stored fields = %v", got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("field %s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	var out hashUser
+	if err := h.HgetAllInto("user:1", &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.ID != 1 || !out.CreatedAt.Equal(created) || out.Name != "alice" || out.Score != 1.5 || out.Untagged != 7 {
+		t.Fatalf("HgetAllInto = %+v", out)
+	}
+	// 嵌入的结构体指针自动分配，空字符串读取为nil指针
+	if out.HashMeta == nil || out.Source != "app" {
+		t.Fatalf("embedded pointer = %+v", out.HashMeta)
+	}
+	if out.Age == nil || *out.Age != 30 || out.Nick != nil {
+		t.Fatalf("pointers = %v, %v", out.Age, out.Nick)
+	}
+	if !out.IP.Equal(in.IP) || len(out.Tags) != 2 || out.Tags[1] != "b" || out.Password != "" {
+		t.Fatalf("HgetAllInto = %+v", out)
+	}
+
+	// omitempty字段非零值时写入，nil的嵌入指针跳过
+	vip := hashUser{Name: "bob", Vip: true, Extra: map[string]int{"x": 1}}
+	if _, err := h.HsetStruct("user:2", vip); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := h.Hget("user:2", "vip"); v != "true" {
+		t.Fatalf("vip = %q", v)
+	}
+	if v, _ := h.Hget("user:2", "extra"); v != `{"x":1}` {
+		t.Fatalf("extra = %q", v)
+	}
+	if exists, _ := h.Hexists("user:2", "source"); exists != 0 {
+		t.Fatal("fields of a nil embedded pointer must not be written")
+	}
+
+	// 未导出类型的嵌入指针无法分配，为nil时跳过，已分配时正常写入
+	type owned struct {
+		*hashOwner
+		Name string `redis:"name"`
+	}
+	if _, err := h.HsetStruct("item:1", owned{hashOwner: &hashOwner{Owner: "alice"}, Name: "box"}); err != nil {
+		t.Fatal(err)
+	}
+	var item owned
+	if err := h.HgetAllInto("item:1", &item); err != nil || item.Name != "box" || item.hashOwner != nil {
+		t.Fatalf("HgetAllInto with a nil unexported embedded pointer = %+v, %v", item, err)
+	}
+	item = owned{hashOwner: &hashOwner{}}
+	if err := h.HgetAllInto("item:1", &item); err != nil || item.Owner != "alice" {
+		t.Fatalf("HgetAllInto with an allocated unexported embedded pointer = %+v, %v", item, err)
+	}
+}
+
+func TestHashStructPartial(t *testing.T) {
+	s := redistest.NewServer(t)
+	client, err := New(Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	h := client.Hash
+
+	s.Do("HSET", "user:1", "id", "1", "name", "alice", "vip", "1", "created_at", "1714979289", "score", "2")
+	s.ResetCommands()
+
+	// 只读取指定字段(HMGET)，其余字段保持原值，不存在的字段忽略
+	out := hashUser{Score: 9}
+	if err := h.HgetAllInto("user:1", &out, "name", "vip", "created_at", "age"); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "alice" || !out.Vip || out.ID != 0 || out.Score != 9 || out.Age != nil {
+		t.Fatalf("HgetAllInto with fields = %+v", out)
+	}
+	// 时间兼容时间戳格式
+	if out.CreatedAt.Unix() != 1714979289 {
+		t.Fatalf("created_at = %v", out.CreatedAt)
+	}
+	if s.Commands() != 1 {
+		t.Fatalf("commands = %d, want a single HMGET", s.Commands())
+	}
+
+	if err := h.HgetAllInto("user:1", &out, "age", "nick"); err != redis.ErrNil {
+		t.Fatalf("missing fields = %v, want redis.ErrNil", err)
+	}
+	if err := h.HgetAllInto("missing", &out); err != redis.ErrNil {
+		t.Fatalf("missing key = %v, want redis.ErrNil", err)
+	}
+	if err := h.HgetAllInto("user:1", &out, "Password"); err == nil {
+		t.Fatal("unknown field should fail")
+	}
+	if err := h.HgetAllInto("user:1", out); err != ErrNotStruct {
+		t.Fatalf("non-pointer dst = %v, want ErrNotStruct", err)
+	}
+	if _, err := h.HsetStruct("user:1", "x"); err != ErrNotStruct {
+		t.Fatalf("HsetStruct of a string = %v, want ErrNotStruct", err)
+	}
+
+	s.Do("HSET", "user:1", "score", "abc")
+	if err := h.HgetAllInto("user:1", &out, "score"); err == nil {
+		t.Fatal("an invalid number should fail to parse")
+	}
+}
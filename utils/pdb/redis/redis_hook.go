@@ -0,0 +1,144 @@
+// Redis 命令钩子
+// 通过 AddHook 注册的钩子在每条命令执行前后被调用，各类型操作对象、Do、Lua脚本均会经过钩子，
+// 管道及事务整体作为一次调用，命令名分别为 PIPELINE、MULTI。钩子对同一客户端的所有视图(WithContext、Namespace、Pin等)生效，例如：
+//
+//	client.AddHook(
+//		redis.NewSlowLogHook(logger, redis.SlowLogOptions{Threshold: 50 * time.Millisecond}),
+//		redis.NewMetricsHook(redis.MetricsOptions{Name: "cache"}),
+//	)
+package redis
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Hook 命令钩子，Before返回的context会传递给命令执行及After，可用于链路追踪
+// 钩子在命令执行的goroutine中同步调用，不要在钩子中执行耗时操作
+type Hook interface {
+	Before(ctx context.Context, cmd *HookCommand) context.Context
+	After(ctx context.Context, cmd *HookCommand)
+}
+
+// HookCommand 钩子中的命令信息
+type HookCommand struct {
+	Name     string        // 命令名(大写)，管道为 PIPELINE，事务为 MULTI
+	Key      string        // 第一个key(含命名空间前缀)，无key命令为空
+	Args     []interface{} // 命令参数，管道及事务为nil
+	Batch    []string      // 管道及事务中各命令的命令名
+	Start    time.Time     // 开始执行时间
+	Duration time.Duration // 执行耗时，After中有效
+	Err      error         // 执行错误，After中有效
+}
+
+// HookFunc 只在命令执行之后调用的钩子函数
+type HookFunc func(ctx context.Context, cmd *HookCommand)
+
+func (f HookFunc) Before(ctx context.Context, cmd *HookCommand) context.Context {
+	return ctx
+}
+
+func (f HookFunc) After(ctx context.Context, cmd *HookCommand) {
+	f(ctx, cmd)
+}
+
+// hookSet 客户端注册的钩子，所有视图共用
+type hookSet struct {
+	mu    sync.Mutex
+	hooks atomic.Value // []Hook，写时复制
+}
+
+// AddHook 注册命令钩子，按注册顺序调用Before，按相反顺序调用After
+func (c *Client) AddHook(hooks ...Hook) {
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+	old, _ := c.hooks.hooks.Load().([]Hook)
+	arr := make([]Hook, 0, len(old)+len(hooks))
+	arr = append(arr, old...)
+	for _, h := range hooks {
+		if h != nil {
+			arr = append(arr, h)
+		}
+	}
+	c.hooks.hooks.Store(arr)
+}
+
+func (s *hookSet) list() []Hook {
+	if s == nil {
+		return nil
+	}
+	arr, _ := s.hooks.Load().([]Hook)
+	return arr
+}
+
+// 执行单条命令，前后调用钩子
+func (s *hookSet) do(ctx context.Context, commandName string, args []interface{}, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	hooks := s.list()
+	if len(hooks) == 0 {
+		return fn(ctx)
+	}
+	upper := strings.ToUpper(commandName)
+	cmd := &HookCommand{
+		Name: upper,
+		Key:  hookKey(upper, args),
+		Args: args,
+	}
+	var reply interface{}
+	s.run(ctx, hooks, cmd, func(ctx context.Context) error {
+		var err error
+		reply, err = fn(ctx)
+		return err
+	})
+	return reply, cmd.Err
+}
+
+// 执行管道或事务，整体调用一次钩子
+func (s *hookSet) batch(ctx context.Context, name string, cmds []*queuedCmd, fn func(ctx context.Context) error) error {
+	hooks := s.list()
+	if len(hooks) == 0 {
+		return fn(ctx)
+	}
+	cmd := &HookCommand{
+		Name:  name,
+		Batch: make([]string, 0, len(cmds)),
+	}
+	for _, q := range cmds {
+		upper := strings.ToUpper(q.commandName)
+		if cmd.Key == "" {
+			cmd.Key = hookKey(upper, q.args)
+		}
+		cmd.Batch = append(cmd.Batch, upper)
+	}
+	s.run(ctx, hooks, cmd, fn)
+	return cmd.Err
+}
+
+func (s *hookSet) run(ctx context.Context, hooks []Hook, cmd *HookCommand, fn func(ctx context.Context) error) {
+	for _, h := range hooks {
+		ctx = h.Before(ctx, cmd)
+	}
+	cmd.Start = time.Now()
+	cmd.Err = fn(ctx)
+	cmd.Duration = time.Since(cmd.Start)
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i].After(ctx, cmd)
+	}
+}
+
+// 钩子中展示的key，无key命令返回空
+func hookKey(upper string, args []interface{}) string {
+	if _, ok := keylessCommands[upper]; ok {
+		return ""
+	}
+	if _, ok := broadcastCommands[upper]; ok {
+		return ""
+	}
+	if _, ok := namespaceSkipCommands[upper]; ok {
+		return ""
+	}
+	key, _ := commandKey(upper, args)
+	return key
+}
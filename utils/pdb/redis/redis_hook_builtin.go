@@ -0,0 +1,165 @@
+// 内置命令钩子：慢命令日志、命令指标统计
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/perpower/goframe/utils/plog"
+	"github.com/perpower/goframe/utils/pmetrics"
+)
+
+// SlowLogOptions 慢命令日志选项
+type SlowLogOptions struct {
+	Threshold time.Duration // 耗时超过该值的命令记录Warn日志
+	LogErrors bool          // 是否同时记录执行失败的命令(Error日志)
+	Cate      string        // 日志分类，elasticSearch平台下为索引名
+	MaxArgs   int           // 日志中最多记录的参数个数，超出部分省略
+	MaxArgLen int           // 单个参数最多记录的字节数，超出部分截断
+}
+
+// MetricsOptions 命令指标选项
+type MetricsOptions struct {
+	Registry *pmetrics.Registry // 指标注册表，默认 pmetrics.Default
+	Name     string             // client标签的值，同一进程中有多个客户端时用于区分
+	Buckets  []float64          // 耗时直方图的桶，单位秒，默认 pmetrics.DefaultBuckets
+}
+
+var (
+	defaultSlowLogOptions = SlowLogOptions{
+		Threshold: 100 * time.Millisecond,
+		Cate:      "redis",
+		MaxArgs:   8,
+		MaxArgLen: 64,
+	}
+	defaultMetricsOptions = MetricsOptions{
+		Name: "default",
+	}
+)
+
+type slowLogHook struct {
+	logger plog.StandLog
+	opts   SlowLogOptions
+}
+
+// NewSlowLogHook 创建慢命令日志钩子，耗时超过阈值的命令通过plog记录
+// logger: plog.StandLog 日志服务，如 plog.InitLogger 返回的 *plog.Output
+func NewSlowLogHook(logger plog.StandLog, opts ...SlowLogOptions) Hook {
+	opt := defaultSlowLogOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+		if opt.Threshold <= 0 {
+			opt.Threshold = defaultSlowLogOptions.Threshold
+		}
+		if opt.Cate == "" {
+			opt.Cate = defaultSlowLogOptions.Cate
+		}
+		if opt.MaxArgs <= 0 {
+			opt.MaxArgs = defaultSlowLogOptions.MaxArgs
+		}
+		if opt.MaxArgLen <= 0 {
+			opt.MaxArgLen = defaultSlowLogOptions.MaxArgLen
+		}
+	}
+	return &slowLogHook{
+		logger: logger,
+		opts:   opt,
+	}
+}
+
+func (h *slowLogHook) Before(ctx context.Context, cmd *HookCommand) context.Context {
+	return ctx
+}
+
+func (h *slowLogHook) After(ctx context.Context, cmd *HookCommand) {
+	if h.logger == nil {
+		return
+	}
+	if cmd.Err != nil && h.opts.LogErrors {
+		h.logger.Error(h.opts.Cate, fmt.Sprintf("redis命令执行失败: %s %s", cmd.Name, cmd.Key), h.fields(cmd)...)
+		return
+	}
+	if cmd.Duration >= h.opts.Threshold {
+		h.logger.Warn(h.opts.Cate, fmt.Sprintf("redis慢命令: %s %s 耗时 %s", cmd.Name, cmd.Key, cmd.Duration), h.fields(cmd)...)
+	}
+}
+
+func (h *slowLogHook) fields(cmd *HookCommand) []plog.ExtendFields {
+	fields := []plog.ExtendFields{
+		{Key: "command", Value: cmd.Name},
+		{Key: "key", Value: cmd.Key},
+		{Key: "durationMs", Value: float64(cmd.Duration.Microseconds()) / 1000},
+	}
+	if len(cmd.Batch) > 0 {
+		fields = append(fields, plog.ExtendFields{Key: "batch", Value: cmd.Batch})
+	} else {
+		fields = append(fields, plog.ExtendFields{Key: "args", Value: h.args(cmd.Args)})
+	}
+	if cmd.Err != nil {
+		fields = append(fields, plog.ExtendFields{Key: "error", Value: cmd.Err.Error()})
+	}
+	return fields
+}
+
+// 截断后的参数，避免大value写入日志
+func (h *slowLogHook) args(args []interface{}) string {
+	arr := make([]string, 0, h.opts.MaxArgs+1)
+	for i, arg := range args {
+		if i >= h.opts.MaxArgs {
+			arr = append(arr, fmt.Sprintf("...(%d more)", len(args)-i))
+			break
+		}
+		s := redisString(arg)
+		if len(s) > h.opts.MaxArgLen {
+			s = s[:h.opts.MaxArgLen] + "..."
+		}
+		arr = append(arr, s)
+	}
+	return strings.Join(arr, " ")
+}
+
+type metricsHook struct {
+	name     string
+	total    *pmetrics.CounterVec
+	duration *pmetrics.HistogramVec
+}
+
+// NewMetricsHook 创建命令指标钩子，按客户端及命令名统计：
+//
+//	redis_commands_total{client, command, status}  命令执行次数，status取值 ok | error
+//	redis_command_duration_seconds{client, command} 命令耗时直方图
+//
+// 管道及事务的命令名分别为 PIPELINE、MULTI
+func NewMetricsHook(opts ...MetricsOptions) Hook {
+	opt := defaultMetricsOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+		if opt.Name == "" {
+			opt.Name = defaultMetricsOptions.Name
+		}
+	}
+	registry := opt.Registry
+	if registry == nil {
+		registry = pmetrics.Default
+	}
+	return &metricsHook{
+		name:     opt.Name,
+		total:    registry.Counter("redis_commands_total", "Redis命令执行次数", "client", "command", "status"),
+		duration: registry.Histogram("redis_command_duration_seconds", "Redis命令执行耗时(秒)", opt.Buckets, "client", "command"),
+	}
+}
+
+func (h *metricsHook) Before(ctx context.Context, cmd *HookCommand) context.Context {
+	return ctx
+}
+
+func (h *metricsHook) After(ctx context.Context, cmd *HookCommand) {
+	status := "ok"
+	if cmd.Err != nil {
+		status = "error"
+	}
+	h.total.With(h.name, cmd.Name, status).Inc()
+	h.duration.With(h.name, cmd.Name).Observe(cmd.Duration.Seconds())
+}
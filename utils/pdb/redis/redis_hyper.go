@@ -4,7 +4,7 @@ package redis
 import "github.com/gomodule/redigo/redis"
 
 type Rhyper struct {
-	conn redis.Conn
+	conn commander
 }
 
 // PFADD 将任意数量的元素添加到指定的 HyperLogLog 里面
@@ -8,7 +8,7 @@ import (
 )
 
 type Rlist struct {
-	conn redis.Conn
+	conn commander
 }
 
 // LINDEX, 返回指定索引位的元素
@@ -0,0 +1,275 @@
+// Redis 键名命名空间
+// Namespace 返回的客户端视图会在发送命令前为所有key加上前缀，并在SCAN、KEYS、BLPOP、LMPOP、XREAD等
+// 返回key名的命令回复中去掉前缀，调用方始终使用不带前缀的key，例如：
+//
+//	tenant := client.Namespace("order:").Namespace("tenant1:")
+//	tenant.String.Set("counter", "1", "", "", 0)         // 实际key为 order:tenant1:counter
+//	tenant.Set.SinterStore([]string{"a", "b"}, "dst")    // a、b、dst 均加前缀
+//	keys, _ := tenant.Scan.ScanAllE("user:*", 100, "")   // 只迭代 order:tenant1:user:*，返回 user:xxx
+//
+// 特别说明：
+//  1. 命名空间作用于各类型操作对象、Do、管道、事务、Lua脚本及Function的KEYS，频道名(PUBLISH/SUBSCRIBE)不加前缀
+//  2. Lua脚本中拼接生成的key不会加前缀，脚本用到的key需全部通过KEYS传入
+//  3. 集群模式下前缀会参与槽位计算，前缀中不要包含 {}，以免所有key落在同一槽位
+package redis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// namespace 键名前缀，为空表示不加前缀
+type namespace string
+
+var (
+	// 不含key或参数不是key的命令，不做处理
+	namespaceSkipCommands = map[string]struct{}{
+		"SELECT": {}, "MULTI": {}, "EXEC": {}, "DISCARD": {}, "UNWATCH": {}, "SPUBLISH": {},
+		"AUTH": {}, "HELLO": {}, "QUIT": {}, "RESET": {}, "READONLY": {}, "READWRITE": {}, "ASKING": {},
+		"SUBSCRIBE": {}, "UNSUBSCRIBE": {}, "PSUBSCRIBE": {}, "PUNSUBSCRIBE": {}, "SSUBSCRIBE": {}, "SUNSUBSCRIBE": {},
+		"DEBUG": {}, "ACL": {}, "MODULE": {}, "MONITOR": {}, "SWAPDB": {}, "SHUTDOWN": {}, "REPLICAOF": {}, "SLAVEOF": {},
+	}
+	// 参数全部为key的命令
+	namespaceAllKeys = map[string]struct{}{
+		"DEL": {}, "UNLINK": {}, "EXISTS": {}, "TOUCH": {}, "MGET": {}, "WATCH": {},
+		"SDIFF": {}, "SINTER": {}, "SUNION": {}, "SDIFFSTORE": {}, "SINTERSTORE": {}, "SUNIONSTORE": {},
+		"PFCOUNT": {}, "PFMERGE": {},
+	}
+	// 前两个参数为key的命令
+	namespaceTwoKeys = map[string]struct{}{
+		"RENAME": {}, "RENAMENX": {}, "COPY": {}, "SMOVE": {}, "LMOVE": {}, "BLMOVE": {},
+		"RPOPLPUSH": {}, "BRPOPLPUSH": {}, "ZRANGESTORE": {}, "GEOSEARCHSTORE": {}, "LCS": {},
+	}
+)
+
+// Namespace 返回指定键名前缀的客户端视图，连接池与配置共用，可多次调用叠加前缀
+// prefix: string 键名前缀，如 "order:"、"tenant1:"
+func (c *Client) Namespace(prefix string) *Client {
+	return c.derive(func(view *Client) {
+		view.ns = c.ns + namespace(prefix)
+	})
+}
+
+// Prefix 返回当前视图的键名前缀
+func (c *Client) Prefix() string {
+	return string(c.ns)
+}
+
+// 为命令参数中的key加上前缀，返回新的参数切片
+func (n namespace) args(commandName string, args []interface{}) []interface{} {
+	if n == "" || len(args) == 0 {
+		return args
+	}
+	upper := strings.ToUpper(commandName)
+	res := append(make([]interface{}, 0, len(args)+2), args...)
+	switch upper {
+	case "SCAN":
+		return n.match(res, 1)
+	case "KEYS":
+		res[0] = n.pattern(res[0])
+		return res
+	case "BITOP":
+		n.keys(res, 1, len(res))
+	case "MSET", "MSETNX":
+		for i := 0; i < len(res); i += 2 {
+			res[i] = n.key(res[i])
+		}
+	case "BLPOP", "BRPOP", "BZPOPMIN", "BZPOPMAX":
+		// 最后一个参数为timeout
+		n.keys(res, 0, len(res)-1)
+	case "EVAL", "EVALSHA", "EVAL_RO", "EVALSHA_RO", "FCALL", "FCALL_RO",
+		"ZUNION", "ZINTER", "ZDIFF", "ZINTERCARD", "SINTERCARD", "LMPOP", "ZMPOP":
+		// [script] numkeys key [key ...]
+		pos := 0
+		if strings.HasPrefix(upper, "EVAL") || strings.HasPrefix(upper, "FCALL") {
+			pos = 1
+		}
+		n.numkeys(res, pos)
+	case "ZUNIONSTORE", "ZINTERSTORE", "ZDIFFSTORE":
+		res[0] = n.key(res[0])
+		n.numkeys(res, 1)
+	case "BLMPOP", "BZMPOP":
+		n.numkeys(res, 1)
+	case "OBJECT", "MEMORY", "XINFO", "XGROUP":
+		// 子命令之后为key
+		n.keys(res, 1, 2)
+	case "XREAD", "XREADGROUP":
+		// STREAMS key [key ...] id [id ...]
+		for i, arg := range res {
+			if s, ok := arg.(string); ok && strings.EqualFold(s, "STREAMS") {
+				rest := len(res) - i - 1
+				n.keys(res, i+1, i+1+rest/2)
+				break
+			}
+		}
+	default:
+		if _, ok := keylessCommands[upper]; ok {
+			return args
+		}
+		if _, ok := broadcastCommands[upper]; ok {
+			return args
+		}
+		if _, ok := namespaceSkipCommands[upper]; ok {
+			return args
+		}
+		if _, ok := namespaceAllKeys[upper]; ok {
+			n.keys(res, 0, len(res))
+		} else if _, ok := namespaceTwoKeys[upper]; ok {
+			n.keys(res, 0, 2)
+		} else {
+			res[0] = n.key(res[0])
+		}
+	}
+	return res
+}
+
+// 去掉回复中key名的前缀，只处理返回key名的命令
+func (n namespace) reply(commandName string, reply interface{}) interface{} {
+	if n == "" || reply == nil {
+		return reply
+	}
+	switch strings.ToUpper(commandName) {
+	case "SCAN":
+		// [cursor, [key ...]]
+		if values, ok := reply.([]interface{}); ok && len(values) == 2 {
+			return []interface{}{values[0], n.stripAll(values[1])}
+		}
+	case "KEYS":
+		return n.stripAll(reply)
+	case "RANDOMKEY":
+		return n.strip(reply)
+	case "BLPOP", "BRPOP", "BZPOPMIN", "BZPOPMAX", "LMPOP", "ZMPOP", "BLMPOP", "BZMPOP":
+		// [key, ...]
+		if values, ok := reply.([]interface{}); ok && len(values) > 0 {
+			res := append([]interface{}{}, values...)
+			res[0] = n.strip(res[0])
+			return res
+		}
+	case "XREAD", "XREADGROUP":
+		// [[key, entries], ...]
+		if values, ok := reply.([]interface{}); ok {
+			res := make([]interface{}, 0, len(values))
+			for _, v := range values {
+				if stream, ok := v.([]interface{}); ok && len(stream) > 0 {
+					stream = append([]interface{}{}, stream...)
+					stream[0] = n.strip(stream[0])
+					v = stream
+				}
+				res = append(res, v)
+			}
+			return res
+		}
+	}
+	return reply
+}
+
+// 为排队的命令加上前缀，管道、事务发送前调用
+func (n namespace) queue(cmds []*queuedCmd) {
+	if n == "" {
+		return
+	}
+	for _, cmd := range cmds {
+		cmd.args = n.args(cmd.commandName, cmd.args)
+	}
+}
+
+// 去掉排队命令回复中的前缀，管道、事务执行后调用
+func (n namespace) replies(cmds []*queuedCmd) {
+	if n == "" {
+		return
+	}
+	for _, cmd := range cmds {
+		if cmd.reply.err == nil {
+			cmd.reply.value = n.reply(cmd.commandName, cmd.reply.value)
+		}
+	}
+}
+
+func (n namespace) key(arg interface{}) interface{} {
+	switch v := arg.(type) {
+	case string:
+		return string(n) + v
+	case []byte:
+		return append([]byte(n), v...)
+	default:
+		return string(n) + redisString(v)
+	}
+}
+
+// 为 [start, end) 范围内的参数加上前缀
+func (n namespace) keys(args []interface{}, start, end int) {
+	for i := start; i < end && i < len(args); i++ {
+		args[i] = n.key(args[i])
+	}
+}
+
+// 为 numkeys key [key ...] 形式中的key加上前缀，pos为numkeys所在位置
+func (n namespace) numkeys(args []interface{}, pos int) {
+	if pos >= len(args) {
+		return
+	}
+	num, err := intArg(args[pos])
+	if err != nil {
+		return
+	}
+	n.keys(args, pos+1, pos+1+num)
+}
+
+// 为SCAN的MATCH参数加上前缀，未指定MATCH时追加，pos为游标之后的第一个选项位置
+func (n namespace) match(args []interface{}, pos int) []interface{} {
+	for i := pos; i+1 < len(args); i += 2 {
+		if s, ok := args[i].(string); ok && strings.EqualFold(s, "MATCH") {
+			args[i+1] = n.pattern(args[i+1])
+			return args
+		}
+	}
+	return append(args, "MATCH", n.pattern("*"))
+}
+
+// 将前缀中的通配符转义后拼接到匹配模式之前
+func (n namespace) pattern(arg interface{}) interface{} {
+	var b strings.Builder
+	for _, r := range string(n) {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String() + redisString(arg)
+}
+
+func (n namespace) strip(v interface{}) interface{} {
+	switch s := v.(type) {
+	case []byte:
+		if strings.HasPrefix(string(s), string(n)) {
+			return s[len(n):]
+		}
+	case string:
+		return strings.TrimPrefix(s, string(n))
+	}
+	return v
+}
+
+func (n namespace) stripAll(v interface{}) interface{} {
+	values, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+	res := make([]interface{}, 0, len(values))
+	for _, item := range values {
+		res = append(res, n.strip(item))
+	}
+	return res
+}
+
+func redisString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	default:
+		return fmt.Sprint(s)
+	}
+}
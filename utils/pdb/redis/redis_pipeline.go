@@ -0,0 +1,235 @@
+// Redis pipeline 管道批量命令
+// 通过管道上的各类型操作对象添加命令，命令不会立即执行，而是在Exec时于同一连接上一次性发送并依次读取回复，
+// 通过 Queue 排队的命令在Exec之后得到该方法的类型化结果(见 redis_cmd.go)，例如：
+//
+//	p := client.Pipeline()
+//	p.Hash.Hset("user:1", [][2]string{{"name", "tom"}})
+//	p.Expire.Expire("user:1", 3600, "")
+//	counter := redis.Queue(p, func() (string, error) { return p.String.Get("counter") })
+//	if _, err := p.Exec(); err != nil {
+//		return err
+//	}
+//	n, err := counter.Result()
+//
+// 直接调用管道上的方法时统一返回 ErrQueued，原始回复可通过 Last() 或 Exec() 返回的 Reply 获取
+// 特别说明：Pipeline 对象非并发安全，不要在多个goroutine中共用
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+type Pipeline struct {
+	queued
+	client *Client
+}
+
+// queued 绑定到命令队列的各类型操作对象，管道与事务共用
+type queued struct {
+	queue  *cmdQueue
+	Db     *Rdb
+	String *Rstring
+	Expire *Rexpire
+	Hash   *Rhash
+	Set    *Rset
+	Zset   *Rzset
+	List   *Rlist
+	Geo    *Rgeo
+	Hyper  *Rhyper
+	Bit    *Rbit
+	Stream *Rstream
+}
+
+// cmdQueue 命令队列，实现commander接口，供各类型操作对象排队命令
+type cmdQueue struct {
+	cmds      []*queuedCmd
+	decoders  []func() // Queue登记的类型化结果解码函数，执行之后依次调用
+	replay    []*Reply // 解码时依次返回给方法的回复
+	replaying bool
+}
+
+type queuedCmd struct {
+	commandName string
+	args        []interface{}
+	reply       *Reply
+}
+
+var ErrNoConnector = errors.New("redis: 当前客户端不支持批量发送命令")
+
+// Pipeline 创建一个管道，管道执行时使用客户端当前视图的context及超时设置
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{
+		queued: newQueued(),
+		client: c,
+	}
+}
+
+func newQueued() queued {
+	q := &cmdQueue{}
+	return queued{
+		queue:  q,
+		Db:     &Rdb{conn: q},
+		String: &Rstring{conn: q},
+		Expire: &Rexpire{conn: q},
+		Hash:   &Rhash{conn: q},
+		Set:    &Rset{conn: q},
+		Zset:   &Rzset{conn: q},
+		List:   &Rlist{conn: q},
+		Geo:    &Rgeo{conn: q},
+		Hyper:  &Rhyper{conn: q},
+		Bit:    &Rbit{conn: q},
+		Stream: &Rstream{conn: q},
+	}
+}
+
+func (q *cmdQueue) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if q.replaying {
+		// 解码阶段，按顺序返回该方法排队的命令的回复
+		if len(q.replay) == 0 {
+			return nil, ErrNotExecuted
+		}
+		reply := q.replay[0]
+		q.replay = q.replay[1:]
+		return reply.value, reply.err
+	}
+	q.push(commandName, args...)
+	return nil, ErrQueued
+}
+
+// 取出待执行的命令及解码函数，并清空队列
+func (q *cmdQueue) take() ([]*queuedCmd, []func()) {
+	cmds, decoders := q.cmds, q.decoders
+	q.cmds, q.decoders = nil, nil
+	return cmds, decoders
+}
+
+func (q *cmdQueue) push(commandName string, args ...interface{}) *Reply {
+	cmd := &queuedCmd{
+		commandName: commandName,
+		args:        args,
+		reply:       newReply(commandName),
+	}
+	q.cmds = append(q.cmds, cmd)
+	return cmd.reply
+}
+
+// Do 向队列中添加任意命令
+// return: *Reply 该命令的执行结果，执行之后可读取
+func (q *queued) Do(commandName string, args ...interface{}) *Reply {
+	return q.queue.push(commandName, args...)
+}
+
+// Last 返回最近一条加入队列的命令结果，用于在调用类型化方法之后获取其Reply
+func (q *queued) Last() *Reply {
+	if len(q.queue.cmds) == 0 {
+		return nil
+	}
+	return q.queue.cmds[len(q.queue.cmds)-1].reply
+}
+
+// Len 返回队列中待执行的命令数
+func (q *queued) Len() int {
+	return len(q.queue.cmds)
+}
+
+// Discard 清空队列中待执行的命令
+func (q *queued) Discard() {
+	q.queue.take()
+}
+
+// Exec 在同一连接上发送管道中所有命令并依次读取回复，执行后管道被清空可继续复用
+// 通过Queue排队的命令在返回前完成类型转换
+// return:
+//
+//	replies: []*Reply 与命令加入顺序一致的执行结果
+//	err: 连接错误，或者第一条执行失败的命令的错误
+func (p *Pipeline) Exec() ([]*Reply, error) {
+	cmds, decoders := p.queue.take()
+	if len(cmds) == 0 {
+		return []*Reply{}, nil
+	}
+
+	ctx := p.client.ctx
+	if p.client.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.client.timeout)
+		defer cancel()
+	}
+
+	p.client.ns.queue(cmds)
+	err := p.client.hooks.batch(ctx, "PIPELINE", cmds, func(ctx context.Context) error {
+		return batchErr(sendBatch(ctx, p.client.exec, cmds), cmds)
+	})
+	p.client.ns.replies(cmds)
+	p.queue.decode(decoders)
+	replies := make([]*Reply, 0, len(cmds))
+	for _, cmd := range cmds {
+		replies = append(replies, cmd.reply)
+	}
+	return replies, err
+}
+
+// 返回批量执行的连接错误，或者第一条执行失败的命令的错误
+func batchErr(err error, cmds []*queuedCmd) error {
+	if err != nil {
+		return err
+	}
+	for _, cmd := range cmds {
+		if cmd.reply.err != nil {
+			return cmd.reply.err
+		}
+	}
+	return nil
+}
+
+// 在同一连接上批量发送命令并读取回复，回复写入各命令的Reply中
+func sendBatch(ctx context.Context, exec executor, cmds []*queuedCmd) error {
+	if b, ok := exec.(batcher); ok {
+		return b.batch(ctx, cmds)
+	}
+	conner, ok := exec.(connector)
+	if !ok {
+		return ErrNoConnector
+	}
+	conn, release, err := conner.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return pipe(ctx, conn, cmds)
+}
+
+// 在指定连接上执行管道
+// 写入失败时，已写入缓冲区的命令可能已部分发送，是否执行无法确定，回复设为该连接错误；
+// 之后的命令从未发送，保持 ErrNotExecuted
+func pipe(ctx context.Context, conn redis.Conn, cmds []*queuedCmd) error {
+	for i, cmd := range cmds {
+		if err := conn.Send(cmd.commandName, cmd.args...); err != nil {
+			for _, sent := range cmds[:i+1] {
+				sent.reply.set(nil, err)
+			}
+			return err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		for _, cmd := range cmds {
+			cmd.reply.set(nil, err)
+		}
+		return err
+	}
+	for i, cmd := range cmds {
+		reply, err := redis.ReceiveContext(conn, ctx)
+		if _, ok := err.(redis.Error); !ok && err != nil {
+			// 连接级错误，剩余回复无法读取
+			for _, rest := range cmds[i:] {
+				rest.reply.set(nil, err)
+			}
+			return err
+		}
+		cmd.reply.set(reply, err)
+	}
+	return nil
+}
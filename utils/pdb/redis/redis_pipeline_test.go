@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/perpower/goframe/utils/pdb/redis/redistest"
+)
+
+func TestPipelineTypedResults(t *testing.T) {
+	s := redistest.NewServer(t)
+	client, err := New(Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.String.Set("word", "abc", "", "", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	p := client.Pipeline()
+	p.Hash.Hset("user:1", [][2]string{{"name", "tom"}})
+	name := Queue(p, func() (string, error) { return p.Hash.Hget("user:1", "name") })
+	visits := Queue(p, func() (int, error) { return p.String.Incr("visits") })
+	missing := Queue(p, func() (string, error) { return p.String.Get("missing") })
+	bad := Queue(p, func() (int, error) { return p.String.Incr("word") })
+
+	if _, err := visits.Result(); err != ErrNotExecuted {
+		t.Fatalf("before Exec err = %v, want ErrNotExecuted", err)
+	}
+	replies, err := p.Exec()
+	if err == nil {
+		t.Fatal("Exec should report the failed INCR")
+	}
+	if len(replies) != 5 {
+		t.Fatalf("got %d replies, want 5", len(replies))
+	}
+
+	if v, err := name.Result(); err != nil || v != "tom" {
+		t.Fatalf("name = %q, %v", v, err)
+	}
+	if v, err := visits.Result(); err != nil || v != 1 {
+		t.Fatalf("visits = %d, %v", v, err)
+	}
+	if _, err := missing.Result(); err != redis.ErrNil {
+		t.Fatalf("missing err = %v, want redis.ErrNil", err)
+	}
+	var re redis.Error
+	if !errors.As(bad.Err(), &re) {
+		t.Fatalf("bad err = %v, want a redis error", bad.Err())
+	}
+
+	// 执行后管道被清空，可继续复用
+	again := Queue(p, func() (int, error) { return p.String.Incr("visits") })
+	if _, err := p.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if again.Val() != 2 {
+		t.Fatalf("visits = %d, want 2", again.Val())
+	}
+}
+
+func TestPipelineDiscard(t *testing.T) {
+	s := redistest.NewServer(t)
+	client, err := New(Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	p := client.Pipeline()
+	dropped := Queue(p, func() (int, error) { return p.String.Incr("visits") })
+	p.Discard()
+	if replies, err := p.Exec(); err != nil || len(replies) != 0 {
+		t.Fatalf("Exec after Discard = %v, %v", replies, err)
+	}
+	if dropped.Err() != ErrNotExecuted {
+		t.Fatalf("discarded err = %v, want ErrNotExecuted", dropped.Err())
+	}
+}
@@ -0,0 +1,561 @@
+// Redis 发布订阅
+// 订阅使用独立的专用连接(不占用连接池)，连接断开后会自动重连并恢复全部订阅，例如：
+//
+//	sub := client.NewSubscriber(redis.SubscribeOptions{
+//		Channels:    []string{"cache:invalidate"},
+//		OnReconnect: func() { localCache.Clear() },
+//	})
+//	go sub.Run(ctx, func(msg redis.Message) {
+//		localCache.Delete(msg.Payload)
+//	})
+//
+// ctx结束时订阅连接关闭，Run返回
+//
+// 集群模式下，普通频道及模式在任意一个节点上订阅，分片频道按槽位在所属主节点上订阅(每个节点一个连接)，
+// 收到MOVED或槽位迁移导致的退订时刷新槽位并重新分组订阅，同样会回调OnReconnect
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/perpower/goframe/utils/prand"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// 订阅收到的消息
+type Message struct {
+	Kind    string // 消息类型：message | pmessage | smessage
+	Pattern string // 匹配的模式，仅pmessage有值
+	Channel string // 频道
+	Payload string // 消息内容
+}
+
+// 订阅选项
+type SubscribeOptions struct {
+	Channels      []string        // SUBSCRIBE 订阅的频道
+	Patterns      []string        // PSUBSCRIBE 订阅的频道模式
+	ShardChannels []string        // SSUBSCRIBE 订阅的分片频道，7.0.0以上支持
+	HealthCheck   time.Duration   // 心跳间隔，超过两个间隔未收到任何数据视为连接已断开，默认30s
+	OnReconnect   func()          // 连接断开后重新订阅成功时回调，期间发布的消息已丢失，可在此做补偿(如清空本地缓存)
+	OnError       func(err error) // 连接或订阅出错时回调，出错后会自动重连
+}
+
+type Subscriber struct {
+	client *Client
+	opts   SubscribeOptions
+
+	mu          sync.Mutex
+	conns       map[string]redis.Conn // 当前订阅连接，节点地址 → 连接，未连接时为nil
+	main        string                // 订阅普通频道及模式的节点
+	shardNodes  map[string]string     // 已订阅的分片频道 → 所在节点
+	resubscribe func()                // 结束当前会话并重新分组订阅
+	channels    map[string]struct{}
+	patterns    map[string]struct{}
+	shards      map[string]struct{}
+}
+
+var (
+	defaultHealthCheck     = 30 * time.Second
+	defaultReconnectWait   = 100 * time.Millisecond
+	maxReconnectWait       = 5 * time.Second
+	subscribeCommands      = [3]string{"SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE"}
+	unsubscribeCommands    = [3]string{"UNSUBSCRIBE", "PUNSUBSCRIBE", "SUNSUBSCRIBE"}
+	ErrSubscriberNoChannel = errors.New("redis: 未指定任何订阅频道")
+
+	errResubscribe = errors.New("redis: 分片频道所在节点已变化，需重新订阅")
+)
+
+// PUBLISH 向频道发布消息
+// channel: string 频道
+// message: string 消息内容
+// return: reply int 收到消息的订阅者数量
+// link: https://redis.io/commands/publish/
+func (c *Client) Publish(channel, message string) (int, error) {
+	return redis.Int(c.conn.Do("PUBLISH", channel, message))
+}
+
+// SPUBLISH 向分片频道发布消息
+// since: 7.0.0
+// channel: string 分片频道
+// message: string 消息内容
+// return: reply int 收到消息的订阅者数量
+// link: https://redis.io/commands/spublish/
+func (c *Client) Spublish(channel, message string) (int, error) {
+	return redis.Int(c.conn.Do("SPUBLISH", channel, message))
+}
+
+// NewSubscriber 创建订阅者，调用Run之后才会建立连接
+func (c *Client) NewSubscriber(opts SubscribeOptions) *Subscriber {
+	if opts.HealthCheck <= 0 {
+		opts.HealthCheck = defaultHealthCheck
+	}
+	s := &Subscriber{
+		client:   c,
+		opts:     opts,
+		channels: map[string]struct{}{},
+		patterns: map[string]struct{}{},
+		shards:   map[string]struct{}{},
+	}
+	for _, v := range opts.Channels {
+		s.channels[v] = struct{}{}
+	}
+	for _, v := range opts.Patterns {
+		s.patterns[v] = struct{}{}
+	}
+	for _, v := range opts.ShardChannels {
+		s.shards[v] = struct{}{}
+	}
+	return s
+}
+
+// Subscribe 追加订阅频道，已连接时立即生效，重连后自动恢复
+func (s *Subscriber) Subscribe(channels ...string) error {
+	return s.change(0, true, channels)
+}
+
+// Psubscribe 追加订阅频道模式
+func (s *Subscriber) Psubscribe(patterns ...string) error {
+	return s.change(1, true, patterns)
+}
+
+// Ssubscribe 追加订阅分片频道
+// since: 7.0.0
+func (s *Subscriber) Ssubscribe(channels ...string) error {
+	return s.change(2, true, channels)
+}
+
+// Unsubscribe 取消订阅频道
+func (s *Subscriber) Unsubscribe(channels ...string) error {
+	return s.change(0, false, channels)
+}
+
+// Punsubscribe 取消订阅频道模式
+func (s *Subscriber) Punsubscribe(patterns ...string) error {
+	return s.change(1, false, patterns)
+}
+
+// Sunsubscribe 取消订阅分片频道
+// since: 7.0.0
+func (s *Subscriber) Sunsubscribe(channels ...string) error {
+	return s.change(2, false, channels)
+}
+
+// 修改订阅集合，kind: 0 频道，1 模式，2 分片频道
+func (s *Subscriber) change(kind int, add bool, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	// 分片频道所在节点可能需要查询槽位表，在加锁前确定
+	var nodes map[string]string
+	if kind == 2 && add {
+		nodes = make(map[string]string, len(names))
+		for _, v := range names {
+			addr, err := s.shardNode(s.client.ctx, v)
+			if err != nil {
+				return err
+			}
+			nodes[v] = addr
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set := [3]map[string]struct{}{s.channels, s.patterns, s.shards}[kind]
+	for _, v := range names {
+		if add {
+			set[v] = struct{}{}
+		} else {
+			delete(set, v)
+		}
+	}
+	if s.conns == nil {
+		return nil
+	}
+
+	command := subscribeCommands[kind]
+	if !add {
+		command = unsubscribeCommands[kind]
+	}
+	if kind != 2 {
+		conn, ok := s.conns[s.main]
+		if !ok {
+			// 当前会话只订阅了分片频道，重新订阅以建立连接
+			s.resubscribe()
+			return nil
+		}
+		if err := conn.Send(command, stringArgs(names)...); err != nil {
+			return err
+		}
+		return conn.Flush()
+	}
+
+	// 分片频道按所在节点及槽位分别发送
+	groups := map[string]map[int][]string{}
+	for _, v := range names {
+		addr, ok := s.shardNodes[v]
+		if add {
+			if addr = nodes[v]; addr == "" {
+				addr = s.main
+			}
+			if _, connected := s.conns[addr]; !connected {
+				s.resubscribe()
+				return nil
+			}
+			s.shardNodes[v] = addr
+		} else if !ok {
+			continue
+		} else {
+			delete(s.shardNodes, v)
+		}
+		if groups[addr] == nil {
+			groups[addr] = map[int][]string{}
+		}
+		groups[addr][KeySlot(v)] = append(groups[addr][KeySlot(v)], v)
+	}
+	for addr, slots := range groups {
+		conn := s.conns[addr]
+		for _, group := range slots {
+			if err := conn.Send(command, stringArgs(group)...); err != nil {
+				return err
+			}
+		}
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 分片频道所在节点，非集群模式返回空，表示与普通频道使用同一连接
+func (s *Subscriber) shardNode(ctx context.Context, channel string) (string, error) {
+	if c, ok := s.client.topo.(*cluster); ok {
+		return c.node(ctx, KeySlot(channel))
+	}
+	return "", nil
+}
+
+// Run 建立订阅连接并阻塞接收消息，每条消息都会调用handler，连接断开时自动重连，
+// 直到ctx结束才返回
+func (s *Subscriber) Run(ctx context.Context, handler func(msg Message)) error {
+	wait := defaultReconnectWait
+	connected := false
+	for {
+		subscribed, err := s.session(ctx, handler, connected)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == ErrSubscriberNoChannel {
+			return err
+		}
+		if subscribed {
+			// 只有订阅成功过，之后的会话才算作重连
+			connected = true
+			wait = defaultReconnectWait
+			if err == errResubscribe {
+				continue
+			}
+		}
+		if err != nil && err != errResubscribe && s.opts.OnError != nil {
+			s.opts.OnError(err)
+		}
+
+		timer := time.NewTimer(prand.Duration(wait/2, wait))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		wait *= 2
+		if wait > maxReconnectWait {
+			wait = maxReconnectWait
+		}
+	}
+}
+
+// Channel 在后台运行订阅，并通过通道投递消息，ctx结束后通道关闭
+// size: int 通道缓冲大小
+func (s *Subscriber) Channel(ctx context.Context, size int) <-chan Message {
+	ch := make(chan Message, size)
+	go func() {
+		defer close(ch)
+		s.Run(ctx, func(msg Message) {
+			select {
+			case ch <- msg:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return ch
+}
+
+// 一次订阅会话，从建立连接到任意一个连接断开或需要重新分组订阅
+// reconnect: bool 之前是否有会话订阅成功过，是则在本次全部连接都收到订阅确认时调用OnReconnect
+// return: bool 本次会话的全部连接是否都收到过订阅确认
+func (s *Subscriber) session(ctx context.Context, handler func(msg Message), reconnect bool) (bool, error) {
+	s.mu.Lock()
+	if len(s.channels)+len(s.patterns)+len(s.shards) == 0 {
+		s.mu.Unlock()
+		return false, ErrSubscriberNoChannel
+	}
+	shards := make([]string, 0, len(s.shards))
+	for v := range s.shards {
+		shards = append(shards, v)
+	}
+	s.mu.Unlock()
+
+	main, err := s.client.topo.addr(ctx)
+	if err != nil {
+		return false, err
+	}
+	nodes := make(map[string]string, len(shards))
+	for _, v := range shards {
+		addr, err := s.shardNode(ctx, v)
+		if err != nil {
+			return false, err
+		}
+		if addr == "" {
+			addr = main
+		}
+		nodes[v] = addr
+	}
+
+	// 每个节点一个连接
+	conf := *s.client.config
+	conf.ReadTimeout = 0 // 订阅连接长时间阻塞读取，由心跳检测连接可用性
+	conns := map[string]redis.Conn{}
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	addrs := []string{main}
+	for _, addr := range nodes {
+		addrs = append(addrs, addr)
+	}
+	for _, addr := range addrs {
+		if _, ok := conns[addr]; ok {
+			continue
+		}
+		conn, err := dial(ctx, &conf, addr)
+		if err != nil {
+			return false, err
+		}
+		conns[addr] = conn
+	}
+
+	stop := make(chan error, len(conns)+1)
+	resubscribe := func() {
+		select {
+		case stop <- errResubscribe:
+		default:
+		}
+	}
+
+	s.mu.Lock()
+	if len(s.channels)+len(s.patterns)+len(s.shards) == 0 {
+		s.mu.Unlock()
+		return false, ErrSubscriberNoChannel
+	}
+	s.main = main
+	s.shardNodes = map[string]string{}
+	for i, set := range [2]map[string]struct{}{s.channels, s.patterns} {
+		if len(set) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(set))
+		for v := range set {
+			names = append(names, v)
+		}
+		if err := conns[main].Send(subscribeCommands[i], stringArgs(names)...); err != nil {
+			s.mu.Unlock()
+			return false, err
+		}
+	}
+	// 分片频道按节点及槽位分组，同一条SSUBSCRIBE中的频道必须位于同一槽位
+	groups := map[string]map[int][]string{}
+	for v := range s.shards {
+		addr, ok := nodes[v]
+		if !ok {
+			// 获取快照之后新增的频道，所在节点未知，订阅成功后重新分组
+			resubscribe()
+			continue
+		}
+		s.shardNodes[v] = addr
+		if groups[addr] == nil {
+			groups[addr] = map[int][]string{}
+		}
+		groups[addr][KeySlot(v)] = append(groups[addr][KeySlot(v)], v)
+	}
+	for addr, slots := range groups {
+		for _, group := range slots {
+			if err := conns[addr].Send(subscribeCommands[2], stringArgs(group)...); err != nil {
+				s.mu.Unlock()
+				return false, err
+			}
+		}
+	}
+	for _, conn := range conns {
+		if err := conn.Flush(); err != nil {
+			s.mu.Unlock()
+			return false, err
+		}
+	}
+	if len(s.channels)+len(s.patterns) == 0 && len(groups[main]) == 0 {
+		// 普通频道的节点上没有订阅，不接收该连接的推送
+		conns[main].Close()
+		delete(conns, main)
+	}
+	s.conns = conns
+	s.resubscribe = resubscribe
+	s.mu.Unlock()
+
+	// 每个连接一个读取goroutine，handler串行调用
+	var handlerMu sync.Mutex
+	var acked int32
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		wg.Add(1)
+		go func(conn redis.Conn) {
+			defer wg.Done()
+			subscribed := false
+			for {
+				reply, err := redis.ReceiveWithTimeout(conn, 2*s.opts.HealthCheck)
+				if e, ok := err.(redis.Error); ok {
+					if kind, slot, addr := parseRedirect(e); kind == "MOVED" {
+						// 分片频道的槽位已迁移到其他节点
+						if c, ok := s.client.topo.(*cluster); ok {
+							c.moved(slot, addr)
+						}
+						resubscribe()
+						continue
+					}
+					// 订阅命令的错误回复(如服务端不支持SSUBSCRIBE)不影响连接上的其他订阅
+					if s.opts.OnError != nil {
+						s.opts.OnError(e)
+					}
+					continue
+				}
+				if err != nil {
+					select {
+					case stop <- err:
+					default:
+					}
+					return
+				}
+				if !subscribed && isSubscribeAck(reply) {
+					subscribed = true
+					if int(atomic.AddInt32(&acked, 1)) == len(conns) && reconnect && s.opts.OnReconnect != nil {
+						s.opts.OnReconnect()
+					}
+				}
+				if s.unsubscribedShard(reply) {
+					resubscribe()
+					continue
+				}
+				if msg, ok := parseMessage(reply); ok {
+					handlerMu.Lock()
+					handler(msg)
+					handlerMu.Unlock()
+				}
+			}
+		}(conn)
+	}
+
+	// 定时发送PING作为心跳
+	ticker := time.NewTicker(s.opts.HealthCheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case err = <-stop:
+		case <-ticker.C:
+			s.mu.Lock()
+			for _, conn := range conns {
+				if conn.Send("PING") == nil {
+					conn.Flush()
+				}
+			}
+			s.mu.Unlock()
+			continue
+		}
+		break
+	}
+
+	// 关闭连接以中断阻塞中的读取
+	s.mu.Lock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+	s.conns, s.resubscribe = nil, nil
+	s.mu.Unlock()
+	wg.Wait()
+	return int(atomic.LoadInt32(&acked)) == len(conns), err
+}
+
+// 服务端主动退订(槽位迁移)的分片频道仍在订阅集合中，需要到新节点重新订阅
+func (s *Subscriber) unsubscribedShard(reply interface{}) bool {
+	items, err := redis.Values(reply, nil)
+	if err != nil || len(items) < 2 {
+		return false
+	}
+	kind, _ := redis.String(items[0], nil)
+	if kind != "sunsubscribe" {
+		return false
+	}
+	channel, _ := redis.String(items[1], nil)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.shards[channel]
+	return ok
+}
+
+// 是否为订阅确认推送
+func isSubscribeAck(reply interface{}) bool {
+	items, err := redis.Values(reply, nil)
+	if err != nil || len(items) == 0 {
+		return false
+	}
+	kind, _ := redis.String(items[0], nil)
+	return kind == "subscribe" || kind == "psubscribe" || kind == "ssubscribe"
+}
+
+// 解析订阅连接上的推送，非消息类推送(订阅确认、心跳)返回false
+func parseMessage(reply interface{}) (Message, bool) {
+	items, err := redis.Values(reply, nil)
+	if err != nil || len(items) < 3 {
+		return Message{}, false
+	}
+	kind, _ := redis.String(items[0], nil)
+	switch kind {
+	case "message", "smessage":
+		channel, _ := redis.String(items[1], nil)
+		payload, _ := redis.String(items[2], nil)
+		return Message{Kind: kind, Channel: channel, Payload: payload}, true
+	case "pmessage":
+		if len(items) < 4 {
+			return Message{}, false
+		}
+		pattern, _ := redis.String(items[1], nil)
+		channel, _ := redis.String(items[2], nil)
+		payload, _ := redis.String(items[3], nil)
+		return Message{Kind: kind, Pattern: pattern, Channel: channel, Payload: payload}, true
+	}
+	return Message{}, false
+}
+
+// 将字符串数组转换为命令参数
+func stringArgs(arr []string) []interface{} {
+	args := make([]interface{}, 0, len(arr))
+	for _, v := range arr {
+		args = append(args, v)
+	}
+	return args
+}
@@ -0,0 +1,81 @@
+package redis
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/perpower/goframe/utils/pdb/redis/redistest"
+)
+
+// 等待条件成立，超时则测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSubscriberReconnectOnlyAfterSubscribed(t *testing.T) {
+	var subscribes int32
+	s := redistest.NewServer(t, func(s *redistest.Server) {
+		s.Hook = func(c *redistest.Conn, args []string) (interface{}, bool) {
+			// 第一次订阅时断开连接，该会话从未订阅成功
+			if args[0] == "SUBSCRIBE" && atomic.AddInt32(&subscribes, 1) == 1 {
+				c.Close()
+				return nil, true
+			}
+			return nil, false
+		}
+	})
+	client, err := New(Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var reconnects, errs int32
+	sub := client.NewSubscriber(SubscribeOptions{
+		Channels:    []string{"news"},
+		OnReconnect: func() { atomic.AddInt32(&reconnects, 1) },
+		OnError:     func(err error) { atomic.AddInt32(&errs, 1) },
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs := sub.Channel(ctx, 10)
+
+	publish := func(payload string) {
+		t.Helper()
+		waitFor(t, "subscriber", func() bool {
+			n, err := client.Publish("news", payload)
+			return err == nil && n == 1
+		})
+		select {
+		case msg := <-msgs:
+			if msg.Channel != "news" || msg.Payload != payload {
+				t.Fatalf("got %+v", msg)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("message not delivered")
+		}
+	}
+
+	publish("first")
+	if n := atomic.LoadInt32(&errs); n != 1 {
+		t.Fatalf("OnError called %d times, want 1", n)
+	}
+	if n := atomic.LoadInt32(&reconnects); n != 0 {
+		t.Fatalf("OnReconnect called %d times before any reconnect", n)
+	}
+
+	s.DropConns()
+	publish("second")
+	if n := atomic.LoadInt32(&reconnects); n != 1 {
+		t.Fatalf("OnReconnect called %d times, want 1", n)
+	}
+}
@@ -0,0 +1,101 @@
+// 命令回复，用于管道、事务、脚本等返回值类型不固定的场景
+package redis
+
+import (
+	"errors"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Reply 命令执行结果，排队的命令在管道或事务执行之后才可读取
+type Reply struct {
+	commandName string
+	value       interface{}
+	err         error
+}
+
+var (
+	ErrQueued      = errors.New("redis: 命令已加入队列，执行结果需在Exec之后通过Reply获取")
+	ErrNotExecuted = errors.New("redis: 命令尚未执行")
+)
+
+func newReply(commandName string) *Reply {
+	return &Reply{
+		commandName: commandName,
+		err:         ErrNotExecuted,
+	}
+}
+
+// 写入执行结果，redis返回的错误回复转换为error
+func (r *Reply) set(value interface{}, err error) {
+	if e, ok := value.(redis.Error); ok && err == nil {
+		value, err = nil, e
+	}
+	r.value = value
+	r.err = err
+}
+
+// Command 返回命令名
+func (r *Reply) Command() string {
+	return r.commandName
+}
+
+// Value 返回未做类型转换的原始回复
+func (r *Reply) Value() (interface{}, error) {
+	return r.value, r.err
+}
+
+// Err 返回该命令的执行错误
+func (r *Reply) Err() error {
+	return r.err
+}
+
+// String 将回复转换为string
+func (r *Reply) String() (string, error) {
+	return redis.String(r.value, r.err)
+}
+
+// Int 将回复转换为int
+func (r *Reply) Int() (int, error) {
+	return redis.Int(r.value, r.err)
+}
+
+// Int64 将回复转换为int64
+func (r *Reply) Int64() (int64, error) {
+	return redis.Int64(r.value, r.err)
+}
+
+// Float64 将回复转换为float64
+func (r *Reply) Float64() (float64, error) {
+	return redis.Float64(r.value, r.err)
+}
+
+// Bool 将回复转换为bool
+func (r *Reply) Bool() (bool, error) {
+	return redis.Bool(r.value, r.err)
+}
+
+// Strings 将数组回复转换为[]string
+func (r *Reply) Strings() ([]string, error) {
+	return redis.Strings(r.value, r.err)
+}
+
+// Ints 将数组回复转换为[]int
+func (r *Reply) Ints() ([]int, error) {
+	return redis.Ints(r.value, r.err)
+}
+
+// Float64s 将数组回复转换为[]float64
+func (r *Reply) Float64s() ([]float64, error) {
+	return redis.Float64s(r.value, r.err)
+}
+
+// StringMap 将键值对数组回复转换为map[string]string，适用于HGETALL等命令
+func (r *Reply) StringMap() (map[string]string, error) {
+	return redis.StringMap(r.value, r.err)
+}
+
+// Values 将数组回复转换为[]interface{}
+func (r *Reply) Values() ([]interface{}, error) {
+	return redis.Values(r.value, r.err)
+}
@@ -6,16 +6,33 @@ import (
 )
 
 type Rscan struct {
-	conn redis.Conn
+	conn commander
 }
 
-// 根据条件迭代一次当前数据库中满足条件的键集
+// 根据条件迭代一次当前数据库中满足条件的键集，集群模式下游标无法对应到节点，返回 ErrClusterScanCursor
 // cursor: string 游标
 // pattern: string 正则表达式
 // count: int 单次迭代键的数量
 // typ: string 类型 6.0.0版本以后支持该参数
 // link：https://redis.io/commands/scan/
 func (s *Rscan) ScanOnce(cursor int, pattern string, count int, typ string) (int, []string, error) {
+	if s.cluster() {
+		return 0, []string{}, ErrClusterScanCursor
+	}
+	return parseScan(s.conn.Do("SCAN", scanArgs(cursor, pattern, count, typ)...))
+}
+
+// 是否为集群模式
+func (s *Rscan) cluster() bool {
+	if b, ok := s.conn.(*bound); ok {
+		_, ok = b.exec.(*cluster)
+		return ok
+	}
+	return false
+}
+
+// SCAN命令参数
+func scanArgs(cursor int, pattern string, count int, typ string) []interface{} {
 	if count < 1 {
 		count = defaultScanNum
 	}
@@ -25,7 +42,15 @@ func (s *Rscan) ScanOnce(cursor int, pattern string, count int, typ string) (int
 	} else {
 		args = append(args, cursor, "MATCH", pattern, "COUNT", count, "TYPE", typ)
 	}
-	res, err := redis.Values(s.conn.Do("SCAN", args...))
+	return args
+}
+
+// 解析SCAN命令的回复
+func parseScan(reply interface{}, err error) (int, []string, error) {
+	res, err := redis.Values(reply, err)
+	if err != nil {
+		return 0, []string{}, err
+	}
 	cur, _ := redis.Int(res[0], nil)
 	arr, _ := redis.Strings(res[1], nil)
 
@@ -33,76 +58,55 @@ func (s *Rscan) ScanOnce(cursor int, pattern string, count int, typ string) (int
 }
 
 // 根据条件迭代当前数据库中所有满足条件的键集
+//
+// Deprecated: 该方法会忽略迭代过程中的错误，请使用 ScanAllE
+func (s *Rscan) ScanAll(pattern string, count int, typ string) (arr []string) {
+	arr, _ = s.ScanAllE(pattern, count, typ)
+	return arr
+}
+
+// 根据条件迭代当前数据库中所有满足条件的键集，集群模式下依次遍历所有主节点，出错时停止迭代，键数量较多时请使用 ScanIter 逐个读取
 // pattern: string 正则表达式
 // count: int 单次迭代键的数量
 // typ: string 类型 6.0.0版本以后支持该参数
-// return: arr []string
+// return:
+//
+//	arr: []string 出错前已迭代到的键
+//	err: error
+//
 // link：https://redis.io/commands/scan/
-func (s *Rscan) ScanAll(pattern string, count int, typ string) (arr []string) {
-	if count < 1 {
-		count = defaultScanNum
-	}
-	cursor := defaultCursor
-	for {
-		args := make([]interface{}, 0)
-		if typ == "" {
-			args = append(args, cursor, "MATCH", pattern, "COUNT", count)
-		} else {
-			args = append(args, cursor, "MATCH", pattern, "COUNT", count, "TYPE", typ)
-		}
-
-		res, err := redis.Values(s.conn.Do("SCAN", args...))
-		if err == nil {
-			curs, _ := redis.Int(res[0], nil)
-			cursor = curs
-			lists, _ := redis.Strings(res[1], nil)
-			if len(lists) > 0 {
-				arr = append(arr, lists...)
-			}
-		}
-		if cursor == defaultCursor {
-			break
-		}
+func (s *Rscan) ScanAllE(pattern string, count int, typ string) ([]string, error) {
+	arr := make([]string, 0)
+	it := s.ScanIter(pattern, count, typ)
+	for it.Next() {
+		arr = append(arr, it.Value())
 	}
-	return arr
+	return arr, it.Err()
 }
 
 // 根据条件迭代当前数据库中所有满足条件的键集并删除
+//
+// Deprecated: 该方法会忽略迭代及删除过程中的错误，请使用 ScanDelE
+func (s *Rscan) ScanDel(pattern string, count int, typ string) (nums int) {
+	nums, _ = s.ScanDelE(pattern, count, typ)
+	return nums
+}
+
+// 根据条件迭代当前数据库中所有满足条件的键集并删除，每次迭代到的键作为一批通过UNLINK删除，
+// 集群模式下遍历所有主节点并按槽位拆分，出错时停止，需要限速、试运行时请使用 Delete
 // pattern: string 正则表达式
 // count: int 单次迭代键的数量
 // typ: string 类型 6.0.0版本以后支持该参数
-// return: nums int 本次迭代删除的键数量
+// return:
+//
+//	nums: int 出错前已删除的键数量
+//	err: error
+//
 // link：https://redis.io/commands/scan/
-func (s *Rscan) ScanDel(pattern string, count int, typ string) (nums int) {
+func (s *Rscan) ScanDelE(pattern string, count int, typ string) (int, error) {
 	if count < 1 {
 		count = defaultScanNum
 	}
-	cursor := defaultCursor
-	nums = 0
-	for {
-		args := make([]interface{}, 0)
-		if typ == "" {
-			args = append(args, cursor, "MATCH", pattern, "COUNT", count)
-		} else {
-			args = append(args, cursor, "MATCH", pattern, "COUNT", count, "TYPE", typ)
-		}
-
-		res, err := redis.Values(s.conn.Do("SCAN", args...))
-		if err == nil {
-			curs, _ := redis.Int(res[0], nil)
-			cursor = curs
-			lists, _ := redis.Values(res[1], nil)
-
-			//执行删除
-			if len(lists) > 0 {
-				num, _ := redis.Int(s.conn.Do("UNLINK", lists...))
-				nums += num
-			}
-		}
-
-		if cursor == defaultCursor {
-			break
-		}
-	}
-	return nums
+	res, err := s.Delete(pattern, ScanDeleteOptions{Count: count, BatchSize: count, Type: typ})
+	return res.Deleted, err
 }
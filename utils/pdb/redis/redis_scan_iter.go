@@ -0,0 +1,308 @@
+// scan迭代器及批量删除
+// 迭代器每次只缓存一批SCAN结果，适合在键数量很多时流式读取，例如：
+//
+//	it := client.WithContext(ctx).Scan.ScanIter("user:*", 500, "")
+//	for it.Next() {
+//		key := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+//
+// 特别说明：SCAN系列命令在迭代期间如有元素被修改，同一元素可能返回多次，需要精确去重时由调用方处理
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ScanIterator 游标迭代器，Next返回false后通过Err判断是迭代完成还是出错
+// 非并发安全，不要在多个goroutine中共用
+type ScanIterator[T any] struct {
+	fetch  func(cursor int) (int, []T, error)
+	ctx    context.Context
+	cursor int
+	page   []T
+	value  T
+	done   bool
+	err    error
+}
+
+func newScanIterator[T any](ctx context.Context, fetch func(cursor int) (int, []T, error)) *ScanIterator[T] {
+	return &ScanIterator[T]{
+		fetch:  fetch,
+		ctx:    ctx,
+		cursor: defaultCursor,
+	}
+}
+
+// Next 前进到下一个元素，当前批次读完时自动执行下一次迭代
+func (it *ScanIterator[T]) Next() bool {
+	for len(it.page) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		if err := it.ctx.Err(); err != nil {
+			it.err = err
+			return false
+		}
+		cur, page, err := it.fetch(it.cursor)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.cursor = cur
+		it.page = page
+		it.done = cur == defaultCursor
+	}
+	it.value = it.page[0]
+	it.page = it.page[1:]
+	return true
+}
+
+// Value 返回当前元素，需在Next返回true之后调用
+func (it *ScanIterator[T]) Value() T {
+	return it.value
+}
+
+// Err 返回迭代过程中的错误，context取消时返回ctx.Err()
+func (it *ScanIterator[T]) Err() error {
+	return it.err
+}
+
+// 根据条件迭代当前数据库中满足条件的键，集群模式下依次遍历所有主节点
+// pattern: string 正则表达式
+// count: int 单次迭代键的数量
+// typ: string 类型 6.0.0版本以后支持该参数
+// link：https://redis.io/commands/scan/
+func (s *Rscan) ScanIter(pattern string, count int, typ string) *ScanIterator[string] {
+	b, ok := s.conn.(*bound)
+	if !ok {
+		return newScanIterator(context.Background(), func(cursor int) (int, []string, error) {
+			return s.ScanOnce(cursor, pattern, count, typ)
+		})
+	}
+	cl, ok := b.exec.(*cluster)
+	if !ok {
+		return newScanIterator(b.ctx, func(cursor int) (int, []string, error) {
+			return s.ScanOnce(cursor, pattern, count, typ)
+		})
+	}
+
+	// 集群模式下每个主节点有独立的游标，一个节点迭代完成后切换到下一个节点
+	var nodes []string
+	index := 0
+	return newScanIterator(b.ctx, func(cursor int) (int, []string, error) {
+		if cursor < 0 {
+			cursor = defaultCursor
+		}
+		ctx, cancel := b.context()
+		defer cancel()
+		if nodes == nil {
+			arr, err := cl.nodes(ctx)
+			if err != nil {
+				return 0, []string{}, err
+			}
+			nodes = arr
+		}
+		if index >= len(nodes) {
+			return defaultCursor, []string{}, nil
+		}
+		args := b.ns.args("SCAN", scanArgs(cursor, pattern, count, typ))
+		reply, err := b.hooks.do(ctx, "SCAN", args, func(ctx context.Context) (interface{}, error) {
+			return cl.doNode(ctx, nodes[index], "SCAN", args...)
+		})
+		cur, arr, err := parseScan(b.ns.reply("SCAN", reply), err)
+		if err != nil {
+			return 0, []string{}, err
+		}
+		if cur == defaultCursor {
+			index++
+			if index < len(nodes) {
+				// 返回-1使迭代器继续，下一个节点从游标0开始
+				return -1, arr, nil
+			}
+		}
+		return cur, arr, nil
+	})
+}
+
+// 根据条件迭代指定key中满足条件的键值对，每个元素为 [field, value]
+// key: string 键名
+// pattern: string 正则表达式
+// count: int 单次迭代键值对的数量
+// link：https://redis.io/commands/hscan/
+func (c *Rhash) ScanIter(key string, pattern string, count int) *ScanIterator[[2]string] {
+	return newScanIterator(contextOf(c.conn), func(cursor int) (int, [][2]string, error) {
+		return c.ScanOnce(key, cursor, pattern, count)
+	})
+}
+
+// 根据条件迭代指定key中满足条件的成员
+// key: string 键名
+// pattern: string 正则表达式
+// count: int 单次迭代成员的数量
+// link：https://redis.io/commands/sscan/
+func (c *Rset) ScanIter(key string, pattern string, count int) *ScanIterator[string] {
+	return newScanIterator(contextOf(c.conn), func(cursor int) (int, []string, error) {
+		return c.ScanOnce(key, cursor, pattern, count)
+	})
+}
+
+// 根据条件迭代指定key中满足条件的成员及分数
+// key: string 键名
+// pattern: string 正则表达式
+// count: int 单次迭代成员的数量
+// link：https://redis.io/commands/zscan/
+func (c *Rzset) ScanIter(key string, pattern string, count int) *ScanIterator[ZMember] {
+	return newScanIterator(contextOf(c.conn), func(cursor int) (int, []ZMember, error) {
+		return c.ScanOnce(key, cursor, pattern, count)
+	})
+}
+
+// ScanDeleteOptions 批量删除选项
+type ScanDeleteOptions struct {
+	Count     int                 // 单次SCAN迭代键的数量，默认100
+	BatchSize int                 // 每批UNLINK的键数量，默认100
+	Rate      int                 // 每秒最多删除的键数量，默认0不限制
+	Type      string              // 只删除指定类型的键，6.0.0版本以后支持
+	DryRun    bool                // 试运行，只统计匹配的键，不执行删除
+	OnBatch   func(keys []string) // 每批键删除(试运行时为匹配)之后回调，可用于记录日志或试运行时输出将被删除的键
+}
+
+// ScanDeleteResult 批量删除结果
+type ScanDeleteResult struct {
+	Matched int // 匹配到的键数量，迭代期间键有变动时可能包含重复
+	Deleted int // 实际删除的键数量，试运行时为0
+	Batches int // 执行的批次数
+}
+
+var defaultScanDeleteOptions = ScanDeleteOptions{
+	Count:     100,
+	BatchSize: 100,
+}
+
+// Delete 根据条件迭代当前数据库中所有满足条件的键并按批次通过UNLINK删除，
+// 集群模式下遍历所有主节点，同一批次的键按槽位拆分后通过管道发送
+// 通过client.WithContext(ctx)取消时，已开始的批次执行完成后停止并返回ctx.Err()
+// pattern: string 正则表达式
+// return:
+//
+//	res: ScanDeleteResult 出错或取消前的删除结果
+//	err: error
+//
+// link：https://redis.io/commands/unlink/
+func (s *Rscan) Delete(pattern string, opts ...ScanDeleteOptions) (ScanDeleteResult, error) {
+	opt := defaultScanDeleteOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+		if opt.Count < 1 {
+			opt.Count = defaultScanDeleteOptions.Count
+		}
+		if opt.BatchSize < 1 {
+			opt.BatchSize = defaultScanDeleteOptions.BatchSize
+		}
+	}
+
+	ctx := contextOf(s.conn)
+	res := ScanDeleteResult{}
+	start := time.Now()
+	batch := make([]string, 0, opt.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if opt.Rate > 0 {
+			// 按累计删除数量计算本批次最早的开始时间
+			wait := time.Until(start.Add(time.Duration(res.Matched) * time.Second / time.Duration(opt.Rate)))
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !opt.DryRun {
+			num, err := s.unlink(batch)
+			res.Deleted += num
+			if err != nil {
+				return err
+			}
+		}
+		res.Matched += len(batch)
+		res.Batches++
+		if opt.OnBatch != nil {
+			opt.OnBatch(batch)
+		}
+		batch = make([]string, 0, opt.BatchSize)
+		return nil
+	}
+
+	it := s.ScanIter(pattern, opt.Count, opt.Type)
+	for it.Next() {
+		batch = append(batch, it.Value())
+		if len(batch) >= opt.BatchSize {
+			if err := flush(); err != nil {
+				return res, err
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return res, err
+	}
+	return res, flush()
+}
+
+// 删除一批键，集群模式下按槽位拆分
+func (s *Rscan) unlink(keys []string) (int, error) {
+	b, ok := s.conn.(*bound)
+	if !ok {
+		return redis.Int(s.conn.Do("UNLINK", stringArgs(keys)...))
+	}
+	if _, ok := b.exec.(*cluster); !ok {
+		return redis.Int(s.conn.Do("UNLINK", stringArgs(keys)...))
+	}
+
+	slots := map[int][]string{}
+	for _, key := range keys {
+		slot := KeySlot(string(b.ns) + key)
+		slots[slot] = append(slots[slot], key)
+	}
+	cmds := make([]*queuedCmd, 0, len(slots))
+	for _, group := range slots {
+		cmds = append(cmds, &queuedCmd{
+			commandName: "UNLINK",
+			args:        stringArgs(group),
+			reply:       newReply("UNLINK"),
+		})
+	}
+	ctx, cancel := b.context()
+	defer cancel()
+	b.ns.queue(cmds)
+	err := b.hooks.batch(ctx, "PIPELINE", cmds, func(ctx context.Context) error {
+		return batchErr(sendBatch(ctx, b.exec, cmds), cmds)
+	})
+	nums := 0
+	for _, cmd := range cmds {
+		num, _ := cmd.reply.Int()
+		nums += num
+	}
+	return nums, err
+}
+
+// 返回操作对象所在客户端视图的context
+func contextOf(conn commander) context.Context {
+	if b, ok := conn.(*bound); ok {
+		return b.ctx
+	}
+	return context.Background()
+}
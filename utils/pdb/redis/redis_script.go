@@ -0,0 +1,158 @@
+// Redis Lua脚本
+package redis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+type Rscript struct {
+	conn commander
+}
+
+// Script 预定义的Lua脚本，本地计算并缓存脚本SHA1，执行时优先使用EVALSHA，
+// 服务端未缓存该脚本(NOSCRIPT)时自动回退为EVAL，EVAL执行后服务端即会缓存该脚本
+type Script struct {
+	src  string
+	hash string
+}
+
+// NewScript 创建Lua脚本对象，通常定义为包级变量复用
+// src: string Lua脚本内容
+func NewScript(src string) *Script {
+	h := sha1.Sum([]byte(src))
+	return &Script{
+		src:  src,
+		hash: hex.EncodeToString(h[:]),
+	}
+}
+
+// Hash 返回脚本的SHA1
+func (s *Script) Hash() string {
+	return s.hash
+}
+
+// Load 将脚本预加载到服务端缓存
+func (s *Script) Load(c *Client) error {
+	_, err := c.Script.Load(s.src)
+	return err
+}
+
+// Do 执行脚本
+// c: *Client 执行脚本的客户端，可以是WithContext、Pin等返回的视图
+// keys: []string 脚本中通过KEYS访问的键名
+// args: ...interface{} 脚本中通过ARGV访问的参数
+// return: *Reply 脚本返回值，通过Reply的方法转换为所需类型
+func (s *Script) Do(c *Client, keys []string, args ...interface{}) *Reply {
+	reply := newReply("EVALSHA")
+	reply.set(c.Script.Evalsha(s.hash, keys, args...))
+	if isNoScript(reply.err) {
+		reply = newReply("EVAL")
+		reply.set(c.Script.Eval(s.src, keys, args...))
+	}
+	return reply
+}
+
+// DoReadOnly 以只读方式执行脚本，可在只读副本上执行
+// since: 7.0.0
+func (s *Script) DoReadOnly(c *Client, keys []string, args ...interface{}) *Reply {
+	reply := newReply("EVALSHA_RO")
+	reply.set(c.Script.EvalshaRo(s.hash, keys, args...))
+	if isNoScript(reply.err) {
+		reply = newReply("EVAL_RO")
+		reply.set(c.Script.EvalRo(s.src, keys, args...))
+	}
+	return reply
+}
+
+// 判断是否为服务端未缓存脚本的错误
+func isNoScript(err error) bool {
+	e, ok := err.(redis.Error)
+	return ok && strings.HasPrefix(string(e), "NOSCRIPT")
+}
+
+// 组装 numkeys key [key ...] arg [arg ...] 形式的参数
+func scriptArgs(head string, keys []string, args []interface{}) []interface{} {
+	res := make([]interface{}, 0, len(keys)+len(args)+2)
+	res = append(res, head, len(keys))
+	for _, v := range keys {
+		res = append(res, v)
+	}
+	return append(res, args...)
+}
+
+// EVAL 执行Lua脚本
+// script: string 脚本内容
+// keys: []string 键名数组
+// args: ...interface{} 附加参数
+// 特别说明：因脚本返回值多种多样，故不在此做类型转换，根据业务场景自行转换处理
+// link: https://redis.io/commands/eval/
+func (s *Rscript) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return s.conn.Do("EVAL", scriptArgs(script, keys, args)...)
+}
+
+// EVAL_RO 只读方式执行Lua脚本，脚本中不允许执行写命令
+// since: 7.0.0
+// link: https://redis.io/commands/eval_ro/
+func (s *Rscript) EvalRo(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return s.conn.Do("EVAL_RO", scriptArgs(script, keys, args)...)
+}
+
+// EVALSHA 根据SHA1执行服务端已缓存的Lua脚本，未缓存时返回NOSCRIPT错误
+// sha1: string 脚本SHA1
+// keys: []string 键名数组
+// args: ...interface{} 附加参数
+// link: https://redis.io/commands/evalsha/
+func (s *Rscript) Evalsha(sha1 string, keys []string, args ...interface{}) (interface{}, error) {
+	return s.conn.Do("EVALSHA", scriptArgs(sha1, keys, args)...)
+}
+
+// EVALSHA_RO 只读方式根据SHA1执行服务端已缓存的Lua脚本
+// since: 7.0.0
+// link: https://redis.io/commands/evalsha_ro/
+func (s *Rscript) EvalshaRo(sha1 string, keys []string, args ...interface{}) (interface{}, error) {
+	return s.conn.Do("EVALSHA_RO", scriptArgs(sha1, keys, args)...)
+}
+
+// SCRIPT LOAD 将脚本加载到服务端缓存，不执行
+// script: string 脚本内容
+// return: string 脚本SHA1
+// link: https://redis.io/commands/script-load/
+func (s *Rscript) Load(script string) (string, error) {
+	return redis.String(s.conn.Do("SCRIPT", "LOAD", script))
+}
+
+// SCRIPT EXISTS 判断脚本是否已在服务端缓存
+// sha1s: []string 脚本SHA1数组
+// return: []bool 与sha1s顺序一致
+// link: https://redis.io/commands/script-exists/
+func (s *Rscript) Exists(sha1s []string) ([]bool, error) {
+	args := make([]interface{}, 0)
+	args = append(args, "EXISTS")
+	for _, v := range sha1s {
+		args = append(args, v)
+	}
+	res, err := redis.Ints(s.conn.Do("SCRIPT", args...))
+	if err != nil {
+		return []bool{}, err
+	}
+	arr := make([]bool, 0, len(res))
+	for _, v := range res {
+		arr = append(arr, v == 1)
+	}
+	return arr, err
+}
+
+// SCRIPT FLUSH 清空服务端脚本缓存
+// mode: string 清空模式  SYNC(同步)|ASYNC(异步)
+// return: 始终返回"OK"
+// link: https://redis.io/commands/script-flush/
+func (s *Rscript) Flush(mode string) (string, error) {
+	if mode == "" {
+		mode = defaultFlushdbMode
+	}
+	return redis.String(s.conn.Do("SCRIPT", "FLUSH", mode))
+}
@@ -6,7 +6,7 @@ import (
 )
 
 type Rset struct {
-	conn redis.Conn
+	conn commander
 }
 
 // SADD, 往指定集合添加成员，已经存在的跳过, key存在将创建
@@ -244,6 +244,9 @@ func (c *Rset) ScanOnce(key string, cursor int, pattern string, count int) (int,
 	args = append(args, key, cursor, "MATCH", pattern, "COUNT", count)
 
 	res, err := redis.Values(c.conn.Do("SSCAN", args...))
+	if err != nil {
+		return 0, []string{}, err
+	}
 	cur, _ := redis.Int(res[0], nil)
 	arr, _ := redis.Strings(res[1], nil)
 
@@ -251,68 +254,75 @@ func (c *Rset) ScanOnce(key string, cursor int, pattern string, count int) (int,
 }
 
 // 根据条件迭代指定key中所有满足条件的成员
+//
+// Deprecated: 该方法会忽略迭代过程中的错误，请使用 ScanAllE
+func (c *Rset) ScanAll(key string, pattern string, count int) (arr []string) {
+	arr, _ = c.ScanAllE(key, pattern, count)
+	return arr
+}
+
+// 根据条件迭代指定key中所有满足条件的成员，出错时停止迭代，数据量较多时请使用 ScanIter 逐个读取
 // key: string 键名
 // pattern: string 正则表达式
 // count: int 单次迭代成员的数量
-// return: arr []string
+// return:
+//
+//	arr: []string 出错前已迭代到的成员
+//	err: error
+//
 // link：https://redis.io/commands/sscan/
-func (c *Rset) ScanAll(key string, pattern string, count int) (arr []string) {
-	if count < 1 {
-		count = defaultScanNum
-	}
+func (c *Rset) ScanAllE(key string, pattern string, count int) ([]string, error) {
+	arr := make([]string, 0)
 	cursor := defaultCursor
 	for {
-		args := make([]interface{}, 0)
-		args = append(args, key, cursor, "MATCH", pattern, "COUNT", count)
-
-		res, err := redis.Values(c.conn.Do("SSCAN", args...))
-		if err == nil {
-			curs, _ := redis.Int(res[0], nil)
-			cursor = curs
-			lists, _ := redis.Strings(res[1], nil)
-			if len(lists) > 0 {
-				arr = append(arr, lists...)
-			}
+		cur, lists, err := c.ScanOnce(key, cursor, pattern, count)
+		if err != nil {
+			return arr, err
 		}
+		arr = append(arr, lists...)
+		cursor = cur
 		if cursor == defaultCursor {
-			break
+			return arr, nil
 		}
 	}
-	return arr
 }
 
 // 根据条件迭代指定key中所有满足条件的成员并删除
+//
+// Deprecated: 该方法会忽略迭代及删除过程中的错误，请使用 ScanDelE
+func (c *Rset) ScanDel(key string, pattern string, count int) (nums int) {
+	nums, _ = c.ScanDelE(key, pattern, count)
+	return nums
+}
+
+// 根据条件迭代指定key中所有满足条件的成员并删除，出错时停止
 // key: string 键名
 // pattern: string 正则表达式
 // count: int 单次迭代成员的数量
-// return: nums int 本次迭代删除的成员数量
+// return:
+//
+//	nums: int 出错前已删除的成员数量
+//	err: error
+//
 // link：https://redis.io/commands/sscan/
-func (c *Rset) ScanDel(key string, pattern string, count int) (nums int) {
-	if count < 1 {
-		count = defaultScanNum
-	}
+func (c *Rset) ScanDelE(key string, pattern string, count int) (int, error) {
+	nums := 0
 	cursor := defaultCursor
-	nums = 0
 	for {
-		args := make([]interface{}, 0)
-		args = append(args, key, cursor, "MATCH", pattern, "COUNT", count)
-
-		res, err := redis.Values(c.conn.Do("SSCAN", args...))
-		if err == nil {
-			curs, _ := redis.Int(res[0], nil)
-			cursor = curs
-			lists, _ := redis.Strings(res[1], nil)
-
-			//执行删除
-			if len(lists) > 0 {
-				num, _ := c.Srem(key, lists)
-				nums += num
+		cur, lists, err := c.ScanOnce(key, cursor, pattern, count)
+		if err != nil {
+			return nums, err
+		}
+		if len(lists) > 0 {
+			num, err := c.Srem(key, lists)
+			if err != nil {
+				return nums, err
 			}
+			nums += num
 		}
-
+		cursor = cur
 		if cursor == defaultCursor {
-			break
+			return nums, nil
 		}
 	}
-	return nums
 }
@@ -0,0 +1,453 @@
+// Redis Stream 流
+package redis
+
+import (
+	"github.com/perpower/goframe/funcs/convert"
+	"github.com/perpower/goframe/funcs/normal"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+type Rstream struct {
+	conn commander
+}
+
+// 流中的一条消息
+type StreamEntry struct {
+	ID     string            // 消息ID
+	Fields map[string]string // 消息内容 field=>value
+}
+
+// 某个流返回的消息集合，XREADGROUP等可同时读取多个流的命令使用
+type StreamMessages struct {
+	Stream  string        // 流的键名
+	Entries []StreamEntry // 消息列表
+}
+
+// 流的裁剪选项，Strategy为空表示不裁剪
+type StreamTrim struct {
+	Strategy  string // 裁剪方式，取值：MAXLEN | MINID
+	Threshold string // MAXLEN时为保留的最大长度，MINID时为保留的最小消息ID
+	Approx    bool   // 是否近似裁剪(~)，近似裁剪性能更好，实际保留的消息可能略多于阈值
+	Limit     int    // 近似裁剪时单次最多删除的消息数，0表示使用服务端默认值
+}
+
+// 消费组待确认消息汇总
+type StreamPendingSummary struct {
+	Count     int            // 待确认消息总数
+	Lower     string         // 最小消息ID
+	Upper     string         // 最大消息ID
+	Consumers map[string]int // 各消费者的待确认消息数
+}
+
+// 消费组中的一条待确认消息
+type StreamPendingEntry struct {
+	ID         string // 消息ID
+	Consumer   string // 当前所属消费者
+	Idle       int64  // 距离上次投递的毫秒数
+	Deliveries int64  // 投递次数
+}
+
+// 组装裁剪参数
+func (t StreamTrim) args() []interface{} {
+	args := make([]interface{}, 0)
+	if !normal.InArray(t.Strategy, []string{"MAXLEN", "MINID"}) || t.Threshold == "" {
+		return args
+	}
+	args = append(args, t.Strategy)
+	if t.Approx {
+		args = append(args, "~")
+	} else {
+		args = append(args, "=")
+	}
+	args = append(args, t.Threshold)
+	if t.Approx && t.Limit > 0 {
+		args = append(args, "LIMIT", t.Limit)
+	}
+	return args
+}
+
+// 解析消息列表回复 [[id, [field, value, ...]], ...]
+func parseStreamEntries(reply interface{}) []StreamEntry {
+	items, _ := redis.Values(reply, nil)
+	entries := make([]StreamEntry, 0, len(items))
+	for _, item := range items {
+		pair, _ := redis.Values(item, nil)
+		if len(pair) < 2 {
+			continue
+		}
+		id, _ := redis.String(pair[0], nil)
+		fields, _ := redis.StringMap(pair[1], nil)
+		entries = append(entries, StreamEntry{
+			ID:     id,
+			Fields: fields,
+		})
+	}
+	return entries
+}
+
+// 解析多流消息回复 [[stream, entries], ...]
+func parseStreamMessages(reply interface{}) []StreamMessages {
+	items, _ := redis.Values(reply, nil)
+	arr := make([]StreamMessages, 0, len(items))
+	for _, item := range items {
+		pair, _ := redis.Values(item, nil)
+		if len(pair) < 2 {
+			continue
+		}
+		stream, _ := redis.String(pair[0], nil)
+		arr = append(arr, StreamMessages{
+			Stream:  stream,
+			Entries: parseStreamEntries(pair[1]),
+		})
+	}
+	return arr
+}
+
+// XADD, 向流中追加一条消息，流不存在时自动创建
+// key: string 键名
+// id: string 消息ID，传空或"*"表示由服务端自动生成
+// fields: [][2]string{{field, value}, ...} 消息内容
+// trim: StreamTrim 追加的同时按条件裁剪流，不裁剪传StreamTrim{}
+// noMkStream: bool 流不存在时是否不创建，为true且流不存在时返回空
+// return: reply string 新消息的ID
+// link: https://redis.io/commands/xadd/
+func (s *Rstream) Xadd(key, id string, fields [][2]string, trim StreamTrim, noMkStream bool) (string, error) {
+	if id == "" {
+		id = "*"
+	}
+	args := make([]interface{}, 0)
+	args = append(args, key)
+	if noMkStream {
+		args = append(args, "NOMKSTREAM")
+	}
+	args = append(args, trim.args()...)
+	args = append(args, id)
+	for _, v := range fields {
+		args = append(args, v[0], v[1])
+	}
+	return redis.String(s.conn.Do("XADD", args...))
+}
+
+// XTRIM, 按条件裁剪流
+// key: string 键名
+// trim: StreamTrim 裁剪条件
+// return: reply int 被删除的消息数
+// link: https://redis.io/commands/xtrim/
+func (s *Rstream) Xtrim(key string, trim StreamTrim) (int, error) {
+	args := make([]interface{}, 0)
+	args = append(args, key)
+	args = append(args, trim.args()...)
+	return redis.Int(s.conn.Do("XTRIM", args...))
+}
+
+// XLEN, 返回流中的消息数
+// key: string 键名
+// return: reply int
+// link: https://redis.io/commands/xlen/
+func (s *Rstream) Xlen(key string) (int, error) {
+	return redis.Int(s.conn.Do("XLEN", key))
+}
+
+// XDEL, 删除流中指定ID的消息
+// key: string 键名
+// ids: []string 消息ID数组
+// return: reply int 成功删除的消息数
+// link: https://redis.io/commands/xdel/
+func (s *Rstream) Xdel(key string, ids []string) (int, error) {
+	args := make([]interface{}, 0)
+	args = append(args, key)
+	for _, v := range ids {
+		args = append(args, v)
+	}
+	return redis.Int(s.conn.Do("XDEL", args...))
+}
+
+// XRANGE, 按ID从小到大返回指定区间的消息
+// key: string 键名
+// start: string 起始ID，"-"表示最小ID，可在ID前加"("表示不包含
+// end: string 截止ID，"+"表示最大ID，可在ID前加"("表示不包含
+// count: int 最多返回的消息数，0表示不限制
+// return: reply []StreamEntry
+// link: https://redis.io/commands/xrange/
+func (s *Rstream) Xrange(key, start, end string, count int) ([]StreamEntry, error) {
+	args := make([]interface{}, 0)
+	args = append(args, key, start, end)
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	res, err := s.conn.Do("XRANGE", args...)
+	if err != nil {
+		return []StreamEntry{}, err
+	}
+	return parseStreamEntries(res), err
+}
+
+// XREVRANGE, 按ID从大到小返回指定区间的消息
+// key: string 键名
+// end: string 起始ID(较大者)，"+"表示最大ID
+// start: string 截止ID(较小者)，"-"表示最小ID
+// count: int 最多返回的消息数，0表示不限制
+// return: reply []StreamEntry
+// link: https://redis.io/commands/xrevrange/
+func (s *Rstream) Xrevrange(key, end, start string, count int) ([]StreamEntry, error) {
+	args := make([]interface{}, 0)
+	args = append(args, key, end, start)
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	res, err := s.conn.Do("XREVRANGE", args...)
+	if err != nil {
+		return []StreamEntry{}, err
+	}
+	return parseStreamEntries(res), err
+}
+
+// XGROUP CREATE, 创建消费组
+// key: string 键名
+// group: string 消费组名
+// id: string 消费组起始消息ID，"$"表示只消费之后的新消息，"0"表示从头消费
+// mkStream: bool 流不存在时是否自动创建
+// return: 始终返回"OK"
+// link: https://redis.io/commands/xgroup-create/
+func (s *Rstream) XgroupCreate(key, group, id string, mkStream bool) (string, error) {
+	if id == "" {
+		id = "$"
+	}
+	if mkStream {
+		return redis.String(s.conn.Do("XGROUP", "CREATE", key, group, id, "MKSTREAM"))
+	}
+	return redis.String(s.conn.Do("XGROUP", "CREATE", key, group, id))
+}
+
+// XGROUP DESTROY, 删除消费组
+// key: string 键名
+// group: string 消费组名
+// return: reply int 被删除的消费组数量
+// link: https://redis.io/commands/xgroup-destroy/
+func (s *Rstream) XgroupDestroy(key, group string) (int, error) {
+	return redis.Int(s.conn.Do("XGROUP", "DESTROY", key, group))
+}
+
+// XGROUP SETID, 设置消费组的最后投递ID
+// key: string 键名
+// group: string 消费组名
+// id: string 消息ID，"$"表示流的最后一条消息
+// return: 始终返回"OK"
+// link: https://redis.io/commands/xgroup-setid/
+func (s *Rstream) XgroupSetId(key, group, id string) (string, error) {
+	return redis.String(s.conn.Do("XGROUP", "SETID", key, group, id))
+}
+
+// XGROUP CREATECONSUMER, 在消费组中创建消费者
+// since: 6.2.0
+// key: string 键名
+// group: string 消费组名
+// consumer: string 消费者名
+// return: reply int 新创建的消费者数量
+// link: https://redis.io/commands/xgroup-createconsumer/
+func (s *Rstream) XgroupCreateConsumer(key, group, consumer string) (int, error) {
+	return redis.Int(s.conn.Do("XGROUP", "CREATECONSUMER", key, group, consumer))
+}
+
+// XGROUP DELCONSUMER, 从消费组中删除消费者，该消费者的待确认消息会一并丢失
+// key: string 键名
+// group: string 消费组名
+// consumer: string 消费者名
+// return: reply int 被删除消费者的待确认消息数
+// link: https://redis.io/commands/xgroup-delconsumer/
+func (s *Rstream) XgroupDelConsumer(key, group, consumer string) (int, error) {
+	return redis.Int(s.conn.Do("XGROUP", "DELCONSUMER", key, group, consumer))
+}
+
+// XREADGROUP, 以消费组中某个消费者的身份读取消息
+// group: string 消费组名
+// consumer: string 消费者名
+// streams: [][2]string{{key, id}, ...} 读取的流及起始ID，">"表示读取从未投递给任何消费者的新消息，
+// 其他ID表示读取该消费者自己已投递但未确认的消息
+// count: int 每个流最多返回的消息数，0表示不限制
+// block: int 无消息时阻塞等待的毫秒数，0表示一直阻塞，小于0表示不阻塞，
+// 使用阻塞读取时，Config.ReadTimeout需大于阻塞时间
+// noAck: bool 是否无需确认，为true时消息投递后即视为已确认
+// return: reply []StreamMessages 超时无消息时返回空数组
+// link: https://redis.io/commands/xreadgroup/
+func (s *Rstream) XreadGroup(group, consumer string, streams [][2]string, count, block int, noAck bool) ([]StreamMessages, error) {
+	args := make([]interface{}, 0)
+	args = append(args, "GROUP", group, consumer)
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	if block >= 0 {
+		args = append(args, "BLOCK", block)
+	}
+	if noAck {
+		args = append(args, "NOACK")
+	}
+	args = append(args, "STREAMS")
+	for _, v := range streams {
+		args = append(args, v[0])
+	}
+	for _, v := range streams {
+		args = append(args, v[1])
+	}
+
+	res, err := s.conn.Do("XREADGROUP", args...)
+	if err != nil || res == nil {
+		return []StreamMessages{}, err
+	}
+	return parseStreamMessages(res), err
+}
+
+// XREAD, 从一个或多个流中读取ID大于指定ID的消息，不使用消费组
+// streams: [][2]string{{key, id}, ...} 读取的流及起始ID，"$"表示只读取之后的新消息
+// count: int 每个流最多返回的消息数，0表示不限制
+// block: int 无消息时阻塞等待的毫秒数，0表示一直阻塞，小于0表示不阻塞
+// return: reply []StreamMessages 超时无消息时返回空数组
+// link: https://redis.io/commands/xread/
+func (s *Rstream) Xread(streams [][2]string, count, block int) ([]StreamMessages, error) {
+	args := make([]interface{}, 0)
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	if block >= 0 {
+		args = append(args, "BLOCK", block)
+	}
+	args = append(args, "STREAMS")
+	for _, v := range streams {
+		args = append(args, v[0])
+	}
+	for _, v := range streams {
+		args = append(args, v[1])
+	}
+
+	res, err := s.conn.Do("XREAD", args...)
+	if err != nil || res == nil {
+		return []StreamMessages{}, err
+	}
+	return parseStreamMessages(res), err
+}
+
+// XACK, 确认消费组中的消息已处理，确认后从待确认列表中移除
+// key: string 键名
+// group: string 消费组名
+// ids: []string 消息ID数组
+// return: reply int 成功确认的消息数
+// link: https://redis.io/commands/xack/
+func (s *Rstream) Xack(key, group string, ids []string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	args := make([]interface{}, 0)
+	args = append(args, key, group)
+	for _, v := range ids {
+		args = append(args, v)
+	}
+	return redis.Int(s.conn.Do("XACK", args...))
+}
+
+// XPENDING, 返回消费组待确认消息的汇总信息
+// key: string 键名
+// group: string 消费组名
+// return: reply StreamPendingSummary
+// link: https://redis.io/commands/xpending/
+func (s *Rstream) XpendingSummary(key, group string) (StreamPendingSummary, error) {
+	summary := StreamPendingSummary{
+		Consumers: map[string]int{},
+	}
+	res, err := redis.Values(s.conn.Do("XPENDING", key, group))
+	if err != nil || len(res) < 4 {
+		return summary, err
+	}
+
+	summary.Count, _ = redis.Int(res[0], nil)
+	summary.Lower, _ = redis.String(res[1], nil)
+	summary.Upper, _ = redis.String(res[2], nil)
+	consumers, _ := redis.Values(res[3], nil)
+	for _, v := range consumers {
+		pair, _ := redis.Strings(v, nil)
+		if len(pair) == 2 {
+			summary.Consumers[pair[0]] = convert.Int(pair[1])
+		}
+	}
+	return summary, err
+}
+
+// XPENDING, 返回消费组待确认消息的明细
+// key: string 键名
+// group: string 消费组名
+// start: string 起始ID，"-"表示最小ID
+// end: string 截止ID，"+"表示最大ID
+// count: int 最多返回的消息数
+// consumer: string 只返回指定消费者的消息，不指定传空
+// minIdle: int 只返回空闲时间不小于该值(毫秒)的消息，0表示不过滤
+// return: reply []StreamPendingEntry
+// link: https://redis.io/commands/xpending/
+func (s *Rstream) Xpending(key, group, start, end string, count int, consumer string, minIdle int) ([]StreamPendingEntry, error) {
+	if count < 1 {
+		count = defaultScanNum
+	}
+	args := make([]interface{}, 0)
+	args = append(args, key, group)
+	if minIdle > 0 {
+		args = append(args, "IDLE", minIdle)
+	}
+	args = append(args, start, end, count)
+	if consumer != "" {
+		args = append(args, consumer)
+	}
+
+	res, err := redis.Values(s.conn.Do("XPENDING", args...))
+	if err != nil {
+		return []StreamPendingEntry{}, err
+	}
+	arr := make([]StreamPendingEntry, 0, len(res))
+	for _, v := range res {
+		item, _ := redis.Values(v, nil)
+		if len(item) < 4 {
+			continue
+		}
+		entry := StreamPendingEntry{}
+		entry.ID, _ = redis.String(item[0], nil)
+		entry.Consumer, _ = redis.String(item[1], nil)
+		entry.Idle, _ = redis.Int64(item[2], nil)
+		entry.Deliveries, _ = redis.Int64(item[3], nil)
+		arr = append(arr, entry)
+	}
+	return arr, err
+}
+
+// XAUTOCLAIM, 将空闲时间超过minIdle的待确认消息转移给指定消费者，用于接管宕机消费者未处理的消息
+// since: 6.2.0
+// key: string 键名
+// group: string 消费组名
+// consumer: string 接收消息的消费者
+// minIdle: int 最小空闲时间，单位ms
+// start: string 起始ID，首次传"0-0"，之后传上一次返回的next
+// count: int 单次最多转移的消息数
+// return:
+//
+//	next: string 下一次调用的起始ID，为"0-0"表示已遍历完
+//	entries: []StreamEntry 成功转移的消息
+//	deleted: []string 待确认列表中已不存在于流中的消息ID，7.0.0以上返回
+//
+// link: https://redis.io/commands/xautoclaim/
+func (s *Rstream) Xautoclaim(key, group, consumer string, minIdle int, start string, count int) (next string, entries []StreamEntry, deleted []string, err error) {
+	if start == "" {
+		start = "0-0"
+	}
+	if count < 1 {
+		count = defaultScanNum
+	}
+	res, err := redis.Values(s.conn.Do("XAUTOCLAIM", key, group, consumer, minIdle, start, "COUNT", count))
+	if err != nil || len(res) < 2 {
+		return "", []StreamEntry{}, []string{}, err
+	}
+
+	next, _ = redis.String(res[0], nil)
+	entries = parseStreamEntries(res[1])
+	deleted = []string{}
+	if len(res) > 2 {
+		deleted, _ = redis.Strings(res[2], nil)
+	}
+	return next, entries, deleted, err
+}
@@ -9,7 +9,7 @@ import (
 )
 
 type Rstring struct {
-	conn redis.Conn
+	conn commander
 }
 
 // SET--全参数功能方法
@@ -0,0 +1,179 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/perpower/goframe/utils/pdb/redis/redistest"
+)
+
+func TestSetConfigDefaults(t *testing.T) {
+	cases := []struct {
+		in                        Config
+		maxIdle, maxActive, idleS int
+	}{
+		{Config{}, defaultMaxIdle, defaultMaxActive, defaultIdleTimeout},
+		{Config{MaxIdle: 3, MaxActive: 7, IdleTimeout: 30}, 3, 7, 30},
+		{Config{MaxIdle: -1, MaxActive: -1, IdleTimeout: -1}, 0, 0, 0},
+	}
+	for _, c := range cases {
+		got := SetConfig(c.in)
+		if got.MaxIdle != c.maxIdle || got.MaxActive != c.maxActive || got.IdleTimeout != c.idleS {
+			t.Errorf("SetConfig(%+v) = MaxIdle %d MaxActive %d IdleTimeout %d", c.in, got.MaxIdle, got.MaxActive, got.IdleTimeout)
+		}
+	}
+}
+
+func TestPoolReusesIdleConnections(t *testing.T) {
+	s := redistest.NewServer(t)
+	client, err := New(Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 100; i++ {
+		if _, err := client.String.Set("k", strconv.Itoa(i), "", "", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 默认保留空闲连接，顺序执行的命令复用同一个连接
+	if n := s.Accepted(); n != 1 {
+		t.Fatalf("accepted %d connections, want 1", n)
+	}
+	stats := client.Stats()
+	if stats.ActiveCount != 1 || stats.IdleCount != 1 {
+		t.Fatalf("stats = %+v, want 1 active and 1 idle", stats)
+	}
+}
+
+func TestConcurrentCommands(t *testing.T) {
+	s := redistest.NewServer(t, func(s *redistest.Server) { s.Password = "secret" })
+	const maxActive = 8
+	client, err := New(Config{
+		Address:   s.Addr(),
+		Password:  "secret",
+		MaxIdle:   maxActive,
+		MaxActive: maxActive,
+		Wait:      true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	const workers, rounds = 50, 40
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			key := fmt.Sprintf("worker:%d", w)
+			for i := 0; i < rounds; i++ {
+				if _, err := client.String.Incr("counter"); err != nil {
+					errs <- err
+					return
+				}
+				value := fmt.Sprintf("%d-%d", w, i)
+				if _, err := client.Hash.Hset(key, [][2]string{{"v", value}}); err != nil {
+					errs <- err
+					return
+				}
+				got, err := client.Hash.Hget(key, "v")
+				if err != nil || got != value {
+					errs <- fmt.Errorf("worker %d read %q, %v, want %q", w, got, err, value)
+					return
+				}
+				if stats := client.Stats(); stats.ActiveCount > maxActive {
+					errs <- fmt.Errorf("active connections %d exceed MaxActive", stats.ActiveCount)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	n, err := client.String.Get("counter")
+	if err != nil || n != strconv.Itoa(workers*rounds) {
+		t.Fatalf("counter = %q, %v, want %d", n, err, workers*rounds)
+	}
+	// 空闲连接数上限与最大连接数一致时，归还的连接全部保留复用，不会重复建立连接
+	if accepted := s.Accepted(); accepted > maxActive {
+		t.Fatalf("accepted %d connections, want at most %d", accepted, maxActive)
+	}
+	stats := client.Stats()
+	if stats.ActiveCount > maxActive || stats.IdleCount == 0 || stats.ActiveCount != stats.IdleCount {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestDroppedConnectionIsReplaced(t *testing.T) {
+	s := redistest.NewServer(t)
+	client, err := New(Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	s.DropConns()
+	// 断开的空闲连接最多导致一次失败，之后的命令使用新建立的连接
+	var lastErr error
+	for i := 0; i < 2; i++ {
+		if lastErr = client.Ping(); lastErr == nil {
+			break
+		}
+	}
+	if lastErr != nil {
+		t.Fatalf("ping after reconnect: %v", lastErr)
+	}
+	if n := s.Accepted(); n != 2 {
+		t.Fatalf("accepted %d connections, want 2", n)
+	}
+}
+
+func TestConnStateCommandsRequirePin(t *testing.T) {
+	s := redistest.NewServer(t)
+	client, err := New(Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, err := client.Do("MULTI"); err != ErrPinRequired {
+		t.Fatalf("MULTI on pool: %v, want ErrPinRequired", err)
+	}
+	pinned, err := client.Pin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pinned.Do("MULTI"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pinned.Do("DISCARD"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pinned.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := client.Stats(); stats.ActiveCount != stats.IdleCount {
+		t.Fatalf("pinned connection not returned: %+v", stats)
+	}
+}
+
+func TestNewReturnsAuthError(t *testing.T) {
+	s := redistest.NewServer(t, func(s *redistest.Server) { s.Password = "secret" })
+	if _, err := New(Config{Address: s.Addr(), Password: "wrong"}); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Fatalf("New with wrong password: %v", err)
+	}
+	if _, err := New(Config{Address: s.Addr()}); err == nil {
+		t.Fatal("New without password should fail")
+	}
+}
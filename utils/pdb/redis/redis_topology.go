@@ -0,0 +1,277 @@
+// Redis 部署拓扑：单机、哨兵、集群
+// 哨兵模式下通过哨兵查询主节点地址，主从切换后(连接断开或收到READONLY)自动重新查询，并丢弃连接池中指向旧主节点的连接；
+// 集群模式见 redis_cluster.go
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// 部署模式
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
+)
+
+// topology 部署拓扑，负责节点发现及连接管理
+type topology interface {
+	pin(ctx context.Context, key string) (redis.Conn, error) // 取出一个独占连接，集群模式下为key所在槽位的主节点
+	addr(ctx context.Context) (string, error)                // 订阅等需要专用连接的场景使用的节点地址
+	stats() PoolStats
+	close() error
+}
+
+// standalone 单机模式
+type standalone struct {
+	pool    *redis.Pool
+	address string
+}
+
+// sentinel 哨兵模式，连接池中的连接均指向当前主节点
+type sentinel struct {
+	conf   *Config
+	pool   *redis.Pool
+	mu     sync.RWMutex
+	addrs  []string // 哨兵地址，最近一次查询成功的排在最前
+	master string   // 当前主节点地址
+}
+
+// nodeConn 记录连接所属节点地址，用于主从切换后识别指向旧节点的连接
+type nodeConn struct {
+	redis.Conn
+	addr string
+}
+
+var (
+	ErrSentinelConfig = errors.New("redis: sentinel模式需要配置MasterName及哨兵地址Addrs")
+	ErrMasterNotFound = errors.New("redis: 哨兵未返回主节点地址")
+	ErrNotMaster      = errors.New("redis: 连接的节点不是主节点")
+	errStaleNode      = errors.New("redis: 连接指向的节点已不是当前主节点")
+)
+
+// 创建连接池，check不为空时每次取出连接都会先校验
+func newPool(conf *Config, dialer func(ctx context.Context) (redis.Conn, error), check func(conn redis.Conn) error) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:         conf.MaxIdle,
+		MaxActive:       conf.MaxActive,
+		IdleTimeout:     time.Duration(conf.IdleTimeout) * time.Second,
+		MaxConnLifetime: time.Duration(conf.MaxConnLifetime) * time.Second,
+		Wait:            conf.Wait,
+		DialContext:     dialer,
+		// 空闲较久的连接在取出时先检测可用性，已断开的连接会被丢弃并重新建立
+		TestOnBorrow: func(conn redis.Conn, t time.Time) error {
+			if check != nil {
+				if err := check(conn); err != nil {
+					return err
+				}
+			}
+			if time.Since(t) < testOnBorrowIdle {
+				return nil
+			}
+			_, err := conn.Do("PING")
+			return err
+		},
+	}
+}
+
+func newStandalone(conf *Config) *standalone {
+	return &standalone{
+		pool: newPool(conf, func(ctx context.Context) (redis.Conn, error) {
+			return dial(ctx, conf, conf.Address)
+		}, nil),
+		address: conf.Address,
+	}
+}
+
+func (s *standalone) pin(ctx context.Context, key string) (redis.Conn, error) {
+	return s.pool.GetContext(ctx)
+}
+
+func (s *standalone) addr(ctx context.Context) (string, error) {
+	return s.address, nil
+}
+
+func (s *standalone) stats() PoolStats {
+	return s.pool.Stats()
+}
+
+func (s *standalone) close() error {
+	return s.pool.Close()
+}
+
+func newSentinel(conf *Config) *sentinel {
+	s := &sentinel{
+		conf:  conf,
+		addrs: append([]string{}, conf.Addrs...),
+	}
+	s.pool = newPool(conf, s.dial, s.check)
+	return s
+}
+
+func (s *sentinel) pin(ctx context.Context, key string) (redis.Conn, error) {
+	return s.pool.GetContext(ctx)
+}
+
+func (s *sentinel) addr(ctx context.Context) (string, error) {
+	return s.masterAddr(ctx)
+}
+
+func (s *sentinel) stats() PoolStats {
+	return s.pool.Stats()
+}
+
+func (s *sentinel) close() error {
+	return s.pool.Close()
+}
+
+// 返回当前主节点地址，尚未查询过时向哨兵查询
+func (s *sentinel) masterAddr(ctx context.Context) (string, error) {
+	s.mu.RLock()
+	master := s.master
+	s.mu.RUnlock()
+	if master != "" {
+		return master, nil
+	}
+	return s.discover(ctx)
+}
+
+// 依次向各哨兵查询主节点地址
+func (s *sentinel) discover(ctx context.Context) (string, error) {
+	s.mu.RLock()
+	addrs := append([]string{}, s.addrs...)
+	s.mu.RUnlock()
+	if s.conf.MasterName == "" || len(addrs) == 0 {
+		return "", ErrSentinelConfig
+	}
+
+	var lastErr error
+	for i, addr := range addrs {
+		master, err := s.query(ctx, addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		s.mu.Lock()
+		s.master = master
+		if i > 0 {
+			s.addrs = append([]string{addr}, append(addrs[:i:i], addrs[i+1:]...)...)
+		}
+		s.mu.Unlock()
+		return master, nil
+	}
+	return "", lastErr
+}
+
+// SENTINEL GET-MASTER-ADDR-BY-NAME 向指定哨兵查询主节点地址
+func (s *sentinel) query(ctx context.Context, addr string) (string, error) {
+	conf := *s.conf
+	conf.Username = s.conf.SentinelUsername
+	conf.Password = s.conf.SentinelPassword
+	conf.Database = 0
+	conn, err := dial(ctx, &conf, addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	res, err := redis.Strings(redis.DoContext(conn, ctx, "SENTINEL", "GET-MASTER-ADDR-BY-NAME", s.conf.MasterName))
+	if err == redis.ErrNil || (err == nil && len(res) != 2) {
+		return "", ErrMasterNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(res[0], res[1]), nil
+}
+
+// 连接池建立新连接，主节点不可达或已降级时重新向哨兵查询后再试一次
+func (s *sentinel) dial(ctx context.Context) (redis.Conn, error) {
+	addr, err := s.masterAddr(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := s.dialMaster(ctx, addr)
+	if err == nil {
+		return conn, nil
+	}
+	fresh, derr := s.discover(ctx)
+	if derr != nil || fresh == addr {
+		return nil, err
+	}
+	return s.dialMaster(ctx, fresh)
+}
+
+// 连接主节点，并通过ROLE确认其角色
+func (s *sentinel) dialMaster(ctx context.Context, addr string) (redis.Conn, error) {
+	conn, err := dial(ctx, s.conf, addr)
+	if err != nil {
+		return nil, err
+	}
+	role, err := redis.Values(redis.DoContext(conn, ctx, "ROLE"))
+	if err == nil && len(role) > 0 {
+		if name, _ := redis.String(role[0], nil); name != "master" {
+			err = ErrNotMaster
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &nodeConn{Conn: conn, addr: addr}, nil
+}
+
+// 丢弃指向旧主节点的连接
+func (s *sentinel) check(conn redis.Conn) error {
+	nc, ok := conn.(*nodeConn)
+	if !ok {
+		return nil
+	}
+	s.mu.RLock()
+	master := s.master
+	s.mu.RUnlock()
+	if master != "" && nc.addr != master {
+		return errStaleNode
+	}
+	return nil
+}
+
+// 命令执行出错时判断是否发生了主从切换，是则重新查询主节点
+// return: bool 命令未被执行，可在新主节点上重试
+func (s *sentinel) failover(ctx context.Context, err error) bool {
+	if e, ok := err.(redis.Error); ok {
+		if !strings.HasPrefix(string(e), "READONLY") {
+			return false
+		}
+		_, derr := s.discover(ctx)
+		return derr == nil
+	}
+	if ctx.Err() == nil {
+		// 连接级错误，主节点可能已宕机，命令是否已执行无法确定，不重试
+		s.discover(ctx)
+	}
+	return false
+}
+
+func (n *nodeConn) DoContext(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	return redis.DoContext(n.Conn, ctx, commandName, args...)
+}
+
+func (n *nodeConn) ReceiveContext(ctx context.Context) (interface{}, error) {
+	return redis.ReceiveContext(n.Conn, ctx)
+}
+
+func (n *nodeConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	return redis.DoWithTimeout(n.Conn, timeout, commandName, args...)
+}
+
+func (n *nodeConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return redis.ReceiveWithTimeout(n.Conn, timeout)
+}
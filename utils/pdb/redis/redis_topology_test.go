@@ -0,0 +1,104 @@
+package redis
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/perpower/goframe/utils/pdb/redis/redistest"
+)
+
+// standinSentinel 模拟哨兵，返回当前主节点地址
+type standinSentinel struct {
+	*redistest.Server
+	mu     sync.Mutex
+	master string
+}
+
+func newStandinSentinel(t *testing.T, master string) *standinSentinel {
+	ss := &standinSentinel{master: master}
+	ss.Server = redistest.NewServer(t, func(s *redistest.Server) {
+		s.Hook = func(c *redistest.Conn, args []string) (interface{}, bool) {
+			if strings.ToUpper(args[0]) != "SENTINEL" {
+				return nil, false
+			}
+			if len(args) != 3 || strings.ToUpper(args[1]) != "GET-MASTER-ADDR-BY-NAME" || args[2] != "mymaster" {
+				return []interface{}(nil), true
+			}
+			ss.mu.Lock()
+			defer ss.mu.Unlock()
+			host, port, _ := net.SplitHostPort(ss.master)
+			return []interface{}{host, port}, true
+		}
+	})
+	return ss
+}
+
+func (ss *standinSentinel) failover(master string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.master = master
+}
+
+// newDemotable 启动可降级的节点，调用返回的函数后ROLE返回slave，写命令返回READONLY
+func newDemotable(t *testing.T) (*redistest.Server, func()) {
+	var demoted int32
+	s := redistest.NewServer(t, func(s *redistest.Server) {
+		s.Hook = func(c *redistest.Conn, args []string) (interface{}, bool) {
+			if atomic.LoadInt32(&demoted) == 0 {
+				return nil, false
+			}
+			switch strings.ToUpper(args[0]) {
+			case "ROLE":
+				return []interface{}{"slave", "127.0.0.1", int64(0), "connected", int64(0)}, true
+			case "SET", "INCR", "DEL":
+				return redistest.Error("READONLY You can't write against a read only replica."), true
+			}
+			return nil, false
+		}
+	})
+	return s, func() { atomic.StoreInt32(&demoted, 1) }
+}
+
+func TestSentinelFailover(t *testing.T) {
+	first, demote := newDemotable(t)
+	second := redistest.NewServer(t)
+	sentinel := newStandinSentinel(t, first.Addr())
+	client, err := New(Config{Mode: ModeSentinel, Addrs: []string{sentinel.Addr()}, MasterName: "mymaster"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, err := client.String.Set("k", "1", "", "", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// 主从切换：旧主节点降级后写命令返回READONLY，客户端重新查询哨兵并在新主节点上重试
+	sentinel.failover(second.Addr())
+	demote()
+	if _, err := client.String.Set("k", "2", "", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := second.Do("GET", "k").(string)
+	if got != "2" {
+		t.Fatalf("new master has k = %q, want 2", got)
+	}
+
+	// 主节点宕机：本次命令返回连接错误(是否已执行无法确定，不重试)，之后的命令发往新主节点
+	third := redistest.NewServer(t)
+	sentinel.failover(third.Addr())
+	second.Close()
+	if _, err := client.String.Set("k", "3", "", "", 0); err == nil {
+		t.Fatal("the command sent to the dead master should fail")
+	}
+	if _, err := client.String.Set("k", "4", "", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = third.Do("GET", "k").(string)
+	if got != "4" {
+		t.Fatalf("third master has k = %q, want 4", got)
+	}
+}
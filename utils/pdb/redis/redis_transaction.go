@@ -1,10 +1,18 @@
 // Redis transaction 事务管理
+// 特别说明：事务命令依赖连接状态，必须在Client.Pin()返回的独占连接上执行，例如：
+//
+//	conn, err := client.Pin()
+//	if err != nil {
+//		return err
+//	}
+//	defer conn.Close()
+//	conn.Transaction.Multi()
 package redis
 
 import "github.com/gomodule/redigo/redis"
 
 type Rtransaction struct {
-	conn redis.Conn
+	conn commander
 }
 
 // UNWATCH 解除所有key的监视
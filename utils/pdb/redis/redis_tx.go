@@ -0,0 +1,171 @@
+// Redis 乐观锁事务(WATCH + MULTI/EXEC)
+// 回调中通过 tx.Conn 读取被监视的key(立即执行)，通过 tx 上的各类型操作对象排队写命令(EXEC时原子执行)，
+// 被监视的key在EXEC前被其他客户端修改时，整个回调会自动重试，例如：
+//
+//	var left *redis.Cmd[int]
+//	_, err := client.Tx([]string{"stock"}, func(tx *redis.Tx) error {
+//		n, err := tx.Conn.String.Get("stock")
+//		if err != nil {
+//			return err
+//		}
+//		if convert.Int(n) <= 0 {
+//			return errSoldOut
+//		}
+//		left = redis.Queue(tx, func() (int, error) { return tx.String.Decr("stock") })
+//		return nil
+//	})
+//	if err == nil {
+//		n, err := left.Result()
+//	}
+//
+// 通过 Queue 排队的命令在EXEC成功后得到类型化结果；重试时回调重新执行，在回调外保存的结果应在回调中重新赋值
+package redis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+type Tx struct {
+	queued
+	Conn *Client // WATCH所在的独占连接，读操作在此立即执行
+}
+
+var (
+	defaultTxRetries = 3 // 乐观锁冲突默认重试次数
+
+	ErrTxFailed = errors.New("redis: 被监视的key已被修改，事务未执行")
+)
+
+// Tx 执行乐观锁事务
+// keys: []string 需要WATCH监视的key
+// fn: func(tx *Tx) error 事务回调，返回error时事务取消，该error原样返回
+// return:
+//
+//	replies: []*Reply 与排队顺序一致的各命令执行结果
+//	err: 重试次数用尽时返回 ErrTxFailed；EXEC执行后有命令失败时返回第一条失败命令的错误，其他命令已执行，结果仍在replies中
+func (c *Client) Tx(keys []string, fn func(tx *Tx) error) ([]*Reply, error) {
+	retries := c.config.TxRetries
+	if retries <= 0 {
+		retries = defaultTxRetries
+	}
+
+	for i := 0; i <= retries; i++ {
+		replies, err := c.txOnce(keys, fn)
+		if err != ErrTxFailed {
+			return replies, err
+		}
+	}
+	return nil, ErrTxFailed
+}
+
+// 执行一次事务
+func (c *Client) txOnce(keys []string, fn func(tx *Tx) error) ([]*Reply, error) {
+	// 集群模式下事务在第一个被监视key所在的节点上执行
+	key := ""
+	if len(keys) > 0 {
+		key = keys[0]
+	}
+	conn, err := c.Pin(key)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if len(keys) > 0 {
+		if _, err := conn.Transaction.Watch(keys); err != nil {
+			return nil, err
+		}
+	}
+
+	tx := &Tx{
+		queued: newQueued(),
+		Conn:   conn,
+	}
+	if err := fn(tx); err != nil {
+		return nil, err
+	}
+
+	cmds := tx.queue.cmds
+	if len(cmds) == 0 {
+		return []*Reply{}, nil
+	}
+
+	ctx := conn.ctx
+	if conn.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conn.timeout)
+		defer cancel()
+	}
+	conn.ns.queue(cmds)
+	var sendErr error
+	err = conn.hooks.batch(ctx, "MULTI", cmds, func(ctx context.Context) error {
+		sendErr = sendMulti(ctx, conn.exec, cmds)
+		return batchErr(sendErr, cmds)
+	})
+	if sendErr != nil {
+		return nil, sendErr
+	}
+	// EXEC已执行，单条命令失败不影响其他命令，与Pipeline一致返回第一条命令的错误及全部结果
+	conn.ns.replies(cmds)
+	tx.queue.decode(tx.queue.decoders)
+
+	replies := make([]*Reply, 0, len(cmds))
+	for _, cmd := range cmds {
+		replies = append(replies, cmd.reply)
+	}
+	return replies, err
+}
+
+// 以 MULTI/EXEC 包裹批量发送命令，EXEC的数组回复按顺序写入各命令的Reply中
+func sendMulti(ctx context.Context, exec executor, cmds []*queuedCmd) error {
+	conner, ok := exec.(connector)
+	if !ok {
+		return ErrNoConnector
+	}
+	conn, release, err := conner.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := conn.Send("MULTI"); err != nil {
+		return err
+	}
+	for _, cmd := range cmds {
+		if err := conn.Send(cmd.commandName, cmd.args...); err != nil {
+			return err
+		}
+	}
+	if err := conn.Send("EXEC"); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+
+	// MULTI 及每条排队命令的 QUEUED 回复，命令语法错误时EXEC会返回EXECABORT，此处无需单独处理
+	for i := 0; i <= len(cmds); i++ {
+		if _, err := redis.ReceiveContext(conn, ctx); err != nil {
+			if _, ok := err.(redis.Error); !ok {
+				return err
+			}
+		}
+	}
+
+	values, err := redis.Values(redis.ReceiveContext(conn, ctx))
+	if err == redis.ErrNil {
+		return ErrTxFailed
+	}
+	if err != nil {
+		return err
+	}
+	for i, cmd := range cmds {
+		if i < len(values) {
+			cmd.reply.set(values[i], nil)
+		}
+	}
+	return nil
+}
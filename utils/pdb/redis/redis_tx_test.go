@@ -0,0 +1,105 @@
+package redis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/perpower/goframe/utils/pdb/redis/redistest"
+)
+
+func TestTxTypedResultsAfterRetry(t *testing.T) {
+	s := redistest.NewServer(t)
+	client, err := New(Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.String.Set("stock", "10", "", "", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	attempts := 0
+	var left *Cmd[int]
+	var stock *Cmd[string]
+	_, err = client.Tx([]string{"stock"}, func(tx *Tx) error {
+		attempts++
+		if attempts == 1 {
+			// 第一次执行时其他客户端修改了被监视的key，事务应重试
+			if _, err := client.String.Decr("stock"); err != nil {
+				return err
+			}
+		}
+		left = Queue(tx, func() (int, error) { return tx.String.Decr("stock") })
+		stock = Queue(tx, func() (string, error) { return tx.String.Get("stock") })
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Fatalf("attempts = %d, want 2", attempts)
+	}
+	if v, err := left.Result(); err != nil || v != 8 {
+		t.Fatalf("left = %d, %v, want 8", v, err)
+	}
+	if v, err := stock.Result(); err != nil || v != "8" {
+		t.Fatalf("stock = %q, %v, want 8", v, err)
+	}
+}
+
+func TestTxFailedLeavesHandlesUnexecuted(t *testing.T) {
+	s := redistest.NewServer(t)
+	client, err := New(Config{Address: s.Addr(), TxRetries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var left *Cmd[int]
+	_, err = client.Tx([]string{"stock"}, func(tx *Tx) error {
+		if _, err := client.String.Incr("stock"); err != nil {
+			return err
+		}
+		left = Queue(tx, func() (int, error) { return tx.String.Decr("stock") })
+		return nil
+	})
+	if err != ErrTxFailed {
+		t.Fatalf("err = %v, want ErrTxFailed", err)
+	}
+	if left.Err() != ErrNotExecuted {
+		t.Fatalf("left err = %v, want ErrNotExecuted", left.Err())
+	}
+}
+
+func TestTxReturnsFirstCommandError(t *testing.T) {
+	s := redistest.NewServer(t)
+	client, err := New(Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.String.Set("name", "abc", "", "", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var incr *Cmd[int]
+	replies, err := client.Tx([]string{"name"}, func(tx *Tx) error {
+		tx.String.Set("other", "1", "", "", 0)
+		incr = Queue(tx, func() (int, error) { return tx.String.Incr("name") })
+		tx.String.Incr("other")
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "not an integer") {
+		t.Fatalf("err = %v, want the INCR error", err)
+	}
+	// 事务中其他命令已执行
+	if len(replies) != 3 || replies[0].Err() != nil || replies[1].Err() == nil {
+		t.Fatalf("replies = %v", replies)
+	}
+	if n, err := replies[2].Int(); err != nil || n != 2 {
+		t.Fatalf("INCR other = %d, %v, want 2", n, err)
+	}
+	if incr.Err() == nil {
+		t.Fatal("the failed command's handle should carry its error")
+	}
+}
@@ -2,100 +2,127 @@
 package redis
 
 import (
+	"errors"
+
 	"github.com/perpower/goframe/funcs/normal"
 
 	"github.com/gomodule/redigo/redis"
 )
 
 type Rzset struct {
-	conn redis.Conn
+	conn commander
+}
+
+// 有序集合成员
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// ZADD 选项
+type ZaddOptions struct {
+	// 取值 XX | NX, 不指定传空
+	//	XX：只更新已经存在的元素。 不要添加新元素。
+	//	NX：只添加新元素。 不要更新已经存在的元素。
+	Condition string
+	// 取值 LT | GT, 不指定传空，与NX相互排斥
+	//	LT：如果新分数小于当前分数，则只更新现有元素。 此标志不会阻止添加新元素。
+	//	GT：如果新分数大于当前分数，则仅更新现有元素。 此标志不会阻止添加新元素。
+	Compare string
+	// 指定该参数时，会将返回值修改，由原先的返回新添加的元素个数，变为返回变更的元素+新添加的元素总数
+	Ch bool
+}
+
+var ErrZaddOptions = errors.New("redis: ZADD选项不正确，Condition取值 XX | NX，Compare取值 LT | GT，且NX与LT、GT相互排斥")
+
+// 校验选项取值及互斥关系，并转换为命令参数
+func (o ZaddOptions) args() ([]interface{}, error) {
+	if o.Condition != "" && !normal.InArray(o.Condition, []string{"XX", "NX"}) {
+		return nil, ErrZaddOptions
+	}
+	if o.Compare != "" && !normal.InArray(o.Compare, []string{"LT", "GT"}) {
+		return nil, ErrZaddOptions
+	}
+	if o.Condition == "NX" && o.Compare != "" {
+		return nil, ErrZaddOptions
+	}
+
+	args := make([]interface{}, 0, 3)
+	if o.Condition != "" {
+		args = append(args, o.Condition)
+	}
+	if o.Compare != "" {
+		args = append(args, o.Compare)
+	}
+	if o.Ch {
+		args = append(args, "CH")
+	}
+	return args, nil
 }
 
 // ZADD, 将具有指定score的所有指定成员添加到key中，如果key不存在，则创建。此方法不添加"INCR"参数
 // 如果指定的成员已经是排序集的成员，则更新score并将元素重新插入正确的位置以确保正确的排序。
-// 注意：GT、LT 和 NX 选项相互排斥。
+// 注意：GT、LT 和 NX 选项相互排斥，同时指定时返回 ErrZaddOptions。
 // since: 6.2.0
 // key: string 键名
-// elementCondition: string , 取值 XX | NX, 不指定传空
-//
-//	XX：只更新已经存在的元素。 不要添加新元素。
-//	NX：只添加新元素。 不要更新已经存在的元素。
-//
-// scoreCondition: string, 取值 LT | GT, 不指定传空
-//
-//	LT：如果新分数小于当前分数，则只更新现有元素。 此标志不会阻止添加新元素。
-//	GT：如果新分数大于当前分数，则仅更新现有元素。 此标志不会阻止添加新元素。
-//
-// isCh: bool, 指定该参数时，会将返回值修改，由原先的返回新添加的元素个数，变为返回变更的元素+新添加的元素总数
-// isIncr: bool, 指定此选项时，ZADD 的行为类似于 ZINCRBY。 在此模式下只能指定一个score-element对。
-// scoreElements: [][2]string{score,element} score-element对数组
+// members: []ZMember 成员数组
+// opts: ...ZaddOptions 可选，见 ZaddOptions
 // return:
 //
 //	reply: int 返回成功影响的成员总数
 //
 // link: https://redis.io/commands/zadd/
-func (c *Rzset) Zadd(key string, scoreElements [][2]string, elementCondition, scoreCondition string, isCh bool) (int, error) {
-	if len(scoreElements) == 0 {
+func (c *Rzset) Zadd(key string, members []ZMember, opts ...ZaddOptions) (int, error) {
+	if len(members) == 0 {
 		return 0, nil
 	}
-
-	args := make([]interface{}, 0)
-	args = append(args, key)
-	if normal.InArray(elementCondition, []string{"XX", "NX"}) {
-		args = append(args, elementCondition)
-	}
-	if normal.InArray(scoreCondition, []string{"LT", "GT"}) {
-		args = append(args, scoreCondition)
+	var opt ZaddOptions
+	if len(opts) > 0 {
+		opt = opts[0]
 	}
-	if isCh {
-		args = append(args, "CH")
+	optArgs, err := opt.args()
+	if err != nil {
+		return 0, err
 	}
 
-	for _, pair := range scoreElements {
-		args = append(args, pair[0], pair[1])
+	args := make([]interface{}, 0, len(optArgs)+len(members)*2+1)
+	args = append(args, key)
+	args = append(args, optArgs...)
+	for _, m := range members {
+		args = append(args, m.Score, m.Member)
 	}
 
 	return redis.Int(c.conn.Do("ZADD", args...))
 }
 
-// ZADD, 将指定score-element对添加到key中，如果key不存在，则创建。 此方法默认添加 "INCR"参数
-// 如果指定的成员已经是排序集的成员，则更新score并将元素重新插入正确的位置以确保正确的排序。
-// 注意：GT、LT 和 NX 选项相互排斥。
+// ZADD, 将指定成员添加到key中，如果key不存在，则创建。 此方法默认添加 "INCR"参数，行为类似 ZINCRBY
+// 如果指定的成员已经是排序集的成员，则score按member.Score递增。
+// 注意：GT、LT 和 NX 选项相互排斥，同时指定时返回 ErrZaddOptions。
 // since: 6.2.0
 // key: string 键名
-// score: string score下标值
-// element: string 成员值
-// elementCondition: string , 取值 XX | NX, 不指定传空
-//
-//	XX：只更新已经存在的元素。 不要添加新元素。
-//	NX：只添加新元素。 不要更新已经存在的元素。
-//
-// scoreCondition: string, 取值 LT | GT, 不指定传空
-//
-//	LT：如果新分数小于当前分数，则只更新现有元素。 此标志不会阻止添加新元素。
-//	GT：如果新分数大于当前分数，则仅更新现有元素。 此标志不会阻止添加新元素。
-//
-// isCh: bool, 指定该参数时，会将返回值修改，由原先的返回新添加的元素个数，变为返回变更的元素+新添加的元素总数
+// member: ZMember 成员及增量值
+// opts: ...ZaddOptions 可选，见 ZaddOptions
 // return:
 //
-//	reply: string 返回新的score值
+//	reply: float64 返回新的score值，因选项条件不满足而未执行时返回 redis.ErrNil
 //
 // link: https://redis.io/commands/zadd/
-func (c *Rzset) ZaddIncr(key, score, element string, elementCondition, scoreCondition string, isCh bool) (string, error) {
-	args := make([]interface{}, 0)
-	args = append(args, key)
-	if normal.InArray(elementCondition, []string{"XX", "NX"}) {
-		args = append(args, elementCondition)
+func (c *Rzset) ZaddIncr(key string, member ZMember, opts ...ZaddOptions) (float64, error) {
+	var opt ZaddOptions
+	if len(opts) > 0 {
+		opt = opts[0]
 	}
-	if normal.InArray(scoreCondition, []string{"LT", "GT"}) {
-		args = append(args, scoreCondition)
-	}
-	if isCh {
-		args = append(args, "CH")
+	optArgs, err := opt.args()
+	if err != nil {
+		return 0, err
 	}
-	args = append(args, "INCR")
 
-	return redis.String(c.conn.Do("ZADD", args...))
+	args := make([]interface{}, 0, len(optArgs)+4)
+	args = append(args, key)
+	args = append(args, optArgs...)
+	args = append(args, "INCR", member.Score, member.Member)
+
+	return redis.Float64(c.conn.Do("ZADD", args...))
 }
 
 // ZCARD, 返回有序集合的元素总数, 不存在的key返回0
@@ -152,12 +179,12 @@ func (c *Rzset) Zdiff(key string, diffKeys []string) ([]string, error) {
 // diffKeys: []string 需要比对的键名数组
 // return:
 //
-//	reply [][2]string{{"score", "element"}, ...}
+//	reply []ZMember
 //
 // link: https://redis.io/commands/zdiff/
-func (c *Rzset) ZdiffWithScore(key string, diffKeys []string) (arr [][2]string, err error) {
+func (c *Rzset) ZdiffWithScore(key string, diffKeys []string) ([]ZMember, error) {
 	if key == "" {
-		return [][2]string{}, nil
+		return []ZMember{}, nil
 	}
 	args := make([]interface{}, 0)
 	args = append(args, len(diffKeys)+1, key)
@@ -166,20 +193,7 @@ func (c *Rzset) ZdiffWithScore(key string, diffKeys []string) (arr [][2]string,
 	}
 	args = append(args, "WITHSCORES")
 
-	lists, err := redis.Strings(c.conn.Do("ZDIFF", args...))
-	if err != nil {
-		return [][2]string{}, err
-	}
-	if len(lists) > 0 {
-		// 将数组进行拆分成多个[2]string{score,element}
-		var i int
-		for i = 0; i < len(lists); i++ {
-			arr = append(arr, [2]string{lists[i+1], lists[i]})
-			i++
-		}
-	}
-
-	return arr, err
+	return zmembers(c.conn.Do("ZDIFF", args...))
 }
 
 // ZDIFFSTORE, 比对给定的N个有序集合之间的差异，并将结果存储到指定destination中
@@ -208,15 +222,15 @@ func (c *Rzset) ZdiffStore(key string, diffKeys []string, destination string) (i
 // ZINCRBY，按增量值递增指定成员的score值，
 // 若成员不存在，则把增量值视为score值添加该成员, 若key不存在则创建
 // key: string 键名
-// increment: string 数值型增量字符串, 可以为负数值，代表递减
+// increment: float64 增量, 可以为负数值，代表递减
 // member: string 成员
 // return:
 //
-//	reply: string 返回设置之后该成员的新score值
+//	reply: float64 返回设置之后该成员的新score值
 //
 // link: https://redis.io/commands/zincrby/
-func (c *Rzset) Zincrby(key, increment, member string) (string, error) {
-	return redis.String(c.conn.Do("ZINCRBY", key, increment, member))
+func (c *Rzset) Zincrby(key string, increment float64, member string) (float64, error) {
+	return redis.Float64(c.conn.Do("ZINCRBY", key, increment, member))
 }
 
 // ZINTER, 返回多个给定集合的交集，此方法不指定 WITHSCORES 参数
@@ -268,12 +282,12 @@ func (c *Rzset) Zinter(keys, weights []string, aggregate string) ([]string, erro
 // 使用 AGGREGATE 选项, 可以指定结果集元素的score的聚合方式，默认为SUM
 // return:
 //
-//	reply [][2]string{{"score", "element"}, ...}
+//	reply []ZMember
 //
 // link: https://redis.io/commands/zinter/
-func (c *Rzset) ZinterWithScore(keys, weights []string, aggregate string) (arr [][2]string, err error) {
+func (c *Rzset) ZinterWithScore(keys, weights []string, aggregate string) ([]ZMember, error) {
 	if len(keys) == 0 {
-		return [][2]string{}, nil
+		return []ZMember{}, nil
 	}
 
 	args := make([]interface{}, 0)
@@ -294,21 +308,7 @@ func (c *Rzset) ZinterWithScore(keys, weights []string, aggregate string) (arr [
 	}
 	args = append(args, "AGGREGATE", aggregate, "WITHSCORES")
 
-	lists, err := redis.Strings(c.conn.Do("ZINTER", args...))
-
-	if err != nil {
-		return [][2]string{}, err
-	}
-	if len(lists) > 0 {
-		// 将数组进行拆分成多个[2]string{score,element}
-		var i int
-		for i = 0; i < len(lists); i++ {
-			arr = append(arr, [2]string{lists[i+1], lists[i]})
-			i++
-		}
-	}
-
-	return arr, err
+	return zmembers(c.conn.Do("ZINTER", args...))
 }
 
 // ZINTERCARD, 返回多个给定集合的交集成员数，若其中一个集合为空，则结果必然为0
@@ -384,8 +384,8 @@ func (c *Rzset) ZinterStore(destination string, keys, weights []string, aggregat
 //	reply: int
 //
 // link: https://redis.io/commands/zlexcount/
-func (c *Rzset) Zlexcount(key, min, max string) (string, error) {
-	return redis.String(c.conn.Do("ZLEXCOUNT", min, max))
+func (c *Rzset) Zlexcount(key, min, max string) (int, error) {
+	return redis.Int(c.conn.Do("ZLEXCOUNT", key, min, max))
 }
 
 // ZMPOP, 从提供的键名列表中的第一个非空集合键中移出一个或多个score-member对。
@@ -396,10 +396,10 @@ func (c *Rzset) Zlexcount(key, min, max string) (string, error) {
 // return:
 //
 //	keyName: string 移出成员的集合名
-//	arr: [][2]string{{score,element}, ...}
+//	arr: []ZMember
 //
 // link: https://redis.io/commands/zmpop/
-func (c *Rzset) Zmpop(keys []string, condition string, count int) (keyName string, arr [][2]string, err error) {
+func (c *Rzset) Zmpop(keys []string, condition string, count int) (keyName string, arr []ZMember, err error) {
 	if condition == "" || !normal.InArray(condition, []string{"MIN", "MAX"}) {
 		panic("参数condition传值不正确,值必须为 MIN | MAX")
 	}
@@ -413,48 +413,60 @@ func (c *Rzset) Zmpop(keys []string, condition string, count int) (keyName strin
 	}
 	args = append(args, condition, "COUNT", count)
 	res, err := redis.Values(c.conn.Do("ZMPOP", args...))
-	if len(res) == 0 || err != nil {
-		return "", [][2]string{}, err
+	if len(res) < 2 || err != nil {
+		return "", []ZMember{}, err
 	}
 
 	keyName, _ = redis.String(res[0], nil)
 	lists, _ := redis.Values(res[1], nil)
-	if len(lists) > 0 {
-		// 将数组重新组装成[2]string{score,element} 格式
-		for _, val := range lists {
-			valArr, _ := redis.Strings(val, nil)
-			arr = append(arr, [2]string{valArr[1], valArr[0]})
+	// 每个元素为[member, score]
+	arr = make([]ZMember, 0, len(lists))
+	for _, val := range lists {
+		pair, err := redis.Values(val, nil)
+		if err != nil || len(pair) != 2 {
+			return keyName, arr, err
 		}
+		m, err := zmember(pair[0], pair[1])
+		if err != nil {
+			return keyName, arr, err
+		}
+		arr = append(arr, m)
 	}
-	return keyName, arr, err
+	return keyName, arr, nil
 }
 
-// ZMSCORE, 返回集合中指定成员的score值，不存在的成员score返回空
+// ZMSCORE, 返回集合中指定成员的score值，不存在的成员不包含在结果中
 // since: 6.2.0
 // key: string 键名
 // members: []string  成员数组
 // return:
 //
-//	reply: map[string]string, 格式为map[element]score
+//	reply: map[string]float64, 格式为map[element]score
 //
 // link: https://redis.io/commands/zmscore/
-func (c *Rzset) Zmscore(key string, members []string) (map[string]string, error) {
+func (c *Rzset) Zmscore(key string, members []string) (map[string]float64, error) {
 	args := make([]interface{}, 0)
 	args = append(args, key)
 	for _, v := range members {
 		args = append(args, v)
 	}
-	res, err := redis.Strings(c.conn.Do("ZMSCORE", args...))
+	res, err := redis.Values(c.conn.Do("ZMSCORE", args...))
 	if err != nil {
-		return map[string]string{}, err
+		return map[string]float64{}, err
 	}
-	// 集合初始化
-	maps := make(map[string]string)
-	for k, v := range members {
-		maps[v] = res[k]
+	maps := make(map[string]float64, len(res))
+	for k, v := range res {
+		if v == nil || k >= len(members) {
+			continue
+		}
+		score, err := redis.Float64(v, nil)
+		if err != nil {
+			return maps, err
+		}
+		maps[members[k]] = score
 	}
 
-	return maps, err
+	return maps, nil
 }
 
 // ZPOPMAX, 移除并返回集合中指定count个数的score最大成员
@@ -462,28 +474,15 @@ func (c *Rzset) Zmscore(key string, members []string) (map[string]string, error)
 // count: int 移除数量
 // return:
 //
-//	reply: [][2]string{{score,element}, ...}
+//	reply: []ZMember
 //
 // link: https://redis.io/commands/zpopmax/
-func (c *Rzset) ZpopMax(key string, count int) (arr [][2]string, err error) {
+func (c *Rzset) ZpopMax(key string, count int) ([]ZMember, error) {
 	if count < 1 {
 		count = 1
 	}
 
-	lists, err := redis.Strings(c.conn.Do("ZPOPMAX", key, count))
-	if err != nil {
-		return [][2]string{}, err
-	}
-	if len(lists) > 0 {
-		// 将数组进行拆分成多个[2]string{score,element}
-		var i int
-		for i = 0; i < len(lists); i++ {
-			arr = append(arr, [2]string{lists[i+1], lists[i]})
-			i++
-		}
-	}
-
-	return arr, err
+	return zmembers(c.conn.Do("ZPOPMAX", key, count))
 }
 
 // ZPOPMIN, 移除并返回集合中指定count个数的score最小成员
@@ -491,28 +490,15 @@ func (c *Rzset) ZpopMax(key string, count int) (arr [][2]string, err error) {
 // count: int 移除数量
 // return:
 //
-//	reply: [][2]string{{score,element}, ...}
+//	reply: []ZMember
 //
 // link: https://redis.io/commands/zpopmin/
-func (c *Rzset) ZpopMin(key string, count int) (arr [][2]string, err error) {
+func (c *Rzset) ZpopMin(key string, count int) ([]ZMember, error) {
 	if count < 1 {
 		count = 1
 	}
 
-	lists, err := redis.Strings(c.conn.Do("ZPOPMIN", key, count))
-	if err != nil {
-		return [][2]string{}, err
-	}
-	if len(lists) > 0 {
-		// 将数组进行拆分成多个[2]string{score,element}
-		var i int
-		for i = 0; i < len(lists); i++ {
-			arr = append(arr, [2]string{lists[i+1], lists[i]})
-			i++
-		}
-	}
-
-	return arr, err
+	return zmembers(c.conn.Do("ZPOPMIN", key, count))
 }
 
 // ZRANK, 返回member在集合中的排名，score从低到高排列，
@@ -536,11 +522,13 @@ func (c *Rzset) Zrank(key, member string) (int, error) {
 // member: string 指定成员
 // return:
 //
-//	reply: []string
+//	rank: int 排名
+//	score: float64 成员的score值
+//	err: member不存在或者key不存在时返回 redis.ErrNil
 //
 // link: https://redis.io/commands/zrank/
-func (c *Rzset) ZrankWithScore(key, member string) ([]string, error) {
-	return redis.Strings(c.conn.Do("ZRANK", key, member, "WITHSCORE"))
+func (c *Rzset) ZrankWithScore(key, member string) (int, float64, error) {
+	return rankWithScore(c.conn.Do("ZRANK", key, member, "WITHSCORE"))
 }
 
 // ZREVRANK, 返回member在集合中的排名，score从高到低排列，
@@ -564,11 +552,13 @@ func (c *Rzset) Zrevrank(key, member string) (int, error) {
 // member: string 指定成员
 // return:
 //
-//	reply: []string
+//	rank: int 排名
+//	score: float64 成员的score值
+//	err: member不存在或者key不存在时返回 redis.ErrNil
 //
 // link: https://redis.io/commands/zrevrank/
-func (c *Rzset) ZrevrankWithScore(key, member string) ([]string, error) {
-	return redis.Strings(c.conn.Do("ZREVRANK", key, member, "WITHSCORE"))
+func (c *Rzset) ZrevrankWithScore(key, member string) (int, float64, error) {
+	return rankWithScore(c.conn.Do("ZREVRANK", key, member, "WITHSCORE"))
 }
 
 // ZREM, 移除集合中指定的N个成员，不存在的成员忽略
@@ -591,16 +581,16 @@ func (c *Rzset) Zrem(key string, members []string) (int, error) {
 	return redis.Int(c.conn.Do("ZREM", args...))
 }
 
-// ZSCORE, 返回集合中指定member的score值，member或key不存在，返回空
+// ZSCORE, 返回集合中指定member的score值
 // key: string 键名
 // member: string 指定成员
 // return:
 //
-//	reply: string
+//	reply: float64, member或key不存在时返回 redis.ErrNil
 //
 // link: https://redis.io/commands/zscore/
-func (c *Rzset) Zscore(key, member string) (string, error) {
-	return redis.String(c.conn.Do("ZSCORE", key, member))
+func (c *Rzset) Zscore(key, member string) (float64, error) {
+	return redis.Float64(c.conn.Do("ZSCORE", key, member))
 }
 
 // ZUNION, 返回多个给定集合的并集，此方法不指定 WITHSCORES 参数
@@ -652,12 +642,12 @@ func (c *Rzset) Zunion(keys, weights []string, aggregate string) ([]string, erro
 // 使用 AGGREGATE 选项, 可以指定结果集元素的score的聚合方式，默认为SUM
 // return:
 //
-//	reply [][2]string{{"score", "element"}, ...}
+//	reply []ZMember
 //
 // link: https://redis.io/commands/zunion/
-func (c *Rzset) ZunionWithScore(keys, weights []string, aggregate string) (arr [][2]string, err error) {
+func (c *Rzset) ZunionWithScore(keys, weights []string, aggregate string) ([]ZMember, error) {
 	if len(keys) == 0 {
-		return [][2]string{}, nil
+		return []ZMember{}, nil
 	}
 
 	args := make([]interface{}, 0)
@@ -678,21 +668,7 @@ func (c *Rzset) ZunionWithScore(keys, weights []string, aggregate string) (arr [
 	}
 	args = append(args, "AGGREGATE", aggregate, "WITHSCORES")
 
-	lists, err := redis.Strings(c.conn.Do("ZUNION", args...))
-
-	if err != nil {
-		return [][2]string{}, err
-	}
-	if len(lists) > 0 {
-		// 将数组进行拆分成多个[2]string{score,element}
-		var i int
-		for i = 0; i < len(lists); i++ {
-			arr = append(arr, [2]string{lists[i+1], lists[i]})
-			i++
-		}
-	}
-
-	return arr, err
+	return zmembers(c.conn.Do("ZUNION", args...))
 }
 
 // ZUNIONSTORE, 查询多个给定集合的并集，并将结果存储到指定集合中，若destination已经存在，则其值会被完全覆盖
@@ -764,26 +740,53 @@ func (c *Rzset) ZrandMember(key string, count int) ([]string, error) {
 //
 // return:
 //
-//	reply: [][2]string{{score,element}, ...}
+//	reply: []ZMember
 //
 // link: https://redis.io/commands/zrandmember/
-func (c *Rzset) ZrandMemberWithScore(key string, count int) (arr [][2]string, err error) {
+func (c *Rzset) ZrandMemberWithScore(key string, count int) ([]ZMember, error) {
 	if count == 0 {
 		count = 1
 	}
-	res, err := redis.Strings(c.conn.Do("ZRANDMEMBER", key, count, "WITHSCORES"))
+	return zmembers(c.conn.Do("ZRANDMEMBER", key, count, "WITHSCORES"))
+}
 
+// 将 WITHSCORES 回复 [member, score, member, score, ...] 转换为成员数组
+func zmembers(reply interface{}, err error) ([]ZMember, error) {
+	values, err := redis.Values(reply, err)
 	if err != nil {
-		return [][2]string{}, err
-	}
-	if len(res) > 0 {
-		// 将数组进行拆分成多个[2]string{score,element}
-		var i int
-		for i = 0; i < len(res); i++ {
-			arr = append(arr, [2]string{res[i+1], res[i]})
-			i++
+		return []ZMember{}, err
+	}
+	arr := make([]ZMember, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		m, err := zmember(values[i], values[i+1])
+		if err != nil {
+			return arr, err
 		}
+		arr = append(arr, m)
 	}
+	return arr, nil
+}
+
+// 解析 ZRANK/ZREVRANK WITHSCORE 的回复 [rank, score]
+func rankWithScore(reply interface{}, err error) (int, float64, error) {
+	values, err := redis.Values(reply, err)
+	if err != nil {
+		return 0, 0, err
+	}
+	var rank int
+	var score float64
+	_, err = redis.Scan(values, &rank, &score)
+	return rank, score, err
+}
 
-	return arr, err
+func zmember(member, score interface{}) (ZMember, error) {
+	name, err := redis.String(member, nil)
+	if err != nil {
+		return ZMember{}, err
+	}
+	f, err := redis.Float64(score, nil)
+	if err != nil {
+		return ZMember{}, err
+	}
+	return ZMember{Member: name, Score: f}, nil
 }
@@ -49,10 +49,10 @@ func (c *Rzset) Zrange(key, start, stop, byType string, isRev bool, limit []int)
 // 负数 <count> 返回 <offset> 中的所有元素。 请记住，如果 <offset> 很大，则需要遍历排序集以获取 <offset> 元素，然后才能返回元素
 // return:
 //
-//	reply: [][2]string{{score,element}, ...}
+//	reply: []ZMember
 //
 // link：https://redis.io/commands/zrange/
-func (c *Rzset) ZrangeWithScore(key, start, stop, byType string, isRev bool, limit []int) (arr [][2]string, err error) {
+func (c *Rzset) ZrangeWithScore(key, start, stop, byType string, isRev bool, limit []int) ([]ZMember, error) {
 	args := make([]interface{}, 0)
 	args = append(args, key, start, stop)
 	if normal.InArray(byType, []string{"BYSCORE", "BYLEX"}) {
@@ -69,21 +69,7 @@ func (c *Rzset) ZrangeWithScore(key, start, stop, byType string, isRev bool, lim
 
 	args = append(args, "WITHSCORES")
 
-	res, err := redis.Strings(c.conn.Do("ZRANGE", args...))
-
-	if err != nil {
-		return [][2]string{}, err
-	}
-	if len(res) > 0 {
-		// 将数组进行拆分成多个[2]string{score,element}
-		var i int
-		for i = 0; i < len(res); i++ {
-			arr = append(arr, [2]string{res[i+1], res[i]})
-			i++
-		}
-	}
-
-	return arr, err
+	return zmembers(c.conn.Do("ZRANGE", args...))
 }
 
 // ZRANGESTORE, 返回集合中指定范围区间的成员，此方法默认不指定 WITHSCORES参数
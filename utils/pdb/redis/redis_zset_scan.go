@@ -1,6 +1,9 @@
 package redis
 
 import (
+	"math"
+	"strconv"
+
 	"github.com/gomodule/redigo/redis"
 )
 
@@ -10,7 +13,7 @@ import (
 // pattern: string 正则表达式
 // count: int 单次迭代成员的数量, 存储的数据体量小时，一般返回都是全部结果，该选项并不起作用，这是正常的
 // link：https://redis.io/commands/zscan/
-func (c *Rzset) ScanOnce(key string, cursor int, pattern string, count int) (int, [][2]string, error) {
+func (c *Rzset) ScanOnce(key string, cursor int, pattern string, count int) (int, []ZMember, error) {
 	if count < 1 {
 		count = defaultScanNum
 	}
@@ -18,95 +21,103 @@ func (c *Rzset) ScanOnce(key string, cursor int, pattern string, count int) (int
 	args = append(args, key, cursor, "MATCH", pattern, "COUNT", count)
 
 	res, err := redis.Values(c.conn.Do("ZSCAN", args...))
+	if err != nil {
+		return 0, []ZMember{}, err
+	}
 	cur, _ := redis.Int(res[0], nil)
-	arr, _ := redis.Strings(res[1], nil)
+	members, err := zmembers(res[1], nil)
+	return cur, members, err
+}
 
-	// 将键值对数组进行拆分
-	resArr := make([][2]string, 0)
-	var i int
-	for i = 0; i < len(arr); i++ {
-		resArr = append(resArr, [2]string{arr[i+1], arr[i]})
-		i++
+// 根据条件迭代指定key中所有满足条件的成员，每个元素为 [score, member]
+//
+// Deprecated: 该方法会忽略迭代过程中的错误，请使用 ScanAllE
+func (c *Rzset) ScanAll(key string, pattern string, count int) (arr [][2]string) {
+	members, _ := c.ScanAllE(key, pattern, count)
+	arr = make([][2]string, 0, len(members))
+	for _, m := range members {
+		arr = append(arr, [2]string{scoreString(m.Score), m.Member})
 	}
+	return arr
+}
 
-	return cur, resArr, err
+// 分数转为与Redis回复一致的字符串
+func scoreString(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(score, 'g', -1, 64)
 }
 
-// 根据条件迭代指定key中所有满足条件的成员
+// 根据条件迭代指定key中所有满足条件的成员，出错时停止迭代，数据量较多时请使用 ScanIter 逐个读取
 // key: string 键名
 // pattern: string 正则表达式
 // count: int 单次迭代成员的数量
-// return: arr []string
+// return:
+//
+//	arr: []ZMember 出错前已迭代到的成员
+//	err: error
+//
 // link：https://redis.io/commands/zscan/
-func (c *Rzset) ScanAll(key string, pattern string, count int) (arr [][2]string) {
-	if count < 1 {
-		count = defaultScanNum
-	}
+func (c *Rzset) ScanAllE(key string, pattern string, count int) ([]ZMember, error) {
+	arr := make([]ZMember, 0)
 	cursor := defaultCursor
-	var i int
 	for {
-		args := make([]interface{}, 0)
-		args = append(args, key, cursor, "MATCH", pattern, "COUNT", count)
-
-		res, err := redis.Values(c.conn.Do("ZSCAN", args...))
-		if err == nil {
-			curs, _ := redis.Int(res[0], nil)
-			cursor = curs
-			lists, _ := redis.Strings(res[1], nil)
-			if len(lists) > 0 {
-				// 将键值对数组进行拆分
-				for i = 0; i < len(lists); i++ {
-					arr = append(arr, [2]string{lists[i+1], lists[i]})
-					i++
-				}
-			}
+		cur, lists, err := c.ScanOnce(key, cursor, pattern, count)
+		if err != nil {
+			return arr, err
 		}
+		arr = append(arr, lists...)
+		cursor = cur
 		if cursor == defaultCursor {
-			break
+			return arr, nil
 		}
 	}
-	return arr
 }
 
 // 根据条件迭代指定key中所有满足条件的成员并删除
+//
+// Deprecated: 该方法会忽略迭代及删除过程中的错误，请使用 ScanDelE
+func (c *Rzset) ScanDel(key string, pattern string, count int) (nums int) {
+	nums, _ = c.ScanDelE(key, pattern, count)
+	return nums
+}
+
+// 根据条件迭代指定key中所有满足条件的成员并删除，出错时停止
 // key: string 键名
 // pattern: string 正则表达式
 // count: int 单次迭代成员的数量
-// return: nums int 本次迭代删除的成员数量
+// return:
+//
+//	nums: int 出错前已删除的成员数量
+//	err: error
+//
 // link：https://redis.io/commands/zscan/
-func (c *Rzset) ScanDel(key string, pattern string, count int) (nums int) {
-	if count < 1 {
-		count = defaultScanNum
-	}
+func (c *Rzset) ScanDelE(key string, pattern string, count int) (int, error) {
+	nums := 0
 	cursor := defaultCursor
-	nums = 0
-	var i int
 	for {
-		args := make([]interface{}, 0)
-		args = append(args, key, cursor, "MATCH", pattern, "COUNT", count)
-
-		res, err := redis.Values(c.conn.Do("ZSCAN", args...))
-		if err == nil {
-			curs, _ := redis.Int(res[0], nil)
-			cursor = curs
-			lists, _ := redis.Strings(res[1], nil)
-
-			//执行删除
-			if len(lists) > 0 {
-				members := []string{}
-				for i = 0; i < len(lists); i++ {
-					if i%2 == 0 {
-						members = append(members, lists[i])
-					}
-				}
-				num, _ := c.Zrem(key, members)
-				nums += num
+		cur, lists, err := c.ScanOnce(key, cursor, pattern, count)
+		if err != nil {
+			return nums, err
+		}
+		if len(lists) > 0 {
+			members := make([]string, 0, len(lists))
+			for _, v := range lists {
+				members = append(members, v.Member)
 			}
+			num, err := c.Zrem(key, members)
+			if err != nil {
+				return nums, err
+			}
+			nums += num
 		}
-
+		cursor = cur
 		if cursor == defaultCursor {
-			break
+			return nums, nil
 		}
 	}
-	return nums
 }
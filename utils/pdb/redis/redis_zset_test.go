@@ -0,0 +1,97 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/perpower/goframe/utils/pdb/redis/redistest"
+)
+
+func TestZsetTypedScores(t *testing.T) {
+	s := redistest.NewServer(t)
+	client, err := New(Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	z := client.Zset
+
+	if _, err := z.Zadd("rank", []ZMember{{Member: "a", Score: 1.5}, {Member: "b", Score: 3}, {Member: "c", Score: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if score, err := z.Zincrby("rank", 0.25, "a"); err != nil || score != 1.75 {
+		t.Fatalf("Zincrby = %v, %v", score, err)
+	}
+	if score, err := z.Zscore("rank", "b"); err != nil || score != 3 {
+		t.Fatalf("Zscore = %v, %v", score, err)
+	}
+	if _, err := z.Zscore("rank", "missing"); err != redis.ErrNil {
+		t.Fatalf("Zscore of a missing member err = %v, want redis.ErrNil", err)
+	}
+
+	scores, err := z.Zmscore("rank", []string{"a", "missing", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 2 || scores["a"] != 1.75 || scores["c"] != 2 {
+		t.Fatalf("Zmscore = %v", scores)
+	}
+
+	if rank, score, err := z.ZrankWithScore("rank", "c"); err != nil || rank != 1 || score != 2 {
+		t.Fatalf("ZrankWithScore = %d, %v, %v", rank, score, err)
+	}
+	if rank, score, err := z.ZrevrankWithScore("rank", "b"); err != nil || rank != 0 || score != 3 {
+		t.Fatalf("ZrevrankWithScore = %d, %v, %v", rank, score, err)
+	}
+	if _, _, err := z.ZrankWithScore("rank", "missing"); err != redis.ErrNil {
+		t.Fatalf("ZrankWithScore of a missing member err = %v, want redis.ErrNil", err)
+	}
+
+	members, err := z.ScanAllE("rank", "*", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ZMember{{Member: "a", Score: 1.75}, {Member: "c", Score: 2}, {Member: "b", Score: 3}}
+	if len(members) != len(want) {
+		t.Fatalf("ScanAllE = %v", members)
+	}
+	for i := range want {
+		if members[i] != want[i] {
+			t.Fatalf("ScanAllE = %v, want %v", members, want)
+		}
+	}
+}
+
+func TestZsetLexcountAndScanAll(t *testing.T) {
+	s := redistest.NewServer(t)
+	client, err := New(Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	z := client.Zset
+
+	if _, err := z.Zadd("lex", []ZMember{{Member: "a"}, {Member: "b"}, {Member: "c"}, {Member: "d"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := z.Zlexcount("lex", "-", "+"); err != nil || n != 4 {
+		t.Fatalf("Zlexcount - + = %d, %v, want 4", n, err)
+	}
+	if n, err := z.Zlexcount("lex", "[b", "(d"); err != nil || n != 2 {
+		t.Fatalf("Zlexcount [b (d = %d, %v, want 2", n, err)
+	}
+	if n, err := z.Zlexcount("missing", "-", "+"); err != nil || n != 0 {
+		t.Fatalf("Zlexcount of a missing key = %d, %v", n, err)
+	}
+
+	// 兼容旧版本的ScanAll仍返回 [score, member]
+	if _, err := z.Zadd("rank", []ZMember{{Member: "x", Score: 1.5}, {Member: "y", Score: 20}}); err != nil {
+		t.Fatal(err)
+	}
+	got := z.ScanAll("rank", "*", 0)
+	want := [][2]string{{"1.5", "x"}, {"20", "y"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("ScanAll = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,375 @@
+// 哈希、列表及集合命令
+package redistest
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var hashCommands = map[string]command{
+	"HSET": {3, func(s *Server, args []string) interface{} {
+		if len(args)%2 != 1 {
+			return wrongArgs("HSET")
+		}
+		h, err := s.hash(args[0], true)
+		if err != nil {
+			return err
+		}
+		added := int64(0)
+		for i := 1; i+1 < len(args); i += 2 {
+			if _, ok := h[args[i]]; !ok {
+				added++
+			}
+			h[args[i]] = args[i+1]
+		}
+		s.touch(args[0])
+		return added
+	}},
+	"HSETNX": {3, func(s *Server, args []string) interface{} {
+		h, err := s.hash(args[0], true)
+		if err != nil {
+			return err
+		}
+		if _, ok := h[args[1]]; ok {
+			return int64(0)
+		}
+		h[args[1]] = args[2]
+		s.touch(args[0])
+		return int64(1)
+	}},
+	"HGET": {2, func(s *Server, args []string) interface{} {
+		h, err := s.hash(args[0], false)
+		if err != nil {
+			return err
+		}
+		if v, ok := h[args[1]]; ok {
+			return v
+		}
+		return nil
+	}},
+	"HMGET": {2, func(s *Server, args []string) interface{} {
+		h, err := s.hash(args[0], false)
+		if err != nil {
+			return err
+		}
+		res := make([]interface{}, 0, len(args)-1)
+		for _, f := range args[1:] {
+			if v, ok := h[f]; ok {
+				res = append(res, v)
+			} else {
+				res = append(res, nil)
+			}
+		}
+		return res
+	}},
+	"HGETALL": {1, func(s *Server, args []string) interface{} {
+		h, err := s.hash(args[0], false)
+		if err != nil {
+			return err
+		}
+		res := make([]interface{}, 0, len(h)*2)
+		for _, f := range sortedKeys(h) {
+			res = append(res, f, h[f])
+		}
+		return res
+	}},
+	"HKEYS": {1, func(s *Server, args []string) interface{} {
+		h, err := s.hash(args[0], false)
+		if err != nil {
+			return err
+		}
+		return strs(sortedKeys(h))
+	}},
+	"HLEN": {1, func(s *Server, args []string) interface{} {
+		h, err := s.hash(args[0], false)
+		if err != nil {
+			return err
+		}
+		return int64(len(h))
+	}},
+	"HEXISTS": {2, func(s *Server, args []string) interface{} {
+		h, err := s.hash(args[0], false)
+		if err != nil {
+			return err
+		}
+		if _, ok := h[args[1]]; ok {
+			return int64(1)
+		}
+		return int64(0)
+	}},
+	"HDEL": {2, func(s *Server, args []string) interface{} {
+		h, err := s.hash(args[0], false)
+		if err != nil {
+			return err
+		}
+		n := int64(0)
+		for _, f := range args[1:] {
+			if _, ok := h[f]; ok {
+				delete(h, f)
+				n++
+			}
+		}
+		if n > 0 {
+			s.touch(args[0])
+			s.cleanup(args[0])
+		}
+		return n
+	}},
+	"HINCRBY": {3, func(s *Server, args []string) interface{} {
+		delta, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return errNotInt
+		}
+		h, e := s.hash(args[0], true)
+		if e != nil {
+			return e
+		}
+		n := int64(0)
+		if v, ok := h[args[1]]; ok {
+			if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return Error("ERR hash value is not an integer")
+			}
+		}
+		n += delta
+		h[args[1]] = strconv.FormatInt(n, 10)
+		s.touch(args[0])
+		return n
+	}},
+}
+
+var listCommands = map[string]command{
+	"LPUSH": {2, pushCommand(true)},
+	"RPUSH": {2, pushCommand(false)},
+	"LPOP":  {1, popCommand(true)},
+	"RPOP":  {1, popCommand(false)},
+	"LLEN": {1, func(s *Server, args []string) interface{} {
+		if s.wrongType(args[0], "list") {
+			return errWrongType
+		}
+		return int64(len(s.lists[args[0]]))
+	}},
+	"LRANGE": {3, func(s *Server, args []string) interface{} {
+		if s.wrongType(args[0], "list") {
+			return errWrongType
+		}
+		start, err1 := strconv.ParseInt(args[1], 10, 64)
+		stop, err2 := strconv.ParseInt(args[2], 10, 64)
+		if err1 != nil || err2 != nil {
+			return errNotInt
+		}
+		list := s.lists[args[0]]
+		start, stop = clampRange(start, stop, int64(len(list)))
+		res := []interface{}{}
+		for i := start; i <= stop; i++ {
+			res = append(res, list[i])
+		}
+		return res
+	}},
+	"LREM": {3, func(s *Server, args []string) interface{} {
+		if s.wrongType(args[0], "list") {
+			return errWrongType
+		}
+		count, err := strconv.Atoi(args[1])
+		if err != nil {
+			return errNotInt
+		}
+		// count为负数时从尾部开始删除
+		list := s.lists[args[0]]
+		remove := map[int]bool{}
+		for i := range list {
+			j := i
+			if count < 0 {
+				j = len(list) - 1 - i
+			}
+			if list[j] == args[2] && (count == 0 || len(remove) < abs(count)) {
+				remove[j] = true
+			}
+		}
+		kept := make([]string, 0, len(list))
+		for i, v := range list {
+			if !remove[i] {
+				kept = append(kept, v)
+			}
+		}
+		removed := len(remove)
+		if removed > 0 {
+			s.lists[args[0]] = kept
+			s.touch(args[0])
+			s.cleanup(args[0])
+		}
+		return int64(removed)
+	}},
+}
+
+var setCommands = map[string]command{
+	"SADD": {2, func(s *Server, args []string) interface{} {
+		if s.wrongType(args[0], "set") {
+			return errWrongType
+		}
+		set := s.sets[args[0]]
+		if set == nil {
+			set = map[string]struct{}{}
+			s.sets[args[0]] = set
+		}
+		n := int64(0)
+		for _, m := range args[1:] {
+			if _, ok := set[m]; !ok {
+				set[m] = struct{}{}
+				n++
+			}
+		}
+		s.touch(args[0])
+		return n
+	}},
+	"SREM": {2, func(s *Server, args []string) interface{} {
+		if s.wrongType(args[0], "set") {
+			return errWrongType
+		}
+		set := s.sets[args[0]]
+		n := int64(0)
+		for _, m := range args[1:] {
+			if _, ok := set[m]; ok {
+				delete(set, m)
+				n++
+			}
+		}
+		if n > 0 {
+			s.touch(args[0])
+			s.cleanup(args[0])
+		}
+		return n
+	}},
+	"SMEMBERS": {1, func(s *Server, args []string) interface{} {
+		if s.wrongType(args[0], "set") {
+			return errWrongType
+		}
+		return strs(sortedKeys(s.sets[args[0]]))
+	}},
+	"SCARD": {1, func(s *Server, args []string) interface{} {
+		if s.wrongType(args[0], "set") {
+			return errWrongType
+		}
+		return int64(len(s.sets[args[0]]))
+	}},
+	"SISMEMBER": {2, func(s *Server, args []string) interface{} {
+		if s.wrongType(args[0], "set") {
+			return errWrongType
+		}
+		if _, ok := s.sets[args[0]][args[1]]; ok {
+			return int64(1)
+		}
+		return int64(0)
+	}},
+}
+
+// 返回哈希表，create为true时不存在则创建
+func (s *Server) hash(key string, create bool) (map[string]string, interface{}) {
+	if s.wrongType(key, "hash") {
+		return nil, errWrongType
+	}
+	h := s.hashes[key]
+	if h == nil && create {
+		h = map[string]string{}
+		s.hashes[key] = h
+	}
+	return h, nil
+}
+
+// LPUSH、RPUSH key element [element ...]
+func pushCommand(left bool) func(s *Server, args []string) interface{} {
+	return func(s *Server, args []string) interface{} {
+		key := args[0]
+		if s.wrongType(key, "list") {
+			return errWrongType
+		}
+		for _, v := range args[1:] {
+			if left {
+				s.lists[key] = append([]string{v}, s.lists[key]...)
+			} else {
+				s.lists[key] = append(s.lists[key], v)
+			}
+		}
+		s.touch(key)
+		return int64(len(s.lists[key]))
+	}
+}
+
+// LPOP、RPOP key [count]
+func popCommand(left bool) func(s *Server, args []string) interface{} {
+	return func(s *Server, args []string) interface{} {
+		key := args[0]
+		if s.wrongType(key, "list") {
+			return errWrongType
+		}
+		count := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 0 {
+				return Error("ERR value is out of range, must be positive")
+			}
+			count = n
+		}
+		list := s.lists[key]
+		if len(list) == 0 {
+			if len(args) > 1 {
+				return []interface{}(nil)
+			}
+			return nil
+		}
+		if count > len(list) {
+			count = len(list)
+		}
+		res := []interface{}{}
+		for i := 0; i < count; i++ {
+			if left {
+				res = append(res, list[0])
+				list = list[1:]
+			} else {
+				res = append(res, list[len(list)-1])
+				list = list[:len(list)-1]
+			}
+		}
+		s.lists[key] = list
+		s.touch(key)
+		s.cleanup(key)
+		if len(args) == 1 {
+			return res[0]
+		}
+		return res
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func strs(arr []string) []interface{} {
+	res := make([]interface{}, 0, len(arr))
+	for _, v := range arr {
+		res = append(res, v)
+	}
+	return res
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// 布尔选项是否出现在参数中
+func hasFlag(args []string, flag string) bool {
+	for _, a := range args {
+		if strings.EqualFold(a, flag) {
+			return true
+		}
+	}
+	return false
+}
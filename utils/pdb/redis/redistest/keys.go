@@ -0,0 +1,403 @@
+// 命令分发及键空间命令
+package redistest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// command 命令实现，args不含命令名
+type command struct {
+	arity int // 最少参数个数，不含命令名
+	fn    func(s *Server, args []string) interface{}
+}
+
+// 已实现的命令，在init中注册以避免与脚本命令之间的初始化循环
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{}
+	for _, table := range []map[string]command{keyCommands, stringCommands, hashCommands, listCommands, setCommands, zsetCommands, scriptCommands} {
+		for name, cmd := range table {
+			commands[name] = cmd
+		}
+	}
+}
+
+var keyCommands = map[string]command{
+	"PING": {0, func(s *Server, args []string) interface{} {
+		if len(args) > 0 {
+			return args[0]
+		}
+		return Status("PONG")
+	}},
+	"ECHO":   {1, func(s *Server, args []string) interface{} { return args[0] }},
+	"SELECT": {1, func(s *Server, args []string) interface{} { return Status("OK") }},
+	"ROLE": {0, func(s *Server, args []string) interface{} {
+		return []interface{}{"master", int64(0), []interface{}{}}
+	}},
+	"DBSIZE":   {0, func(s *Server, args []string) interface{} { return int64(len(s.keys())) }},
+	"FLUSHDB":  {0, cmdFlush},
+	"FLUSHALL": {0, cmdFlush},
+	"DEL":      {1, cmdDel},
+	"UNLINK":   {1, cmdDel},
+	"EXISTS": {1, func(s *Server, args []string) interface{} {
+		n := int64(0)
+		for _, key := range args {
+			if s.exists(key) {
+				n++
+			}
+		}
+		return n
+	}},
+	"TYPE": {1, func(s *Server, args []string) interface{} { return Status(s.kind(args[0])) }},
+	"KEYS": {1, func(s *Server, args []string) interface{} {
+		res := []interface{}{}
+		for _, key := range s.keys() {
+			if match(args[0], key) {
+				res = append(res, key)
+			}
+		}
+		return res
+	}},
+	"SCAN":      {1, cmdScan},
+	"EXPIRE":    {2, expireCommand(time.Second, false)},
+	"PEXPIRE":   {2, expireCommand(time.Millisecond, false)},
+	"EXPIREAT":  {2, expireCommand(time.Second, true)},
+	"PEXPIREAT": {2, expireCommand(time.Millisecond, true)},
+	"TTL":       {1, ttlCommand(time.Second)},
+	"PTTL":      {1, ttlCommand(time.Millisecond)},
+	"PERSIST": {1, func(s *Server, args []string) interface{} {
+		if _, ok := s.expires[args[0]]; !ok || !s.exists(args[0]) {
+			return int64(0)
+		}
+		delete(s.expires, args[0])
+		s.touch(args[0])
+		return int64(1)
+	}},
+}
+
+// 执行单条命令，调用方持有锁
+func (s *Server) run(args []string) interface{} {
+	s.expire()
+	cmd, ok := commands[strings.ToUpper(args[0])]
+	if !ok {
+		return unknown(args[0])
+	}
+	if len(args)-1 < cmd.arity {
+		return wrongArgs(args[0])
+	}
+	return cmd.fn(s, args[1:])
+}
+
+func unknown(name string) Error {
+	return Error("ERR unknown command '" + name + "'")
+}
+
+func wrongArgs(name string) Error {
+	return Error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+}
+
+// 删除已过期的键
+func (s *Server) expire() {
+	now := time.Now()
+	for key, at := range s.expires {
+		if !now.Before(at) {
+			s.del(key)
+		}
+	}
+}
+
+// 所有键，按字典序排列
+func (s *Server) keys() []string {
+	keys := make([]string, 0)
+	for k := range s.strings {
+		keys = append(keys, k)
+	}
+	for k := range s.hashes {
+		keys = append(keys, k)
+	}
+	for k := range s.lists {
+		keys = append(keys, k)
+	}
+	for k := range s.sets {
+		keys = append(keys, k)
+	}
+	for k := range s.zsets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Server) exists(key string) bool {
+	return s.kind(key) != "none"
+}
+
+// 键的类型，不存在时返回none
+func (s *Server) kind(key string) string {
+	if _, ok := s.strings[key]; ok {
+		return "string"
+	}
+	if _, ok := s.hashes[key]; ok {
+		return "hash"
+	}
+	if _, ok := s.lists[key]; ok {
+		return "list"
+	}
+	if _, ok := s.sets[key]; ok {
+		return "set"
+	}
+	if _, ok := s.zsets[key]; ok {
+		return "zset"
+	}
+	return "none"
+}
+
+// 键存在且不是指定类型
+func (s *Server) wrongType(key, kind string) bool {
+	k := s.kind(key)
+	return k != "none" && k != kind
+}
+
+// 记录键被修改，WATCH该键的事务将失败
+func (s *Server) touch(key string) {
+	s.versions[key]++
+}
+
+func (s *Server) del(key string) bool {
+	ok := s.exists(key)
+	delete(s.strings, key)
+	delete(s.hashes, key)
+	delete(s.lists, key)
+	delete(s.sets, key)
+	delete(s.zsets, key)
+	delete(s.expires, key)
+	if ok {
+		s.touch(key)
+	}
+	return ok
+}
+
+// 集合类型的值为空时删除该键
+func (s *Server) cleanup(key string) {
+	empty := false
+	switch s.kind(key) {
+	case "hash":
+		empty = len(s.hashes[key]) == 0
+	case "list":
+		empty = len(s.lists[key]) == 0
+	case "set":
+		empty = len(s.sets[key]) == 0
+	case "zset":
+		empty = len(s.zsets[key]) == 0
+	}
+	if empty {
+		s.del(key)
+	}
+}
+
+func cmdFlush(s *Server, args []string) interface{} {
+	for _, key := range s.keys() {
+		s.del(key)
+	}
+	return Status("OK")
+}
+
+func cmdDel(s *Server, args []string) interface{} {
+	n := int64(0)
+	for _, key := range args {
+		if s.del(key) {
+			n++
+		}
+	}
+	return n
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]，按键的字典序迭代
+func cmdScan(s *Server, args []string) interface{} {
+	cursor, err := strconv.Atoi(args[0])
+	if err != nil || cursor < 0 {
+		return Error("ERR invalid cursor")
+	}
+	pattern, count, typ := "*", 10, ""
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errSyntax
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count < 1 {
+				return errSyntax
+			}
+		case "TYPE":
+			typ = strings.ToLower(args[i+1])
+		default:
+			return errSyntax
+		}
+	}
+	// 游标对应上次返回的最后一个键，从其后继续，迭代期间删除键不会跳过其余的键
+	keys := s.keys()
+	start := 0
+	if cursor != 0 {
+		last, ok := s.cursors[cursor]
+		if !ok {
+			return []interface{}{"0", []interface{}{}}
+		}
+		delete(s.cursors, cursor)
+		start = sort.SearchStrings(keys, last)
+		if start < len(keys) && keys[start] == last {
+			start++
+		}
+	}
+	res := []interface{}{}
+	end := start
+	for ; end < len(keys) && end < start+count; end++ {
+		key := keys[end]
+		if match(pattern, key) && (typ == "" || s.kind(key) == typ) {
+			res = append(res, key)
+		}
+	}
+	if end >= len(keys) {
+		return []interface{}{"0", res}
+	}
+	s.cursor++
+	s.cursors[s.cursor] = keys[end-1]
+	return []interface{}{strconv.Itoa(s.cursor), res}
+}
+
+// EXPIRE、PEXPIRE、EXPIREAT、PEXPIREAT key value [NX|XX|GT|LT]
+func expireCommand(unit time.Duration, at bool) func(s *Server, args []string) interface{} {
+	return func(s *Server, args []string) interface{} {
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return errNotInt
+		}
+		deadline := time.Now().Add(time.Duration(n) * unit)
+		if at {
+			deadline = time.Unix(0, 0).Add(time.Duration(n) * unit)
+		}
+		key := args[0]
+		if !s.exists(key) {
+			return int64(0)
+		}
+		current, volatile := s.expires[key]
+		for _, opt := range args[2:] {
+			switch strings.ToUpper(opt) {
+			case "NX":
+				if volatile {
+					return int64(0)
+				}
+			case "XX":
+				if !volatile {
+					return int64(0)
+				}
+			case "GT":
+				if !volatile || !deadline.After(current) {
+					return int64(0)
+				}
+			case "LT":
+				if volatile && !deadline.Before(current) {
+					return int64(0)
+				}
+			default:
+				return Error("ERR Unsupported option " + opt)
+			}
+		}
+		if !deadline.After(time.Now()) {
+			s.del(key)
+			return int64(1)
+		}
+		s.expires[key] = deadline
+		s.touch(key)
+		return int64(1)
+	}
+}
+
+// TTL、PTTL key
+func ttlCommand(unit time.Duration) func(s *Server, args []string) interface{} {
+	return func(s *Server, args []string) interface{} {
+		if !s.exists(args[0]) {
+			return int64(-2)
+		}
+		at, ok := s.expires[args[0]]
+		if !ok {
+			return int64(-1)
+		}
+		left := time.Until(at)
+		return int64(math.Round(float64(left) / float64(unit)))
+	}
+}
+
+// 按Redis的glob规则匹配，支持 * ? [abc] [^a] [a-z] 及反斜杠转义
+func match(pattern, str string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(str); i++ {
+				if match(pattern[1:], str[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(str) == 0 {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(str) == 0 {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				return false
+			}
+			class := pattern[1 : end+1]
+			not := strings.HasPrefix(class, "^")
+			if not {
+				class = class[1:]
+			}
+			matched := false
+			for i := 0; i < len(class); i++ {
+				if i+2 < len(class) && class[i+1] == '-' {
+					if class[i] <= str[0] && str[0] <= class[i+2] {
+						matched = true
+					}
+					i += 2
+				} else if class[i] == str[0] {
+					matched = true
+				}
+			}
+			if matched == not {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[end+2:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(str) == 0 || str[0] != pattern[0] {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(str) == 0
+}
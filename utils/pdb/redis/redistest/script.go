@@ -0,0 +1,225 @@
+// Lua脚本命令，脚本在gopher-lua中执行，通过redis.call访问当前数据库
+package redistest
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+var scriptCommands = map[string]command{
+	"EVAL":       {2, cmdEval},
+	"EVAL_RO":    {2, cmdEval},
+	"EVALSHA":    {2, cmdEvalsha},
+	"EVALSHA_RO": {2, cmdEvalsha},
+	"SCRIPT": {1, func(s *Server, args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "LOAD":
+			if len(args) != 2 {
+				return wrongArgs("SCRIPT|LOAD")
+			}
+			L := lua.NewState()
+			defer L.Close()
+			if _, err := L.LoadString(args[1]); err != nil {
+				return Error("ERR Error compiling script (new function): " + err.Error())
+			}
+			sha := scriptHash(args[1])
+			s.scripts[sha] = args[1]
+			return sha
+		case "EXISTS":
+			res := []interface{}{}
+			for _, sha := range args[1:] {
+				if _, ok := s.scripts[strings.ToLower(sha)]; ok {
+					res = append(res, int64(1))
+				} else {
+					res = append(res, int64(0))
+				}
+			}
+			return res
+		case "FLUSH":
+			s.scripts = map[string]string{}
+			return Status("OK")
+		}
+		return errSyntax
+	}},
+}
+
+func scriptHash(src string) string {
+	h := sha1.Sum([]byte(src))
+	return hex.EncodeToString(h[:])
+}
+
+// EVAL script numkeys [key [key ...]] [arg [arg ...]]
+func cmdEval(s *Server, args []string) interface{} {
+	s.scripts[scriptHash(args[0])] = args[0]
+	return s.eval(args[0], args[1:])
+}
+
+// EVALSHA sha1 numkeys [key [key ...]] [arg [arg ...]]
+func cmdEvalsha(s *Server, args []string) interface{} {
+	src, ok := s.scripts[strings.ToLower(args[0])]
+	if !ok {
+		return Error("NOSCRIPT No matching script. Please use EVAL.")
+	}
+	return s.eval(src, args[1:])
+}
+
+// 执行脚本，调用方持有锁，脚本中的命令与脚本一起原子执行
+func (s *Server) eval(src string, args []string) interface{} {
+	numkeys, err := strconv.Atoi(args[0])
+	if err != nil || numkeys < 0 {
+		return Error("ERR value is not an integer or out of range")
+	}
+	if numkeys > len(args)-1 {
+		return Error("ERR Number of keys can't be greater than number of args")
+	}
+
+	L := lua.NewState()
+	defer L.Close()
+	L.SetGlobal("KEYS", toLuaArray(L, args[1:1+numkeys]))
+	L.SetGlobal("ARGV", toLuaArray(L, args[1+numkeys:]))
+	r := L.NewTable()
+	L.SetField(r, "call", L.NewFunction(func(L *lua.LState) int { return s.luaCall(L, true) }))
+	L.SetField(r, "pcall", L.NewFunction(func(L *lua.LState) int { return s.luaCall(L, false) }))
+	L.SetField(r, "status_reply", L.NewFunction(func(L *lua.LState) int {
+		t := L.NewTable()
+		t.RawSetString("ok", lua.LString(L.CheckString(1)))
+		L.Push(t)
+		return 1
+	}))
+	L.SetField(r, "error_reply", L.NewFunction(func(L *lua.LState) int {
+		t := L.NewTable()
+		t.RawSetString("err", lua.LString(L.CheckString(1)))
+		L.Push(t)
+		return 1
+	}))
+	L.SetGlobal("redis", r)
+
+	fn, err := L.LoadString(src)
+	if err != nil {
+		return Error("ERR Error compiling script (new function): " + err.Error())
+	}
+	L.Push(fn)
+	if err := L.PCall(0, 1, nil); err != nil {
+		if e, ok := err.(*lua.ApiError); ok {
+			if t, ok := e.Object.(*lua.LTable); ok {
+				if msg, ok := t.RawGetString("err").(lua.LString); ok {
+					return Error(msg)
+				}
+			}
+			return Error("ERR Error running script: " + e.Object.String())
+		}
+		return Error("ERR Error running script: " + err.Error())
+	}
+	return fromLua(L.Get(-1))
+}
+
+// redis.call、redis.pcall，raise为true时命令出错则中止脚本
+func (s *Server) luaCall(L *lua.LState, raise bool) int {
+	n := L.GetTop()
+	if n == 0 {
+		L.RaiseError("Please specify at least one argument for redis.call()")
+	}
+	args := make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		switch v := L.Get(i).(type) {
+		case lua.LString:
+			args = append(args, string(v))
+		case lua.LNumber:
+			args = append(args, formatLuaNumber(float64(v)))
+		default:
+			L.RaiseError("Lua redis() command arguments must be strings or integers")
+		}
+	}
+	reply := s.run(args)
+	if e, ok := reply.(Error); ok {
+		t := L.NewTable()
+		t.RawSetString("err", lua.LString(e))
+		if raise {
+			L.Error(t, 0)
+		}
+		L.Push(t)
+		return 1
+	}
+	L.Push(toLua(L, reply))
+	return 1
+}
+
+// Redis回复转换为Lua值：整数为number，空值为false，状态回复为{ok=...}
+func toLua(L *lua.LState, reply interface{}) lua.LValue {
+	switch v := reply.(type) {
+	case nil:
+		return lua.LFalse
+	case int64:
+		return lua.LNumber(v)
+	case int:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case Status:
+		t := L.NewTable()
+		t.RawSetString("ok", lua.LString(v))
+		return t
+	case []interface{}:
+		if v == nil {
+			return lua.LFalse
+		}
+		t := L.NewTable()
+		for _, e := range v {
+			t.Append(toLua(L, e))
+		}
+		return t
+	}
+	return lua.LFalse
+}
+
+// Lua返回值转换为Redis回复：number取整，true为1，false为空值，数组遇到nil截止
+func fromLua(v lua.LValue) interface{} {
+	switch x := v.(type) {
+	case lua.LString:
+		return string(x)
+	case lua.LNumber:
+		return int64(x)
+	case lua.LBool:
+		if x {
+			return int64(1)
+		}
+		return nil
+	case *lua.LTable:
+		if ok, isStr := x.RawGetString("ok").(lua.LString); isStr {
+			return Status(ok)
+		}
+		if e, isStr := x.RawGetString("err").(lua.LString); isStr {
+			return Error(e)
+		}
+		res := []interface{}{}
+		for i := 1; ; i++ {
+			e := x.RawGetInt(i)
+			if e == lua.LNil {
+				return res
+			}
+			res = append(res, fromLua(e))
+		}
+	}
+	return nil
+}
+
+func toLuaArray(L *lua.LState, arr []string) *lua.LTable {
+	t := L.NewTable()
+	for _, v := range arr {
+		t.Append(lua.LString(v))
+	}
+	return t
+}
+
+// 整数形式的number按整数传给命令，与Redis一致
+func formatLuaNumber(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+		return strconv.FormatInt(int64(v), 10)
+	}
+	return strconv.FormatFloat(v, 'g', 17, 64)
+}
@@ -0,0 +1,440 @@
+// 测试用的本地RESP服务，在内存中实现了常用的键、字符串、位图、哈希、列表、集合、有序集合、事务、发布订阅及Lua脚本命令，
+// 供依赖redis的组件在没有真实Redis的环境中编写测试，例如：
+//
+//	s := redistest.NewServer(t)
+//	client, err := redis.New(redis.Config{Address: s.Addr()})
+//
+// Hook 可拦截任意命令，用于模拟集群重定向、哨兵、网络故障等场景；
+// 命令语义以测试所需为准，未实现的命令返回 unknown command 错误
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Server 本地RESP服务，所有连接共享同一个数据库
+type Server struct {
+	Password string                                           // 设置后连接需先执行AUTH
+	Hook     func(c *Conn, args []string) (interface{}, bool) // 在命令执行之前调用，返回true时以其返回值作为回复，需在NewServer的setup中设置
+
+	ln net.Listener
+
+	mu       sync.Mutex
+	strings  map[string]string
+	hashes   map[string]map[string]string
+	lists    map[string][]string
+	sets     map[string]map[string]struct{}
+	zsets    map[string]map[string]float64
+	expires  map[string]time.Time
+	versions map[string]int // 键的修改次数，用于WATCH
+	scripts  map[string]string
+	conns    map[*Conn]struct{}
+	cursors  map[int]string // SCAN游标 -> 上次返回的最后一个键
+	cursor   int
+
+	accepted int32 // 累计建立的连接数
+	commands int32 // 累计执行的命令数
+}
+
+// Conn 服务端的单个客户端连接
+type Conn struct {
+	s       *Server
+	conn    net.Conn
+	authed  bool
+	asking  bool
+	multi   bool
+	queue   [][]string
+	watched map[string]int
+	dirty   bool // 事务排队期间出现过错误
+
+	wmu  sync.Mutex // 发布的消息由其他连接的goroutine写入
+	w    *bufio.Writer
+	subs map[string]string // 已订阅的频道 -> 推送类型 message | smessage，由Server.mu保护
+}
+
+// Status 简单字符串回复，如 OK
+type Status string
+
+// Error 错误回复
+type Error string
+
+// Pushes 依次写出的多个回复，用于订阅确认
+type Pushes []interface{}
+
+func (e Error) Error() string { return string(e) }
+
+var (
+	errWrongType = Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errSyntax    = Error("ERR syntax error")
+	errNotInt    = Error("ERR value is not an integer or out of range")
+	errNotFloat  = Error("ERR value is not a valid float")
+)
+
+// NewServer 启动本地RESP服务，测试结束时自动关闭
+// setup: 在开始接受连接之前调用，用于设置Password、Hook等
+func NewServer(t testing.TB, setup ...func(s *Server)) *Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		ln:       ln,
+		strings:  map[string]string{},
+		hashes:   map[string]map[string]string{},
+		lists:    map[string][]string{},
+		sets:     map[string]map[string]struct{}{},
+		zsets:    map[string]map[string]float64{},
+		expires:  map[string]time.Time{},
+		versions: map[string]int{},
+		scripts:  map[string]string{},
+		conns:    map[*Conn]struct{}{},
+		cursors:  map[int]string{},
+	}
+	for _, fn := range setup {
+		fn(s)
+	}
+	go s.accept()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Addr 返回服务监听的地址
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close 停止服务并断开所有连接
+func (s *Server) Close() {
+	s.ln.Close()
+	s.DropConns()
+}
+
+// DropConns 断开所有客户端连接，模拟网络故障
+func (s *Server) DropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.conn.Close()
+		delete(s.conns, c)
+	}
+}
+
+// Do 在服务端直接执行一条命令，不经过Hook，用于准备数据或检查结果
+func (s *Server) Do(args ...string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.run(args)
+}
+
+// Keys 返回当前所有未过期的键，按字典序排列
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	return s.keys()
+}
+
+// Accepted 返回累计建立的连接数
+func (s *Server) Accepted() int {
+	return int(atomic.LoadInt32(&s.accepted))
+}
+
+// Commands 返回累计执行的命令数
+func (s *Server) Commands() int {
+	return int(atomic.LoadInt32(&s.commands))
+}
+
+// ResetCommands 将累计执行的命令数清零
+func (s *Server) ResetCommands() {
+	atomic.StoreInt32(&s.commands, 0)
+}
+
+// Kick 服务端主动退订频道，模拟集群槽位迁移后分片频道被退订
+func (s *Server) Kick(channel string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		if kind, ok := c.subs[channel]; ok {
+			delete(c.subs, channel)
+			ack := "unsubscribe"
+			if kind == "smessage" {
+				ack = "sunsubscribe"
+			}
+			c.push([]interface{}{ack, channel, int64(len(c.subs))})
+		}
+	}
+}
+
+// Subscriptions 返回所有连接当前订阅的频道，按字典序排列
+func (s *Server) Subscriptions() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	arr := []string{}
+	for c := range s.conns {
+		for ch := range c.subs {
+			arr = append(arr, ch)
+		}
+	}
+	sort.Strings(arr)
+	return arr
+}
+
+// Asking 当前连接是否执行过ASKING且尚未执行其他命令
+func (c *Conn) Asking() bool {
+	return c.asking
+}
+
+// Close 断开该连接
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+func (s *Server) accept() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddInt32(&s.accepted, 1)
+		c := &Conn{s: s, conn: conn, authed: s.Password == "", w: bufio.NewWriter(conn), subs: map[string]string{}}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		go c.serve()
+	}
+}
+
+func (c *Conn) serve() {
+	defer func() {
+		c.conn.Close()
+		c.s.mu.Lock()
+		delete(c.s.conns, c)
+		c.s.mu.Unlock()
+	}()
+	r := bufio.NewReader(c.conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		atomic.AddInt32(&c.s.commands, 1)
+		reply := c.handle(args)
+		c.wmu.Lock()
+		writeReply(c.w, reply)
+		// 管道中的命令连续到达时合并写出
+		if r.Buffered() == 0 {
+			err = c.w.Flush()
+		}
+		c.wmu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// 向订阅连接推送消息
+func (c *Conn) push(v interface{}) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	writeReply(c.w, v)
+	c.w.Flush()
+}
+
+func (c *Conn) handle(args []string) interface{} {
+	name := strings.ToUpper(args[0])
+	if c.s.Hook != nil {
+		if reply, ok := c.s.Hook(c, args); ok {
+			c.asking = false
+			return reply
+		}
+	}
+	switch name {
+	case "AUTH":
+		if args[len(args)-1] != c.s.Password {
+			return Error("WRONGPASS invalid username-password pair")
+		}
+		c.authed = true
+		return Status("OK")
+	case "ASKING":
+		c.asking = true
+		return Status("OK")
+	}
+	if !c.authed {
+		return Error("NOAUTH Authentication required.")
+	}
+	c.asking = false
+
+	switch name {
+	case "MULTI":
+		c.multi, c.queue, c.dirty = true, nil, false
+		return Status("OK")
+	case "EXEC":
+		return c.exec()
+	case "DISCARD":
+		c.multi, c.queue, c.watched = false, nil, nil
+		return Status("OK")
+	case "WATCH":
+		c.s.mu.Lock()
+		defer c.s.mu.Unlock()
+		c.s.expire()
+		if c.watched == nil {
+			c.watched = map[string]int{}
+		}
+		for _, key := range args[1:] {
+			c.watched[key] = c.s.versions[key]
+		}
+		return Status("OK")
+	case "UNWATCH":
+		c.watched = nil
+		return Status("OK")
+	case "SUBSCRIBE", "SSUBSCRIBE", "UNSUBSCRIBE", "SUNSUBSCRIBE":
+		kind := "message"
+		if name == "SSUBSCRIBE" || name == "SUNSUBSCRIBE" {
+			kind = "smessage"
+		}
+		c.s.mu.Lock()
+		defer c.s.mu.Unlock()
+		acks := Pushes{}
+		for _, ch := range args[1:] {
+			if strings.Contains(name, "UNSUB") {
+				delete(c.subs, ch)
+			} else {
+				c.subs[ch] = kind
+			}
+			acks = append(acks, []interface{}{strings.ToLower(name), ch, int64(len(c.subs))})
+		}
+		return acks
+	case "PUBLISH", "SPUBLISH":
+		if len(args) != 3 {
+			return wrongArgs(args[0])
+		}
+		kind := "message"
+		if name == "SPUBLISH" {
+			kind = "smessage"
+		}
+		c.s.mu.Lock()
+		defer c.s.mu.Unlock()
+		n := int64(0)
+		for sc := range c.s.conns {
+			if sc.subs[args[1]] == kind {
+				sc.push([]interface{}{kind, args[1], args[2]})
+				n++
+			}
+		}
+		return n
+	}
+	if c.multi {
+		if _, ok := commands[name]; !ok {
+			c.dirty = true
+			return unknown(args[0])
+		}
+		c.queue = append(c.queue, args)
+		return Status("QUEUED")
+	}
+
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	return c.s.run(args)
+}
+
+func (c *Conn) exec() interface{} {
+	if !c.multi {
+		return Error("ERR EXEC without MULTI")
+	}
+	queue, watched, dirty := c.queue, c.watched, c.dirty
+	c.multi, c.queue, c.watched, c.dirty = false, nil, nil, false
+	if dirty {
+		return Error("EXECABORT Transaction discarded because of previous errors.")
+	}
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	c.s.expire()
+	for key, version := range watched {
+		if c.s.versions[key] != version {
+			return []interface{}(nil)
+		}
+	}
+	replies := make([]interface{}, 0, len(queue))
+	for _, args := range queue {
+		replies = append(replies, c.s.run(args))
+	}
+	return replies
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[0] != '*' {
+		return nil, errors.New("redistest: 不支持的请求格式")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func writeReply(w *bufio.Writer, v interface{}) {
+	switch x := v.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case Status:
+		fmt.Fprintf(w, "+%s\r\n", x)
+	case Error:
+		fmt.Fprintf(w, "-%s\r\n", x)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", x)
+	case int:
+		fmt.Fprintf(w, ":%d\r\n", x)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(x), x)
+	case Pushes:
+		for _, e := range x {
+			writeReply(w, e)
+		}
+	case []interface{}:
+		if x == nil {
+			w.WriteString("*-1\r\n")
+			return
+		}
+		fmt.Fprintf(w, "*%d\r\n", len(x))
+		for _, e := range x {
+			writeReply(w, e)
+		}
+	default:
+		fmt.Fprintf(w, "-ERR redistest: unsupported reply %T\r\n", v)
+	}
+}
@@ -0,0 +1,487 @@
+// 字符串及位图命令
+package redistest
+
+import (
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var stringCommands = map[string]command{
+	"GET": {1, func(s *Server, args []string) interface{} {
+		if s.wrongType(args[0], "string") {
+			return errWrongType
+		}
+		if v, ok := s.strings[args[0]]; ok {
+			return v
+		}
+		return nil
+	}},
+	"MGET": {1, func(s *Server, args []string) interface{} {
+		res := make([]interface{}, 0, len(args))
+		for _, key := range args {
+			if v, ok := s.strings[key]; ok {
+				res = append(res, v)
+			} else {
+				res = append(res, nil)
+			}
+		}
+		return res
+	}},
+	"SET": {2, cmdSet},
+	"SETNX": {2, func(s *Server, args []string) interface{} {
+		if s.exists(args[0]) {
+			return int64(0)
+		}
+		s.setString(args[0], args[1])
+		return int64(1)
+	}},
+	"STRLEN": {1, func(s *Server, args []string) interface{} {
+		if s.wrongType(args[0], "string") {
+			return errWrongType
+		}
+		return int64(len(s.strings[args[0]]))
+	}},
+	"INCR":   {1, incrCommand(1, false)},
+	"DECR":   {1, incrCommand(-1, false)},
+	"INCRBY": {2, incrCommand(1, true)},
+	"DECRBY": {2, incrCommand(-1, true)},
+	"SETBIT": {3, func(s *Server, args []string) interface{} {
+		if s.wrongType(args[0], "string") {
+			return errWrongType
+		}
+		offset, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return Error("ERR bit offset is not an integer or out of range")
+		}
+		if args[2] != "0" && args[2] != "1" {
+			return Error("ERR bit is not an integer or out of range")
+		}
+		data := grow([]byte(s.strings[args[0]]), int(offset/8)+1)
+		old := getBits(data, int64(offset), 1)
+		data = setBits(data, int64(offset), 1, uint64(args[2][0]-'0'))
+		s.setBytes(args[0], data)
+		return int64(old)
+	}},
+	"GETBIT": {2, func(s *Server, args []string) interface{} {
+		if s.wrongType(args[0], "string") {
+			return errWrongType
+		}
+		offset, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return Error("ERR bit offset is not an integer or out of range")
+		}
+		return int64(getBits([]byte(s.strings[args[0]]), int64(offset), 1))
+	}},
+	"BITCOUNT": {1, cmdBitcount},
+	"BITOP":    {3, cmdBitop},
+	"BITFIELD": {1, cmdBitfield},
+}
+
+// 写入字符串值，覆盖原有的任意类型并清除过期时间
+func (s *Server) setString(key, value string) {
+	s.del(key)
+	s.strings[key] = value
+	s.touch(key)
+}
+
+// 修改字符串值，保留过期时间
+func (s *Server) setBytes(key string, data []byte) {
+	s.strings[key] = string(data)
+	s.touch(key)
+}
+
+// SET key value [NX|XX] [GET] [EX seconds|PX milliseconds|EXAT timestamp|PXAT timestamp|KEEPTTL]
+func cmdSet(s *Server, args []string) interface{} {
+	key, value := args[0], args[1]
+	var (
+		nx, xx, get, keep bool
+		deadline          time.Time
+	)
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			keep = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				return Error("ERR invalid expire time in 'set' command")
+			}
+			i++
+			switch opt {
+			case "EX":
+				deadline = time.Now().Add(time.Duration(n) * time.Second)
+			case "PX":
+				deadline = time.Now().Add(time.Duration(n) * time.Millisecond)
+			case "EXAT":
+				deadline = time.Unix(n, 0)
+			case "PXAT":
+				deadline = time.UnixMilli(n)
+			}
+		default:
+			return errSyntax
+		}
+	}
+	if nx && xx {
+		return errSyntax
+	}
+
+	var old interface{}
+	if get {
+		if s.wrongType(key, "string") {
+			return errWrongType
+		}
+		if v, ok := s.strings[key]; ok {
+			old = v
+		}
+	}
+	if (nx && s.exists(key)) || (xx && !s.exists(key)) {
+		return old
+	}
+	ttl, volatile := s.expires[key]
+	s.setString(key, value)
+	if !deadline.IsZero() {
+		s.expires[key] = deadline
+	} else if keep && volatile {
+		s.expires[key] = ttl
+	}
+	if get {
+		return old
+	}
+	return Status("OK")
+}
+
+// INCR、DECR、INCRBY、DECRBY
+func incrCommand(sign int64, by bool) func(s *Server, args []string) interface{} {
+	return func(s *Server, args []string) interface{} {
+		if s.wrongType(args[0], "string") {
+			return errWrongType
+		}
+		delta := int64(1)
+		if by {
+			var err error
+			if delta, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+				return errNotInt
+			}
+		}
+		n := int64(0)
+		if v, ok := s.strings[args[0]]; ok {
+			var err error
+			if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return errNotInt
+			}
+		}
+		n += sign * delta
+		s.setBytes(args[0], []byte(strconv.FormatInt(n, 10)))
+		return n
+	}
+}
+
+// BITCOUNT key [start end [BYTE|BIT]]
+func cmdBitcount(s *Server, args []string) interface{} {
+	if s.wrongType(args[0], "string") {
+		return errWrongType
+	}
+	data := []byte(s.strings[args[0]])
+	if len(args) == 1 {
+		return int64(popcount(data, 0, int64(len(data))*8-1))
+	}
+	if len(args) != 3 && len(args) != 4 {
+		return errSyntax
+	}
+	start, err1 := strconv.ParseInt(args[1], 10, 64)
+	end, err2 := strconv.ParseInt(args[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		return errNotInt
+	}
+	unit := int64(8)
+	if len(args) == 4 {
+		switch strings.ToUpper(args[3]) {
+		case "BYTE":
+		case "BIT":
+			unit = 1
+		default:
+			return errSyntax
+		}
+	}
+	size := int64(len(data)) * 8 / unit
+	start, end = clampRange(start, end, size)
+	if start > end {
+		return int64(0)
+	}
+	return int64(popcount(data, start*unit, (end+1)*unit-1))
+}
+
+// BITOP AND|OR|XOR|NOT destkey key [key ...]
+func cmdBitop(s *Server, args []string) interface{} {
+	op := strings.ToUpper(args[0])
+	dest, keys := args[1], args[2:]
+	if op == "NOT" && len(keys) != 1 {
+		return Error("ERR BITOP NOT must be called with a single source key.")
+	}
+	if op != "AND" && op != "OR" && op != "XOR" && op != "NOT" {
+		return errSyntax
+	}
+	values := make([][]byte, 0, len(keys))
+	size := 0
+	for _, key := range keys {
+		if s.wrongType(key, "string") {
+			return errWrongType
+		}
+		v := []byte(s.strings[key])
+		values = append(values, v)
+		if len(v) > size {
+			size = len(v)
+		}
+	}
+	res := make([]byte, size)
+	for i := range res {
+		for j, v := range values {
+			var b byte
+			if i < len(v) {
+				b = v[i]
+			}
+			switch {
+			case op == "NOT":
+				res[i] = ^b
+			case j == 0:
+				res[i] = b
+			case op == "AND":
+				res[i] &= b
+			case op == "OR":
+				res[i] |= b
+			case op == "XOR":
+				res[i] ^= b
+			}
+		}
+	}
+	if size == 0 {
+		s.del(dest)
+		return int64(0)
+	}
+	s.setString(dest, string(res))
+	return int64(size)
+}
+
+// BITFIELD key [GET type offset] [SET type offset value] [INCRBY type offset increment] [OVERFLOW WRAP|SAT|FAIL]
+func cmdBitfield(s *Server, args []string) interface{} {
+	key := args[0]
+	if s.wrongType(key, "string") {
+		return errWrongType
+	}
+	data := []byte(s.strings[key])
+	changed := false
+	overflow := "WRAP"
+	res := []interface{}{}
+	for i := 1; i < len(args); {
+		op := strings.ToUpper(args[i])
+		if op == "OVERFLOW" {
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			overflow = strings.ToUpper(args[i+1])
+			if overflow != "WRAP" && overflow != "SAT" && overflow != "FAIL" {
+				return Error("ERR Invalid OVERFLOW type specified")
+			}
+			i += 2
+			continue
+		}
+		need := 3
+		if op == "SET" || op == "INCRBY" {
+			need = 4
+		} else if op != "GET" {
+			return errSyntax
+		}
+		if i+need > len(args) {
+			return errSyntax
+		}
+		signed, width, ok := parseBitType(args[i+1])
+		if !ok {
+			return Error("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+		}
+		offset, ok := parseBitOffset(args[i+2], width)
+		if !ok {
+			return Error("ERR bit offset is not an integer or out of range")
+		}
+		var value int64
+		if need == 4 {
+			var err error
+			if value, err = strconv.ParseInt(args[i+3], 10, 64); err != nil {
+				return errNotInt
+			}
+		}
+		i += need
+
+		old := toInt(getBits(data, offset, width), width, signed)
+		if op == "GET" {
+			res = append(res, old)
+			continue
+		}
+		next := value
+		if op == "INCRBY" {
+			next = old + value
+		}
+		next, ok = fitBits(next, old, value, op == "INCRBY", width, signed, overflow)
+		if !ok {
+			res = append(res, nil)
+			continue
+		}
+		data = setBits(grow(data, int((offset+int64(width)+7)/8)), offset, width, uint64(next))
+		changed = true
+		if op == "SET" {
+			res = append(res, old)
+		} else {
+			res = append(res, next)
+		}
+	}
+	if changed {
+		s.setBytes(key, data)
+	}
+	return res
+}
+
+// 解析 i8、u16 形式的整数类型
+func parseBitType(typ string) (bool, int, bool) {
+	if len(typ) < 2 || (typ[0] != 'i' && typ[0] != 'u') {
+		return false, 0, false
+	}
+	width, err := strconv.Atoi(typ[1:])
+	signed := typ[0] == 'i'
+	if err != nil || width < 1 || (signed && width > 64) || (!signed && width > 63) {
+		return false, 0, false
+	}
+	return signed, width, true
+}
+
+// 解析位偏移，"#N"表示按类型宽度的第N个整数
+func parseBitOffset(offset string, width int) (int64, bool) {
+	mul := int64(1)
+	if strings.HasPrefix(offset, "#") {
+		mul = int64(width)
+		offset = offset[1:]
+	}
+	n, err := strconv.ParseInt(offset, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n * mul, true
+}
+
+// 按溢出处理方式将新值调整到类型范围内，FAIL且溢出时返回false
+func fitBits(next, old, delta int64, incr bool, width int, signed bool, overflow string) (int64, bool) {
+	var min, max int64
+	if signed {
+		min, max = -1<<(width-1), 1<<(width-1)-1
+		if width == 64 {
+			min, max = -1<<63, 1<<63-1
+		}
+	} else {
+		min, max = 0, 1<<width-1
+	}
+	over := next > max || next < min
+	if incr && signed && width == 64 {
+		// int64加法自身溢出
+		over = (delta > 0 && next < old) || (delta < 0 && next > old)
+	}
+	if !over {
+		return next, true
+	}
+	switch overflow {
+	case "FAIL":
+		return 0, false
+	case "SAT":
+		if (incr && delta > 0) || (!incr && next > max) {
+			return max, true
+		}
+		return min, true
+	}
+	return toInt(uint64(next)&(1<<width-1), width, signed), true
+}
+
+// 按位宽截取并按有无符号转换为int64
+func toInt(v uint64, width int, signed bool) int64 {
+	if width < 64 {
+		v &= 1<<width - 1
+	}
+	if signed && width < 64 && v&(1<<(width-1)) != 0 {
+		return int64(v) - 1<<width
+	}
+	return int64(v)
+}
+
+// 读取从offset开始的width位，高位在前
+func getBits(data []byte, offset int64, width int) uint64 {
+	var v uint64
+	for i := int64(0); i < int64(width); i++ {
+		v <<= 1
+		pos := offset + i
+		if pos/8 < int64(len(data)) && data[pos/8]&(0x80>>(pos%8)) != 0 {
+			v |= 1
+		}
+	}
+	return v
+}
+
+// 写入从offset开始的width位，data需已足够长
+func setBits(data []byte, offset int64, width int, v uint64) []byte {
+	for i := int64(0); i < int64(width); i++ {
+		pos := offset + i
+		bit := v >> (int64(width) - 1 - i) & 1
+		if bit == 1 {
+			data[pos/8] |= 0x80 >> (pos % 8)
+		} else {
+			data[pos/8] &^= 0x80 >> (pos % 8)
+		}
+	}
+	return data
+}
+
+func grow(data []byte, size int) []byte {
+	if len(data) >= size {
+		return data
+	}
+	return append(data, make([]byte, size-len(data))...)
+}
+
+// 统计位区间[from, to]中1的个数
+func popcount(data []byte, from, to int64) int {
+	n := 0
+	for pos := from; pos <= to && pos/8 < int64(len(data)); pos++ {
+		if pos%8 == 0 && pos+7 <= to {
+			n += bits.OnesCount8(data[pos/8])
+			pos += 7
+			continue
+		}
+		if data[pos/8]&(0x80>>(pos%8)) != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// 将可为负数的区间转换为[0, size-1]内的位置
+func clampRange(start, end, size int64) (int64, int64) {
+	if start < 0 {
+		start += size
+	}
+	if end < 0 {
+		end += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= size {
+		end = size - 1
+	}
+	return start, end
+}
@@ -0,0 +1,524 @@
+// 有序集合命令
+package redistest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var zsetCommands = map[string]command{
+	"ZADD":    {3, cmdZadd},
+	"ZINCRBY": {3, cmdZincrby},
+	"ZSCORE": {2, func(s *Server, args []string) interface{} {
+		z, err := s.zset(args[0], false)
+		if err != nil {
+			return err
+		}
+		if score, ok := z[args[1]]; ok {
+			return formatScore(score)
+		}
+		return nil
+	}},
+	"ZMSCORE": {2, func(s *Server, args []string) interface{} {
+		z, err := s.zset(args[0], false)
+		if err != nil {
+			return err
+		}
+		res := []interface{}{}
+		for _, m := range args[1:] {
+			if score, ok := z[m]; ok {
+				res = append(res, formatScore(score))
+			} else {
+				res = append(res, nil)
+			}
+		}
+		return res
+	}},
+	"ZCARD": {1, func(s *Server, args []string) interface{} {
+		z, err := s.zset(args[0], false)
+		if err != nil {
+			return err
+		}
+		return int64(len(z))
+	}},
+	"ZCOUNT": {3, func(s *Server, args []string) interface{} {
+		z, err := s.zset(args[0], false)
+		if err != nil {
+			return err
+		}
+		min, max, ok := parseRange(args[1], args[2])
+		if !ok {
+			return Error("ERR min or max is not a float")
+		}
+		n := int64(0)
+		for _, score := range z {
+			if min.below(score) && max.above(score) {
+				n++
+			}
+		}
+		return n
+	}},
+	"ZLEXCOUNT": {3, func(s *Server, args []string) interface{} {
+		z, err := s.zset(args[0], false)
+		if err != nil {
+			return err
+		}
+		n := int64(0)
+		for m := range z {
+			in, ok := inLexRange(m, args[1], args[2])
+			if !ok {
+				return Error("ERR min or max not valid string range item")
+			}
+			if in {
+				n++
+			}
+		}
+		return n
+	}},
+	"ZRANK":    {2, rankCommand(false)},
+	"ZREVRANK": {2, rankCommand(true)},
+	"ZREM": {2, func(s *Server, args []string) interface{} {
+		z, err := s.zset(args[0], false)
+		if err != nil {
+			return err
+		}
+		n := int64(0)
+		for _, m := range args[1:] {
+			if _, ok := z[m]; ok {
+				delete(z, m)
+				n++
+			}
+		}
+		if n > 0 {
+			s.touch(args[0])
+			s.cleanup(args[0])
+		}
+		return n
+	}},
+	"ZRANGE": {3, func(s *Server, args []string) interface{} {
+		return s.zrange(args[0], args[1], args[2], args[3:], false, false)
+	}},
+	"ZREVRANGE": {3, func(s *Server, args []string) interface{} {
+		return s.zrange(args[0], args[1], args[2], args[3:], false, true)
+	}},
+	"ZRANGEBYSCORE": {3, func(s *Server, args []string) interface{} {
+		return s.zrange(args[0], args[1], args[2], args[3:], true, false)
+	}},
+	"ZREVRANGEBYSCORE": {3, func(s *Server, args []string) interface{} {
+		return s.zrange(args[0], args[1], args[2], args[3:], true, true)
+	}},
+	"ZUNIONSTORE": {3, cmdZunionstore},
+	"ZSCAN": {2, func(s *Server, args []string) interface{} {
+		z, err := s.zset(args[0], false)
+		if err != nil {
+			return err
+		}
+		pattern := "*"
+		for i := 2; i+1 < len(args); i += 2 {
+			if strings.EqualFold(args[i], "MATCH") {
+				pattern = args[i+1]
+			}
+		}
+		res := []interface{}{}
+		for _, m := range zmembers(z, false) {
+			if match(pattern, m) {
+				res = append(res, m, formatScore(z[m]))
+			}
+		}
+		return []interface{}{"0", res}
+	}},
+}
+
+// 返回有序集合，create为true时不存在则创建
+func (s *Server) zset(key string, create bool) (map[string]float64, interface{}) {
+	if s.wrongType(key, "zset") {
+		return nil, errWrongType
+	}
+	z := s.zsets[key]
+	if z == nil && create {
+		z = map[string]float64{}
+		s.zsets[key] = z
+	}
+	return z, nil
+}
+
+// ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]
+func cmdZadd(s *Server, args []string) interface{} {
+	key := args[0]
+	var nx, xx, gt, lt, ch, incr bool
+	i := 1
+flags:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break flags
+		}
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 || (nx && xx) || (gt && lt) || (nx && (gt || lt)) {
+		return errSyntax
+	}
+	if incr && len(pairs) != 2 {
+		return Error("ERR INCR option supports a single increment-element pair")
+	}
+	scores := make([]float64, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, err := parseScore(pairs[j])
+		if err != nil {
+			return errNotFloat
+		}
+		scores = append(scores, score)
+	}
+
+	z, err := s.zset(key, false)
+	if err != nil {
+		return err
+	}
+	added, changed := int64(0), int64(0)
+	var last interface{}
+	for j := 0; j < len(pairs); j += 2 {
+		member, score := pairs[j+1], scores[j/2]
+		old, exists := z[member]
+		if incr && exists {
+			score += old
+		}
+		if (nx && exists) || (xx && !exists) || (exists && gt && score <= old) || (exists && lt && score >= old) {
+			last = nil
+			continue
+		}
+		if z == nil {
+			z, _ = s.zset(key, true)
+		}
+		z[member] = score
+		last = formatScore(score)
+		if !exists {
+			added++
+		} else if old != score {
+			changed++
+		}
+	}
+	if added+changed > 0 {
+		s.touch(key)
+	}
+	if incr {
+		return last
+	}
+	if ch {
+		return added + changed
+	}
+	return added
+}
+
+// ZINCRBY key increment member
+func cmdZincrby(s *Server, args []string) interface{} {
+	by, err := parseScore(args[1])
+	if err != nil {
+		return errNotFloat
+	}
+	z, e := s.zset(args[0], true)
+	if e != nil {
+		return e
+	}
+	z[args[2]] += by
+	s.touch(args[0])
+	return formatScore(z[args[2]])
+}
+
+// ZRANK、ZREVRANK key member [WITHSCORE]
+func rankCommand(desc bool) func(s *Server, args []string) interface{} {
+	return func(s *Server, args []string) interface{} {
+		z, err := s.zset(args[0], false)
+		if err != nil {
+			return err
+		}
+		withScore := len(args) > 2 && strings.EqualFold(args[2], "WITHSCORE")
+		if _, ok := z[args[1]]; !ok {
+			if withScore {
+				return []interface{}(nil)
+			}
+			return nil
+		}
+		for i, m := range zmembers(z, desc) {
+			if m == args[1] {
+				if withScore {
+					return []interface{}{int64(i), formatScore(z[m])}
+				}
+				return int64(i)
+			}
+		}
+		return nil
+	}
+}
+
+// 按排名或分数区间查询，byScore且rev时start为最大值、stop为最小值
+// opts: [BYSCORE] [REV] [LIMIT offset count] [WITHSCORES]
+func (s *Server) zrange(key, start, stop string, opts []string, byScore, rev bool) interface{} {
+	z, err := s.zset(key, false)
+	if err != nil {
+		return err
+	}
+	withScores := false
+	offset, count := 0, -1
+	for i := 0; i < len(opts); i++ {
+		switch strings.ToUpper(opts[i]) {
+		case "BYSCORE":
+			byScore = true
+		case "REV":
+			rev = true
+		case "WITHSCORES":
+			withScores = true
+		case "LIMIT":
+			if i+2 >= len(opts) {
+				return errSyntax
+			}
+			var err1, err2 error
+			offset, err1 = strconv.Atoi(opts[i+1])
+			count, err2 = strconv.Atoi(opts[i+2])
+			if err1 != nil || err2 != nil {
+				return errNotInt
+			}
+			i += 2
+		default:
+			return errSyntax
+		}
+	}
+
+	members := zmembers(z, rev)
+	var selected []string
+	if byScore {
+		if rev {
+			start, stop = stop, start
+		}
+		min, max, ok := parseRange(start, stop)
+		if !ok {
+			return Error("ERR min or max is not a float")
+		}
+		for _, m := range members {
+			if min.below(z[m]) && max.above(z[m]) {
+				selected = append(selected, m)
+			}
+		}
+		if offset < 0 {
+			selected = nil
+		} else if offset < len(selected) {
+			selected = selected[offset:]
+		} else {
+			selected = nil
+		}
+		if count >= 0 && count < len(selected) {
+			selected = selected[:count]
+		}
+	} else {
+		from, err1 := strconv.ParseInt(start, 10, 64)
+		to, err2 := strconv.ParseInt(stop, 10, 64)
+		if err1 != nil || err2 != nil {
+			return errNotInt
+		}
+		from, to = clampRange(from, to, int64(len(members)))
+		for i := from; i <= to; i++ {
+			selected = append(selected, members[i])
+		}
+	}
+
+	res := []interface{}{}
+	for _, m := range selected {
+		res = append(res, m)
+		if withScores {
+			res = append(res, formatScore(z[m]))
+		}
+	}
+	return res
+}
+
+// ZUNIONSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]
+func cmdZunionstore(s *Server, args []string) interface{} {
+	dest := args[0]
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 1 || len(args) < 2+n {
+		return errSyntax
+	}
+	keys := args[2 : 2+n]
+	weights := make([]float64, n)
+	for i := range weights {
+		weights[i] = 1
+	}
+	aggregate := "SUM"
+	for i := 2 + n; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "WEIGHTS":
+			if i+n >= len(args) {
+				return errSyntax
+			}
+			for j := 0; j < n; j++ {
+				if weights[j], err = parseScore(args[i+1+j]); err != nil {
+					return Error("ERR weight value is not a float")
+				}
+			}
+			i += n
+		case "AGGREGATE":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			aggregate = strings.ToUpper(args[i+1])
+			i++
+		default:
+			return errSyntax
+		}
+	}
+
+	res := map[string]float64{}
+	for i, key := range keys {
+		var src map[string]float64
+		switch s.kind(key) {
+		case "zset":
+			src = s.zsets[key]
+		case "set":
+			src = map[string]float64{}
+			for m := range s.sets[key] {
+				src[m] = 1
+			}
+		case "none":
+		default:
+			return errWrongType
+		}
+		for m, score := range src {
+			score *= weights[i]
+			old, ok := res[m]
+			switch {
+			case !ok:
+				res[m] = score
+			case aggregate == "MIN":
+				res[m] = math.Min(old, score)
+			case aggregate == "MAX":
+				res[m] = math.Max(old, score)
+			default:
+				res[m] = old + score
+			}
+		}
+	}
+	s.del(dest)
+	if len(res) > 0 {
+		s.zsets[dest] = res
+		s.touch(dest)
+	}
+	return int64(len(res))
+}
+
+// 按分数升序(或降序)排列的成员，同分按成员字典序
+func zmembers(z map[string]float64, desc bool) []string {
+	members := make([]string, 0, len(z))
+	for m := range z {
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if z[members[i]] == z[members[j]] {
+			return (members[i] < members[j]) != desc
+		}
+		return (z[members[i]] < z[members[j]]) != desc
+	})
+	return members
+}
+
+// 分数区间的一端
+type bound struct {
+	value     float64
+	exclusive bool
+}
+
+// 分数是否不小于(或大于)下界
+func (b bound) below(score float64) bool {
+	if b.exclusive {
+		return score > b.value
+	}
+	return score >= b.value
+}
+
+// 分数是否不大于(或小于)上界
+func (b bound) above(score float64) bool {
+	if b.exclusive {
+		return score < b.value
+	}
+	return score <= b.value
+}
+
+// 成员是否位于 -、+、[a、(a 形式的字典序区间内
+func inLexRange(member, min, max string) (in, ok bool) {
+	check := func(b string, lower bool) (bool, bool) {
+		switch {
+		case b == "-":
+			return lower, true
+		case b == "+":
+			return !lower, true
+		case strings.HasPrefix(b, "["):
+			if lower {
+				return member >= b[1:], true
+			}
+			return member <= b[1:], true
+		case strings.HasPrefix(b, "("):
+			if lower {
+				return member > b[1:], true
+			}
+			return member < b[1:], true
+		}
+		return false, false
+	}
+	lo, ok1 := check(min, true)
+	hi, ok2 := check(max, false)
+	return lo && hi, ok1 && ok2
+}
+
+func parseRange(min, max string) (bound, bound, bool) {
+	lo, err1 := parseBound(min)
+	hi, err2 := parseBound(max)
+	return lo, hi, err1 == nil && err2 == nil
+}
+
+// 解析 1.5、(1.5、-inf、+inf 形式的区间端点
+func parseBound(s string) (bound, error) {
+	b := bound{}
+	if strings.HasPrefix(s, "(") {
+		b.exclusive = true
+		s = s[1:]
+	}
+	v, err := parseScore(s)
+	b.value = v
+	return b, err
+}
+
+func parseScore(s string) (float64, error) {
+	switch strings.ToLower(s) {
+	case "+inf", "inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err == nil && math.IsNaN(v) {
+		return 0, errNotFloat
+	}
+	return v, err
+}
+
+func formatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
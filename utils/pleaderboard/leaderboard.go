@@ -0,0 +1,478 @@
+// 排行榜组件，基于Redis有序集合实现
+// 写入分数时同时记入配置的各周期榜单(总榜、日榜、周榜、月榜)，周期榜单的键名包含周期标识，
+// 进入新周期后自动写入新的键，旧榜单在保留期过后自动过期。
+// 同分成员排名相同，下一名次顺延(1,2,2,4)；成员的展示信息(昵称、头像等)存放在同一排行榜的附属哈希表中，查询时一并返回。
+//
+//	lb := pleaderboard.New(redisClient, "points", pleaderboard.Options{
+//		Periods: []pleaderboard.Period{pleaderboard.Total, pleaderboard.Daily, pleaderboard.Weekly},
+//	})
+//	lb.SetMeta(ctx, "u1001", `{"nick":"tom"}`)
+//	lb.Incr(ctx, "u1001", 10)
+//	entries, total, err := lb.Board(pleaderboard.Daily).Top(ctx, 1, 20)
+//	me, err := lb.Board(pleaderboard.Weekly).Rank(ctx, "u1001")
+//	yesterday := lb.Past(pleaderboard.Daily, 1)
+//
+// 同一排行榜的所有键使用相同的hash tag，集群模式下位于同一槽位，可直接合并
+package pleaderboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/perpower/goframe/utils/pdb/redis"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// Period 榜单周期
+type Period string
+
+const (
+	Total   Period = "total"   // 总榜，不过期
+	Daily   Period = "daily"   // 日榜
+	Weekly  Period = "weekly"  // 周榜，周一为一周的第一天
+	Monthly Period = "monthly" // 月榜
+)
+
+type Options struct {
+	Prefix   string         // 键名前缀，默认 "leaderboard:"
+	Periods  []Period       // 写入分数时记入的榜单周期，默认只记入总榜
+	Retain   int            // 周期榜单在周期结束后继续保留的周期数，默认1，即日榜保留到次日结束
+	Asc      bool           // 是否分数越小排名越靠前(如用时榜)，默认分数越大越靠前
+	Location *time.Location // 划分周期使用的时区，默认time.Local
+}
+
+type Leaderboard struct {
+	client *redis.Client
+	name   string
+	opts   Options
+	at     time.Time // 计算当前周期使用的时间，为零值时取当前时间
+}
+
+// Board 一个具体的榜单，可以是某个周期的榜单，也可以是合并后的榜单
+type Board struct {
+	lb  *Leaderboard
+	key string
+	err error // 周期不合法时查询均返回该错误
+}
+
+// Entry 榜单中的一名成员
+type Entry struct {
+	Member string
+	Score  float64
+	Rank   int    // 名次，从1开始，同分名次相同
+	Meta   string // 附属哈希表中的成员信息，未设置时为空
+}
+
+// Part 合并榜单的组成部分
+type Part struct {
+	Period Period
+	Ago    int     // 往前第几个周期，0为当前周期
+	Weight float64 // 分数权重，0视为1
+}
+
+var (
+	defaultPrefix = "leaderboard:"
+	defaultRetain = 1
+
+	ErrInvalidPeriod  = errors.New("pleaderboard: 不支持的榜单周期")
+	ErrMemberNotFound = errors.New("pleaderboard: 成员不在榜单中")
+)
+
+// New 创建排行榜
+// client: *redis.Client redis客户端
+// name: string 排行榜名称
+// opts: Options 可选配置，未设置的项使用默认值
+func New(client *redis.Client, name string, opts ...Options) *Leaderboard {
+	opt := Options{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Prefix == "" {
+		opt.Prefix = defaultPrefix
+	}
+	if len(opt.Periods) == 0 {
+		opt.Periods = []Period{Total}
+	}
+	if opt.Retain <= 0 {
+		opt.Retain = defaultRetain
+	}
+	if opt.Location == nil {
+		opt.Location = time.Local
+	}
+	return &Leaderboard{
+		client: client,
+		name:   name,
+		opts:   opt,
+	}
+}
+
+// At 返回以指定时间划分周期的排行榜副本，用于补录历史数据或查询指定日期的榜单
+func (l *Leaderboard) At(t time.Time) *Leaderboard {
+	cp := *l
+	cp.at = t
+	return &cp
+}
+
+// Incr 成员分数按增量递增，同时记入配置的各周期榜单
+// member: string 成员
+// delta: float64 增量，可以为负数
+// return: float64 第一个配置周期榜单中的新分数
+func (l *Leaderboard) Incr(ctx context.Context, member string, delta float64) (float64, error) {
+	p := l.client.WithContext(ctx).Pipeline()
+	var first *redis.Reply
+	for _, period := range l.opts.Periods {
+		key, expireAt, err := l.current(period)
+		if err != nil {
+			return 0, err
+		}
+		p.Zset.ZaddIncr(key, redis.ZMember{Member: member, Score: delta})
+		if first == nil {
+			first = p.Last()
+		}
+		l.expire(p, key, expireAt)
+	}
+	if _, err := p.Exec(); err != nil {
+		return 0, err
+	}
+	return first.Float64()
+}
+
+// Set 设置成员分数，同时写入配置的各周期榜单
+// onlyBetter: bool 为true时只在新分数优于原分数时更新，适用于记录最好成绩
+func (l *Leaderboard) Set(ctx context.Context, member string, score float64, onlyBetter bool) error {
+	opt := redis.ZaddOptions{}
+	if onlyBetter {
+		opt.Compare = "GT"
+		if l.opts.Asc {
+			opt.Compare = "LT"
+		}
+	}
+	p := l.client.WithContext(ctx).Pipeline()
+	for _, period := range l.opts.Periods {
+		key, expireAt, err := l.current(period)
+		if err != nil {
+			return err
+		}
+		p.Zset.Zadd(key, []redis.ZMember{{Member: member, Score: score}}, opt)
+		l.expire(p, key, expireAt)
+	}
+	_, err := p.Exec()
+	return err
+}
+
+// Remove 从当前各周期榜单中移除成员，并删除其成员信息
+func (l *Leaderboard) Remove(ctx context.Context, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	p := l.client.WithContext(ctx).Pipeline()
+	for _, period := range l.opts.Periods {
+		key, _, err := l.current(period)
+		if err != nil {
+			return err
+		}
+		p.Zset.Zrem(key, members)
+	}
+	p.Hash.Hdel(l.metaKey(), members)
+	_, err := p.Exec()
+	return err
+}
+
+// SetMeta 设置成员信息，存放在附属哈希表中，所有周期榜单共用
+// meta: string 成员信息，建议使用JSON
+func (l *Leaderboard) SetMeta(ctx context.Context, member, meta string) error {
+	_, err := l.client.WithContext(ctx).Hash.Hset(l.metaKey(), [][2]string{{member, meta}})
+	return err
+}
+
+// Board 返回指定周期当前的榜单
+func (l *Leaderboard) Board(period Period) *Board {
+	return l.Past(period, 0)
+}
+
+// Past 返回指定周期往前第ago个周期的榜单，例如 Past(Daily, 1) 为昨日榜单；总榜忽略ago
+func (l *Leaderboard) Past(period Period, ago int) *Board {
+	key, err := l.periodKey(period, ago)
+	return &Board{lb: l, key: key, err: err}
+}
+
+// Merge 按权重合并多个周期的榜单(ZUNIONSTORE)，结果存储为一个新榜单，例如近三日榜：
+//
+//	lb.Merge(ctx, "last3days", 10*time.Minute,
+//		pleaderboard.Part{Period: pleaderboard.Daily},
+//		pleaderboard.Part{Period: pleaderboard.Daily, Ago: 1},
+//		pleaderboard.Part{Period: pleaderboard.Daily, Ago: 2, Weight: 0.5},
+//	)
+//
+// name: string 合并榜单名称，同名的合并结果会被覆盖
+// ttl: time.Duration 合并结果的有效期，0表示不过期
+func (l *Leaderboard) Merge(ctx context.Context, name string, ttl time.Duration, parts ...Part) (*Board, error) {
+	keys := make([]string, 0, len(parts))
+	weights := make([]string, 0, len(parts))
+	for _, part := range parts {
+		key, err := l.periodKey(part.Period, part.Ago)
+		if err != nil {
+			return nil, err
+		}
+		weight := part.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		keys = append(keys, key)
+		weights = append(weights, strconv.FormatFloat(weight, 'f', -1, 64))
+	}
+
+	board := &Board{lb: l, key: l.baseKey() + "merge:" + name}
+	p := l.client.WithContext(ctx).Pipeline()
+	p.Zset.ZunionStore(board.key, keys, weights, "SUM")
+	if ttl > 0 {
+		p.Do("PEXPIRE", board.key, ttl.Milliseconds())
+	}
+	if _, err := p.Exec(); err != nil {
+		return nil, err
+	}
+	return board, nil
+}
+
+// Key 返回榜单的键名
+func (b *Board) Key() string {
+	return b.key
+}
+
+// Count 返回榜单成员总数
+func (b *Board) Count(ctx context.Context) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.lb.client.WithContext(ctx).Zset.Zcard(b.key)
+}
+
+// Rank 查询成员的名次及分数
+// return: 成员不在榜单中时返回 ErrMemberNotFound
+func (b *Board) Rank(ctx context.Context, member string) (Entry, error) {
+	if b.err != nil {
+		return Entry{}, b.err
+	}
+	client := b.lb.client.WithContext(ctx)
+	score, err := client.Zset.Zscore(b.key, member)
+	if err == redigo.ErrNil {
+		return Entry{}, ErrMemberNotFound
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	entries := []Entry{{Member: member, Score: score}}
+	if err := b.rank(ctx, entries, -1); err != nil {
+		return Entry{}, err
+	}
+	if err := b.join(ctx, entries); err != nil {
+		return Entry{}, err
+	}
+	return entries[0], nil
+}
+
+// Top 分页查询榜单
+// page: int 页码，从1开始
+// size: int 每页数量
+// return: entries []Entry 当前页成员, total int 榜单成员总数
+func (b *Board) Top(ctx context.Context, page, size int) ([]Entry, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		return []Entry{}, 0, nil
+	}
+	total, err := b.Count(ctx)
+	if err != nil {
+		return []Entry{}, 0, err
+	}
+	start := (page - 1) * size
+	if start >= total {
+		return []Entry{}, total, nil
+	}
+	entries, err := b.window(ctx, start, start+size-1)
+	return entries, total, err
+}
+
+// Around 查询成员及其前后各n名成员，用于展示"我附近的排名"
+// return: 成员不在榜单中时返回 ErrMemberNotFound
+func (b *Board) Around(ctx context.Context, member string, n int) ([]Entry, error) {
+	if b.err != nil {
+		return []Entry{}, b.err
+	}
+	client := b.lb.client.WithContext(ctx)
+	var (
+		index int
+		err   error
+	)
+	if b.lb.opts.Asc {
+		index, err = client.Zset.Zrank(b.key, member)
+	} else {
+		index, err = client.Zset.Zrevrank(b.key, member)
+	}
+	if err == redigo.ErrNil {
+		return []Entry{}, ErrMemberNotFound
+	}
+	if err != nil {
+		return []Entry{}, err
+	}
+	if n < 0 {
+		n = 0
+	}
+	start := index - n
+	if start < 0 {
+		start = 0
+	}
+	return b.window(ctx, start, index+n)
+}
+
+// 按位置区间查询成员，并计算名次、关联成员信息
+func (b *Board) window(ctx context.Context, start, stop int) ([]Entry, error) {
+	members, err := b.lb.client.WithContext(ctx).Zset.ZrangeWithScore(b.key, strconv.Itoa(start), strconv.Itoa(stop), "", !b.lb.opts.Asc, nil)
+	if err != nil {
+		return []Entry{}, err
+	}
+	entries := make([]Entry, 0, len(members))
+	for _, m := range members {
+		entries = append(entries, Entry{Member: m.Member, Score: m.Score})
+	}
+	if err := b.rank(ctx, entries, start); err != nil {
+		return entries, err
+	}
+	if err := b.join(ctx, entries); err != nil {
+		return entries, err
+	}
+	return entries, nil
+}
+
+// 计算名次：名次 = 分数严格优于该成员的人数 + 1
+// entries按名次排列，start为第一个成员在榜单中的位置，-1表示位置未知
+func (b *Board) rank(ctx context.Context, entries []Entry, start int) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	// 第一个成员可能与前面的成员同分，需要统计严格优于它的人数
+	score := strconv.FormatFloat(entries[0].Score, 'f', -1, 64)
+	min, max := "("+score, "+inf"
+	if b.lb.opts.Asc {
+		min, max = "-inf", "("+score
+	}
+	better, err := b.lb.client.WithContext(ctx).Zset.Zcount(b.key, min, max)
+	if err != nil {
+		return err
+	}
+	entries[0].Rank = better + 1
+	// 之后的成员分数与前一个不同时，排在它前面的成员分数都严格更优
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Score == entries[i-1].Score {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = start + i + 1
+		}
+	}
+	return nil
+}
+
+// 从附属哈希表读取成员信息
+func (b *Board) join(ctx context.Context, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(entries))
+	for _, e := range entries {
+		fields = append(fields, e.Member)
+	}
+	metas, err := b.lb.client.WithContext(ctx).Hash.Hmget(b.lb.metaKey(), fields)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		if i < len(metas) {
+			entries[i].Meta = metas[i]
+		}
+	}
+	return nil
+}
+
+// 排行榜键名前缀，使用hash tag保证同一排行榜的键位于同一槽位
+func (l *Leaderboard) baseKey() string {
+	return l.opts.Prefix + "{" + l.name + "}:"
+}
+
+func (l *Leaderboard) metaKey() string {
+	return l.baseKey() + "meta"
+}
+
+// 写入时使用的当前周期榜单键名及过期时间，总榜过期时间为零值
+func (l *Leaderboard) current(period Period) (string, time.Time, error) {
+	key, err := l.periodKey(period, 0)
+	if err != nil || period == Total {
+		return key, time.Time{}, err
+	}
+	start := l.periodStart(period, l.now())
+	return key, shift(period, start, 1+l.opts.Retain), nil
+}
+
+// 周期榜单键名，例如 leaderboard:{points}:daily:20261018
+func (l *Leaderboard) periodKey(period Period, ago int) (string, error) {
+	base := l.baseKey() + string(period)
+	if period == Total {
+		return base, nil
+	}
+	if period != Daily && period != Weekly && period != Monthly {
+		return "", ErrInvalidPeriod
+	}
+	start := shift(period, l.periodStart(period, l.now()), -ago)
+	switch period {
+	case Daily:
+		return base + ":" + start.Format("20060102"), nil
+	case Weekly:
+		year, week := start.ISOWeek()
+		return base + ":" + fmt.Sprintf("%04dW%02d", year, week), nil
+	default:
+		return base + ":" + start.Format("200601"), nil
+	}
+}
+
+func (l *Leaderboard) now() time.Time {
+	if l.at.IsZero() {
+		return time.Now().In(l.opts.Location)
+	}
+	return l.at.In(l.opts.Location)
+}
+
+// 周期的起始时间
+func (l *Leaderboard) periodStart(period Period, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, l.opts.Location)
+	switch period {
+	case Weekly:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case Monthly:
+		return day.AddDate(0, 0, 1-day.Day())
+	}
+	return day
+}
+
+// 周期起始时间前后移动n个周期
+func shift(period Period, start time.Time, n int) time.Time {
+	switch period {
+	case Weekly:
+		return start.AddDate(0, 0, 7*n)
+	case Monthly:
+		return start.AddDate(0, n, 0)
+	}
+	return start.AddDate(0, 0, n)
+}
+
+// 周期榜单设置过期时间，在写入的同一管道中执行
+func (l *Leaderboard) expire(p *redis.Pipeline, key string, at time.Time) {
+	if at.IsZero() {
+		return
+	}
+	p.Expire.ExpireAt(key, int(at.Unix()), "")
+}
@@ -0,0 +1,270 @@
+package pleaderboard
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/perpower/goframe/utils/pdb/redis"
+	"github.com/perpower/goframe/utils/pdb/redis/redistest"
+)
+
+func newLeaderboard(t *testing.T, opts Options) (*Leaderboard, *redistest.Server) {
+	t.Helper()
+	s := redistest.NewServer(t)
+	client, err := redis.New(redis.Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	return New(client, "points", opts), s
+}
+
+// 按 成员:名次 比较
+func checkEntries(t *testing.T, what string, got []Entry, want ...interface{}) {
+	t.Helper()
+	if len(got) != len(want)/2 {
+		t.Fatalf("%s = %+v, want %v", what, got, want)
+	}
+	for i, e := range got {
+		if e.Member != want[2*i] || e.Rank != want[2*i+1] {
+			t.Fatalf("%s = %+v, want %v", what, got, want)
+		}
+	}
+}
+
+func TestTieRanks(t *testing.T) {
+	lb, _ := newLeaderboard(t, Options{})
+	ctx := context.Background()
+	for member, score := range map[string]float64{"a": 100, "b": 90, "c": 90, "d": 80, "e": 70} {
+		if err := lb.Set(ctx, member, score, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lb.SetMeta(ctx, "b", `{"nick":"bob"}`)
+	board := lb.Board(Total)
+
+	// 同分名次相同，下一名次顺延
+	entries, total, err := board.Top(ctx, 1, 10)
+	if err != nil || total != 5 {
+		t.Fatalf("Top = %v, %d, %v", entries, total, err)
+	}
+	checkEntries(t, "Top", entries, "a", 1, "c", 2, "b", 2, "d", 4, "e", 5)
+	if entries[2].Meta != `{"nick":"bob"}` || entries[1].Meta != "" || entries[0].Score != 100 {
+		t.Fatalf("Top = %+v", entries)
+	}
+
+	// 分页边界上的同分成员沿用前一页的名次
+	entries, _, err = board.Top(ctx, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEntries(t, "Top page 2", entries, "b", 2, "d", 4)
+	if entries, total, err := board.Top(ctx, 4, 2); err != nil || len(entries) != 0 || total != 5 {
+		t.Fatalf("Top past the end = %v, %d, %v", entries, total, err)
+	}
+
+	if e, err := board.Rank(ctx, "b"); err != nil || e.Rank != 2 || e.Score != 90 || e.Meta != `{"nick":"bob"}` {
+		t.Fatalf("Rank = %+v, %v", e, err)
+	}
+	if _, err := board.Rank(ctx, "x"); err != ErrMemberNotFound {
+		t.Fatalf("Rank of a missing member = %v, want ErrMemberNotFound", err)
+	}
+
+	// 只在成绩更好时更新
+	lb.Set(ctx, "e", 60, true)
+	if e, _ := board.Rank(ctx, "e"); e.Score != 70 {
+		t.Fatalf("score after a worse result = %v", e.Score)
+	}
+	lb.Set(ctx, "e", 95, true)
+	if e, _ := board.Rank(ctx, "e"); e.Score != 95 || e.Rank != 2 {
+		t.Fatalf("Rank after a better result = %+v", e)
+	}
+
+	if err := lb.Remove(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := board.Count(ctx); n != 4 {
+		t.Fatalf("Count after Remove = %d", n)
+	}
+}
+
+func TestTieRanksAsc(t *testing.T) {
+	lb, _ := newLeaderboard(t, Options{Asc: true})
+	ctx := context.Background()
+	for member, score := range map[string]float64{"a": 10, "b": 12, "c": 12, "d": 15} {
+		lb.Set(ctx, member, score, false)
+	}
+	entries, _, err := lb.Board(Total).Top(ctx, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEntries(t, "Top", entries, "a", 1, "b", 2, "c", 2, "d", 4)
+
+	// 分数越小越好时，只在新分数更小时更新
+	lb.Set(ctx, "a", 11, true)
+	lb.Set(ctx, "d", 9, true)
+	entries, _, _ = lb.Board(Total).Top(ctx, 1, 2)
+	checkEntries(t, "Top after better results", entries, "d", 1, "a", 2)
+}
+
+func TestAround(t *testing.T) {
+	lb, _ := newLeaderboard(t, Options{})
+	ctx := context.Background()
+	for member, score := range map[string]float64{"a": 100, "b": 90, "c": 90, "d": 80, "e": 70} {
+		lb.Set(ctx, member, score, false)
+	}
+	board := lb.Board(Total)
+
+	entries, err := board.Around(ctx, "d", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEntries(t, "Around d", entries, "b", 2, "d", 4, "e", 5)
+
+	// 位于榜首或榜尾时只返回存在的一侧
+	entries, _ = board.Around(ctx, "a", 2)
+	checkEntries(t, "Around a", entries, "a", 1, "c", 2, "b", 2)
+	entries, _ = board.Around(ctx, "e", 1)
+	checkEntries(t, "Around e", entries, "d", 4, "e", 5)
+	entries, _ = board.Around(ctx, "c", -1)
+	checkEntries(t, "Around c with a negative n", entries, "c", 2)
+
+	if _, err := board.Around(ctx, "x", 1); err != ErrMemberNotFound {
+		t.Fatalf("Around a missing member = %v, want ErrMemberNotFound", err)
+	}
+}
+
+func TestPeriodKeys(t *testing.T) {
+	lb, _ := newLeaderboard(t, Options{})
+	base := "leaderboard:{points}:"
+	cases := []struct {
+		at     time.Time
+		period Period
+		ago    int
+		want   string
+	}{
+		{time.Date(2026, 10, 18, 23, 59, 0, 0, time.UTC), Total, 3, base + "total"},
+		{time.Date(2026, 10, 18, 23, 59, 0, 0, time.UTC), Daily, 0, base + "daily:20261018"},
+		{time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), Daily, 0, base + "daily:20261019"},
+		{time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Daily, 1, base + "daily:20260228"},
+		// 周日仍属于本周，周一进入新的一周
+		{time.Date(2026, 10, 18, 23, 59, 0, 0, time.UTC), Weekly, 0, base + "weekly:2026W42"},
+		{time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), Weekly, 0, base + "weekly:2026W43"},
+		{time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), Weekly, 1, base + "weekly:2026W42"},
+		// 跨年的周按ISO周所属年份命名
+		{time.Date(2027, 1, 1, 12, 0, 0, 0, time.UTC), Weekly, 0, base + "weekly:2026W53"},
+		{time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC), Monthly, 1, base + "monthly:202602"},
+		{time.Date(2027, 1, 15, 12, 0, 0, 0, time.UTC), Monthly, 1, base + "monthly:202612"},
+	}
+	for _, c := range cases {
+		if got := lb.At(c.at).Past(c.period, c.ago).Key(); got != c.want {
+			t.Errorf("At(%s).Past(%s, %d) = %s, want %s", c.at, c.period, c.ago, got, c.want)
+		}
+	}
+
+	// 按配置的时区划分周期
+	shanghai := time.FixedZone("CST", 8*3600)
+	local := New(lb.client, "points", Options{Location: shanghai})
+	if got := local.At(time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC)).Board(Daily).Key(); got != base+"daily:20261019" {
+		t.Fatalf("daily key in UTC+8 = %s", got)
+	}
+
+	if _, err := lb.Board("hourly").Count(context.Background()); err != ErrInvalidPeriod {
+		t.Fatalf("Count of an invalid period = %v, want ErrInvalidPeriod", err)
+	}
+}
+
+func TestPeriodRollover(t *testing.T) {
+	lb, s := newLeaderboard(t, Options{Periods: []Period{Daily, Total, Weekly}})
+	ctx := context.Background()
+	now := time.Now().UTC()
+	yesterday := lb.At(now.AddDate(0, 0, -1))
+
+	if score, err := yesterday.Incr(ctx, "a", 5); err != nil || score != 5 {
+		t.Fatalf("Incr = %v, %v", score, err)
+	}
+	// 返回第一个配置周期(日榜)中的分数，进入新的一天后从0开始
+	if score, err := lb.Incr(ctx, "a", 3); err != nil || score != 3 {
+		t.Fatalf("Incr on a new day = %v, %v", score, err)
+	}
+	if e, err := lb.Board(Total).Rank(ctx, "a"); err != nil || e.Score != 8 {
+		t.Fatalf("total Rank = %+v, %v", e, err)
+	}
+	if e, err := lb.Past(Daily, 1).Rank(ctx, "a"); err != nil || e.Score != 5 {
+		t.Fatalf("yesterday Rank = %+v, %v", e, err)
+	}
+	if lb.Past(Daily, 1).Key() != yesterday.Board(Daily).Key() {
+		t.Fatal("Past(Daily, 1) should be yesterday's board")
+	}
+
+	// 周期榜单保留到下一个周期结束，总榜不过期
+	today := lb.periodStart(Daily, now)
+	daily := lb.Board(Daily).Key()
+	if ttl, _ := s.Do("TTL", daily).(int64); ttl <= 0 || ttl > int64(today.AddDate(0, 0, 2).Sub(now)/time.Second)+1 {
+		t.Fatalf("TTL of %s = %d", daily, ttl)
+	}
+	if ttl, _ := s.Do("TTL", lb.Board(Total).Key()).(int64); ttl != -1 {
+		t.Fatalf("TTL of the total board = %d, want -1", ttl)
+	}
+	weekly := lb.Board(Weekly).Key()
+	if ttl, _ := s.Do("TTL", weekly).(int64); ttl <= int64(time.Until(lb.periodStart(Weekly, now).AddDate(0, 0, 7))/time.Second) {
+		t.Fatalf("TTL of %s = %d, want beyond the end of the week", weekly, ttl)
+	}
+
+	if _, err := New(lb.client, "points", Options{Periods: []Period{"hourly"}}).Incr(ctx, "a", 1); err != ErrInvalidPeriod {
+		t.Fatalf("Incr with an invalid period = %v, want ErrInvalidPeriod", err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	lb, s := newLeaderboard(t, Options{Periods: []Period{Daily}})
+	ctx := context.Background()
+	yesterday := lb.At(time.Now().UTC().AddDate(0, 0, -1))
+	yesterday.Incr(ctx, "a", 10)
+	yesterday.Incr(ctx, "b", 4)
+	lb.Incr(ctx, "a", 1)
+	lb.Incr(ctx, "c", 5)
+
+	board, err := lb.Merge(ctx, "last2days", time.Minute,
+		Part{Period: Daily},
+		Part{Period: Daily, Ago: 1, Weight: 0.5},
+		Part{Period: Daily, Ago: 2},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board.Key() != "leaderboard:{points}:merge:last2days" {
+		t.Fatalf("Key = %s", board.Key())
+	}
+	entries, total, err := board.Top(ctx, 1, 10)
+	if err != nil || total != 3 {
+		t.Fatalf("Top = %v, %d, %v", entries, total, err)
+	}
+	// a = 1 + 10*0.5, b = 4*0.5, c = 5
+	checkEntries(t, "merged Top", entries, "a", 1, "c", 2, "b", 3)
+	if entries[0].Score != 6 || entries[2].Score != 2 {
+		t.Fatalf("merged scores = %+v", entries)
+	}
+	if ttl, _ := s.Do("PTTL", board.Key()).(int64); ttl <= 0 || ttl > 60000 {
+		t.Fatalf("PTTL = %d", ttl)
+	}
+
+	// 同名合并覆盖之前的结果，ttl为0时不过期
+	board, err = lb.Merge(ctx, "last2days", 0, Part{Period: Daily, Ago: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _, _ = board.Top(ctx, 1, 10)
+	checkEntries(t, "merged Top", entries, "a", 1, "b", 2)
+	if ttl, _ := s.Do("PTTL", board.Key()).(int64); ttl != -1 {
+		t.Fatalf("PTTL = %d, want -1", ttl)
+	}
+
+	if _, err := lb.Merge(ctx, "bad", 0, Part{Period: "hourly"}); err != ErrInvalidPeriod {
+		t.Fatalf("Merge with an invalid period = %v, want ErrInvalidPeriod", err)
+	}
+}
@@ -0,0 +1,286 @@
+// 分布式锁组件，基于Redis实现
+// 加锁使用 SET key token NX PX ttl，解锁与续期均通过Lua脚本校验token，避免误删其他持有者的锁；
+// 开启看门狗后，持有锁期间会定时自动续期，直到解锁、续期确认锁已丢失，或连续续期失败超过租期。
+//
+// 在集群中仅让一个实例执行定时任务：
+//
+//	pcron.AddSingleton(ctx, "0 */5 * * * *", plock.Job(redisClient, "cron:report", job), "report")
+package plock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/perpower/goframe/utils/pcron"
+	"github.com/perpower/goframe/utils/pdb/redis"
+	"github.com/perpower/goframe/utils/prand"
+)
+
+type Options struct {
+	TTL              time.Duration // 锁租期
+	RetryInterval    time.Duration // Lock获取失败后的初始重试间隔，之后按指数退避
+	MaxRetryInterval time.Duration // 最大重试间隔
+	Watchdog         bool          // 是否开启看门狗自动续期
+}
+
+type Mutex struct {
+	mu     sync.Mutex
+	client *redis.Client
+	key    string
+	opts   Options
+	token  string
+	lost   chan struct{} // 锁丢失(续期失败)时关闭
+	stop   chan struct{} // 解锁时关闭，用于结束看门狗
+	done   chan struct{} // 看门狗退出时关闭
+}
+
+var (
+	defaultTTL              = 30 * time.Second
+	defaultRetryInterval    = 50 * time.Millisecond
+	defaultMaxRetryInterval = time.Second
+	tokenLength             = 32
+
+	ErrNotHeld = errors.New("plock: 锁未持有或已过期")
+	ErrHeld    = errors.New("plock: 锁已被当前对象持有")
+
+	// 仅当token匹配时删除
+	unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	// 仅当token匹配时续期
+	extendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// New 创建分布式锁对象，同一个Mutex对象同一时刻只能被一个持有者使用
+// client: *redis.Client redis客户端
+// key: string 锁的键名
+// opts: Options 可选配置，未设置的项使用默认值
+func New(client *redis.Client, key string, opts ...Options) *Mutex {
+	opt := Options{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.TTL <= 0 {
+		opt.TTL = defaultTTL
+	}
+	if opt.RetryInterval <= 0 {
+		opt.RetryInterval = defaultRetryInterval
+	}
+	if opt.MaxRetryInterval < opt.RetryInterval {
+		opt.MaxRetryInterval = defaultMaxRetryInterval
+		if opt.MaxRetryInterval < opt.RetryInterval {
+			opt.MaxRetryInterval = opt.RetryInterval
+		}
+	}
+	return &Mutex{
+		client: client,
+		key:    key,
+		opts:   opt,
+	}
+}
+
+// Key 返回锁的键名
+func (m *Mutex) Key() string {
+	return m.key
+}
+
+// Token 返回当前持有锁的token，未持有时返回空
+func (m *Mutex) Token() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.token
+}
+
+// TryLock 尝试获取锁，不等待
+// return: bool 是否获取成功，当前对象已持有锁时返回 ErrHeld
+func (m *Mutex) TryLock() (bool, error) {
+	return m.tryLock(m.client)
+}
+
+// Lock 阻塞获取锁，获取失败时按指数退避重试，直到成功或ctx结束
+// return: 当前对象已持有锁时返回 ErrHeld
+func (m *Mutex) Lock(ctx context.Context) error {
+	client := m.client.WithContext(ctx)
+	interval := m.opts.RetryInterval
+	for {
+		ok, err := m.tryLock(client)
+		if err != nil || ok {
+			return err
+		}
+
+		// 加入随机抖动，避免多个竞争者同时重试
+		wait := prand.Duration(interval/2, interval)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		interval *= 2
+		if interval > m.opts.MaxRetryInterval {
+			interval = m.opts.MaxRetryInterval
+		}
+	}
+}
+
+func (m *Mutex) tryLock(client *redis.Client) (bool, error) {
+	if m.Token() != "" {
+		return false, ErrHeld
+	}
+
+	token := prand.Letters(tokenLength)
+	acquired := time.Now()
+	reply, err := client.Do("SET", m.key, token, "NX", "PX", m.opts.TTL.Milliseconds())
+	if err != nil || reply == nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.token != "" {
+		// 并发调用时其他调用已记录了持有状态(锁在Redis中过期后被重新获取)，释放本次获取的锁
+		unlockScript.Do(m.client, []string{m.key}, token)
+		return false, ErrHeld
+	}
+	m.token = token
+	m.lost = make(chan struct{})
+	if m.opts.Watchdog {
+		m.stop = make(chan struct{})
+		m.done = make(chan struct{})
+		go m.watchdog(token, acquired.Add(m.opts.TTL), m.stop, m.done, m.lost)
+	}
+	return true, nil
+}
+
+// Unlock 释放锁，仅当锁仍由当前持有者持有时才会删除
+// return: 锁已过期或被其他持有者获取时返回 ErrNotHeld
+func (m *Mutex) Unlock() error {
+	m.mu.Lock()
+	token := m.token
+	stop, done := m.stop, m.done
+	m.token, m.stop, m.done, m.lost = "", nil, nil, nil
+	m.mu.Unlock()
+
+	if token == "" {
+		return ErrNotHeld
+	}
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	n, err := unlockScript.Do(m.client, []string{m.key}, token).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// Extend 延长锁的租期
+// ttl: time.Duration 新的租期，传0使用创建时的TTL
+// return: 锁已过期或被其他持有者获取时返回 ErrNotHeld
+func (m *Mutex) Extend(ttl time.Duration) error {
+	token := m.Token()
+	if token == "" {
+		return ErrNotHeld
+	}
+	if ttl <= 0 {
+		ttl = m.opts.TTL
+	}
+	return m.extend(m.client, token, ttl)
+}
+
+func (m *Mutex) extend(client *redis.Client, token string, ttl time.Duration) error {
+	n, err := extendScript.Do(client, []string{m.key}, token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// Lost 返回一个在锁丢失(看门狗续期失败)时关闭的通道，未持有锁时返回nil
+func (m *Mutex) Lost() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lost
+}
+
+// 看门狗，每隔TTL/3续期一次，续期确认失败(锁已被删除或被他人持有)时通知锁丢失并退出；
+// 每次续期最多等待TTL/3且不超过租期截止时间，避免连接卡住时看门狗无法判断锁已丢失
+// expires: time.Time 按最近一次成功加锁或续期的发送时间计算的租期截止时间
+func (m *Mutex) watchdog(token string, expires time.Time, stop, done, lost chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(m.opts.TTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			sent := time.Now()
+			ctx, cancel := context.WithDeadline(m.client.Context(), expires)
+			err := m.extend(m.client.WithContext(ctx).WithTimeout(m.opts.TTL/3), token, m.opts.TTL)
+			cancel()
+			if err == nil {
+				expires = sent.Add(m.opts.TTL)
+				continue
+			}
+			// 网络等临时错误时继续尝试，直到租期耗尽，此时锁可能已被他人获取
+			if err == ErrNotHeld || !time.Now().Before(expires) {
+				close(lost)
+				return
+			}
+		}
+	}
+}
+
+// Job 将定时任务包装为集群单例任务：每次触发时尝试获取锁，获取失败说明其他实例正在执行，直接跳过；
+// 执行期间自动续期，锁丢失时任务的ctx会被取消
+// client: *redis.Client redis客户端
+// key: string 锁的键名，不同任务需使用不同的键名
+// job: pcron.JobFunc 实际执行的任务
+// opts: Options 可选配置，Watchdog始终开启
+func Job(client *redis.Client, key string, job pcron.JobFunc, opts ...Options) pcron.JobFunc {
+	opt := Options{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	opt.Watchdog = true
+
+	return func(ctx context.Context) {
+		m := New(client, key, opt)
+		ok, err := m.TryLock()
+		if err != nil || !ok {
+			return
+		}
+		defer m.Unlock()
+
+		jobCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-m.Lost():
+				cancel()
+			case <-jobCtx.Done():
+			}
+		}()
+		job(jobCtx)
+	}
+}
@@ -0,0 +1,196 @@
+package plock
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/perpower/goframe/utils/pdb/redis"
+	"github.com/perpower/goframe/utils/pdb/redis/redistest"
+)
+
+func newClient(t *testing.T, s *redistest.Server) *redis.Client {
+	t.Helper()
+	client, err := redis.New(redis.Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// 等待锁丢失的通知
+func waitLost(t *testing.T, m *Mutex, within time.Duration) {
+	t.Helper()
+	select {
+	case <-m.Lost():
+	case <-time.After(within):
+		t.Fatalf("lock not reported lost within %s", within)
+	}
+}
+
+func TestTryLockAndUnlock(t *testing.T) {
+	s := redistest.NewServer(t)
+	client := newClient(t, s)
+	m := New(client, "lock:a", Options{TTL: time.Second})
+	other := New(client, "lock:a")
+
+	ok, err := m.TryLock()
+	if err != nil || !ok {
+		t.Fatalf("TryLock = %v, %v", ok, err)
+	}
+	if got := s.Do("GET", "lock:a"); got != m.Token() {
+		t.Fatalf("stored token %v, want %s", got, m.Token())
+	}
+	if ttl, _ := s.Do("PTTL", "lock:a").(int64); ttl <= 0 || ttl > 1000 {
+		t.Fatalf("PTTL = %d, want within the TTL", ttl)
+	}
+	if m.Lost() == nil {
+		t.Fatal("Lost() should not be nil while the lock is held")
+	}
+
+	// 已被其他对象持有时获取失败，同一对象重复获取返回ErrHeld
+	if ok, err := other.TryLock(); err != nil || ok {
+		t.Fatalf("TryLock on a held lock = %v, %v", ok, err)
+	}
+	if _, err := m.TryLock(); err != ErrHeld {
+		t.Fatalf("relock = %v, want ErrHeld", err)
+	}
+	if err := other.Unlock(); err != ErrNotHeld {
+		t.Fatalf("Unlock by a non-holder = %v, want ErrNotHeld", err)
+	}
+
+	if err := m.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Do("EXISTS", "lock:a") != int64(0) {
+		t.Fatal("Unlock should delete the key")
+	}
+	if m.Token() != "" || m.Lost() != nil {
+		t.Fatal("Token and Lost should be reset after Unlock")
+	}
+	if err := m.Unlock(); err != ErrNotHeld {
+		t.Fatalf("second Unlock = %v, want ErrNotHeld", err)
+	}
+
+	// 解锁后可以再次获取
+	if ok, err := m.TryLock(); err != nil || !ok {
+		t.Fatalf("TryLock after Unlock = %v, %v", ok, err)
+	}
+}
+
+func TestUnlockAfterExpiryKeepsNewHolder(t *testing.T) {
+	s := redistest.NewServer(t)
+	client := newClient(t, s)
+	m := New(client, "lock:b", Options{TTL: 50 * time.Millisecond})
+	if ok, err := m.TryLock(); err != nil || !ok {
+		t.Fatalf("TryLock = %v, %v", ok, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	other := New(client, "lock:b")
+	if ok, err := other.TryLock(); err != nil || !ok {
+		t.Fatalf("TryLock after expiry = %v, %v", ok, err)
+	}
+	if err := m.Extend(0); err != ErrNotHeld {
+		t.Fatalf("Extend after expiry = %v, want ErrNotHeld", err)
+	}
+	if err := m.Unlock(); err != ErrNotHeld {
+		t.Fatalf("Unlock after expiry = %v, want ErrNotHeld", err)
+	}
+	if got := s.Do("GET", "lock:b"); got != other.Token() {
+		t.Fatalf("new holder's lock was removed: %v", got)
+	}
+}
+
+func TestLockWaitsForRelease(t *testing.T) {
+	s := redistest.NewServer(t)
+	client := newClient(t, s)
+	holder := New(client, "lock:c")
+	if ok, err := holder.TryLock(); err != nil || !ok {
+		t.Fatalf("TryLock = %v, %v", ok, err)
+	}
+
+	m := New(client, "lock:c", Options{RetryInterval: 10 * time.Millisecond, MaxRetryInterval: 20 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := m.Lock(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Lock on a held lock = %v, want DeadlineExceeded", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		holder.Unlock()
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := m.Lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Lock(ctx); err != ErrHeld {
+		t.Fatalf("relock = %v, want ErrHeld", err)
+	}
+}
+
+func TestWatchdogExtendsLease(t *testing.T) {
+	s := redistest.NewServer(t)
+	client := newClient(t, s)
+	m := New(client, "lock:d", Options{TTL: 150 * time.Millisecond, Watchdog: true})
+	if ok, err := m.TryLock(); err != nil || !ok {
+		t.Fatalf("TryLock = %v, %v", ok, err)
+	}
+
+	time.Sleep(400 * time.Millisecond)
+	select {
+	case <-m.Lost():
+		t.Fatal("lock reported lost while the watchdog keeps extending it")
+	default:
+	}
+	if got := s.Do("GET", "lock:d"); got != m.Token() {
+		t.Fatalf("lease expired despite the watchdog: %v", got)
+	}
+	if err := m.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 锁被删除后续期返回0，看门狗通知锁丢失
+	if ok, err := m.TryLock(); err != nil || !ok {
+		t.Fatalf("TryLock = %v, %v", ok, err)
+	}
+	s.Do("DEL", "lock:d")
+	waitLost(t, m, time.Second)
+}
+
+func TestWatchdogGivesUpOnHungConnection(t *testing.T) {
+	var hang int32
+	release := make(chan struct{})
+	s := redistest.NewServer(t, func(s *redistest.Server) {
+		s.Hook = func(c *redistest.Conn, args []string) (interface{}, bool) {
+			if atomic.LoadInt32(&hang) == 1 && strings.HasPrefix(strings.ToUpper(args[0]), "EVAL") {
+				<-release
+			}
+			return nil, false
+		}
+	})
+	t.Cleanup(func() { close(release) })
+	client := newClient(t, s)
+	m := New(client, "lock:e", Options{TTL: 150 * time.Millisecond, Watchdog: true})
+	if ok, err := m.TryLock(); err != nil || !ok {
+		t.Fatalf("TryLock = %v, %v", ok, err)
+	}
+
+	// 续期命令得不到回复时按超时处理，租期耗尽后通知锁丢失
+	atomic.StoreInt32(&hang, 1)
+	start := time.Now()
+	waitLost(t, m, time.Second)
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("lock reported lost after %s, before the lease expired", elapsed)
+	}
+	atomic.StoreInt32(&hang, 0)
+	// 锁在Redis中已过期
+	if err := m.Unlock(); err != ErrNotHeld {
+		t.Fatalf("Unlock after losing the lock = %v, want ErrNotHeld", err)
+	}
+}
@@ -0,0 +1,242 @@
+// 计数器、仪表、直方图
+package pmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets 默认直方图桶，单位秒，适用于毫秒级的耗时统计
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// vec 按标签值分组的同名指标
+type vec[T any] struct {
+	d      *desc
+	create func() T
+	mu     sync.RWMutex
+	series map[string]*series[T]
+}
+
+type series[T any] struct {
+	values []string
+	metric T
+}
+
+func newVec[T any](d *desc, create func() T) *vec[T] {
+	return &vec[T]{
+		d:      d,
+		create: create,
+		series: map[string]*series[T]{},
+	}
+}
+
+func (v *vec[T]) desc() *desc {
+	return v.d
+}
+
+// 返回标签值对应的指标，不存在时创建；标签值数量与标签名不一致时panic
+func (v *vec[T]) with(values []string) T {
+	if len(values) != len(v.d.labels) {
+		panic(fmt.Sprintf("pmetrics: 指标 %s 需要 %d 个标签值，传入 %d 个", v.d.name, len(v.d.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.RLock()
+	s, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return s.metric
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.series[key]; ok {
+		return s.metric
+	}
+	s = &series[T]{values: append([]string{}, values...), metric: v.create()}
+	v.series[key] = s
+	return s.metric
+}
+
+// 按标签值排序后的全部序列，保证输出顺序稳定
+func (v *vec[T]) sorted() []*series[T] {
+	v.mu.RLock()
+	arr := make([]*series[T], 0, len(v.series))
+	for _, s := range v.series {
+		arr = append(arr, s)
+	}
+	v.mu.RUnlock()
+	sort.Slice(arr, func(i, j int) bool {
+		return strings.Join(arr[i].values, "\xff") < strings.Join(arr[j].values, "\xff")
+	})
+	return arr
+}
+
+// 原子浮点数
+type atomicFloat struct {
+	bits uint64
+}
+
+func (f *atomicFloat) add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		next := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&f.bits, old, next) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) set(v float64) {
+	atomic.StoreUint64(&f.bits, math.Float64bits(v))
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+// CounterVec 按标签分组的计数器
+type CounterVec struct {
+	*vec[*Counter]
+}
+
+// Counter 计数器
+type Counter struct {
+	v atomicFloat
+}
+
+// With 返回标签值对应的计数器，标签值顺序与注册时的标签名一致
+func (c *CounterVec) With(values ...string) *Counter {
+	return c.with(values)
+}
+
+// Inc 计数加1
+func (c *Counter) Inc() {
+	c.v.add(1)
+}
+
+// Add 计数增加delta，delta不能为负数，负数将被忽略
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.v.add(delta)
+}
+
+// Value 返回当前计数
+func (c *Counter) Value() float64 {
+	return c.v.load()
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	for _, s := range c.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", c.d.name, labelPairs(c.d.labels, s.values), formatFloat(s.metric.Value()))
+	}
+}
+
+// GaugeVec 按标签分组的仪表
+type GaugeVec struct {
+	*vec[*Gauge]
+}
+
+// Gauge 仪表
+type Gauge struct {
+	v atomicFloat
+}
+
+// With 返回标签值对应的仪表，标签值顺序与注册时的标签名一致
+func (g *GaugeVec) With(values ...string) *Gauge {
+	return g.with(values)
+}
+
+// Set 设置当前值
+func (g *Gauge) Set(v float64) {
+	g.v.set(v)
+}
+
+// Add 增加delta，delta可以为负数
+func (g *Gauge) Add(delta float64) {
+	g.v.add(delta)
+}
+
+// Inc 加1
+func (g *Gauge) Inc() {
+	g.v.add(1)
+}
+
+// Dec 减1
+func (g *Gauge) Dec() {
+	g.v.add(-1)
+}
+
+// Value 返回当前值
+func (g *Gauge) Value() float64 {
+	return g.v.load()
+}
+
+func (g *GaugeVec) write(w *bufio.Writer) {
+	for _, s := range g.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", g.d.name, labelPairs(g.d.labels, s.values), formatFloat(s.metric.Value()))
+	}
+}
+
+// HistogramVec 按标签分组的直方图
+type HistogramVec struct {
+	*vec[*Histogram]
+}
+
+// Histogram 直方图，统计落入各个桶的样本数量及样本总和
+type Histogram struct {
+	upper  []float64
+	counts []uint64 // 各桶的样本数(非累计)，最后一个为 +Inf
+	count  uint64
+	sum    atomicFloat
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		upper:  buckets,
+		counts: make([]uint64, len(buckets)+1),
+	}
+}
+
+// With 返回标签值对应的直方图，标签值顺序与注册时的标签名一致
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.with(values)
+}
+
+// Observe 记录一个样本
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.upper, v)
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	h.sum.add(v)
+}
+
+// Count 返回样本数量
+func (h *Histogram) Count() uint64 {
+	return atomic.LoadUint64(&h.count)
+}
+
+// Sum 返回样本总和
+func (h *Histogram) Sum() float64 {
+	return h.sum.load()
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	for _, s := range h.sorted() {
+		m := s.metric
+		var cumulative uint64
+		for i, upper := range m.upper {
+			cumulative += atomic.LoadUint64(&m.counts[i])
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.d.name, labelPairs(h.d.labels, s.values, "le", formatFloat(upper)), cumulative)
+		}
+		cumulative += atomic.LoadUint64(&m.counts[len(m.upper)])
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.d.name, labelPairs(h.d.labels, s.values, "le", "+Inf"), cumulative)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.d.name, labelPairs(h.d.labels, s.values), formatFloat(m.Sum()))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.d.name, labelPairs(h.d.labels, s.values), cumulative)
+	}
+}
@@ -0,0 +1,202 @@
+// 进程内指标注册表，提供计数器(Counter)、仪表(Gauge)、直方图(Histogram)三种指标，
+// 并以Prometheus文本格式输出，供Prometheus等监控系统抓取，例如：
+//
+//	requests := pmetrics.Default.Counter("http_requests_total", "HTTP请求数", "path", "code")
+//	requests.With("/login", "200").Inc()
+//
+//	r := gin.New()
+//	r.GET("/metrics", gin.WrapH(pmetrics.Default.Handler()))
+package pmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry 指标注册表，并发安全
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]metric
+	names   []string // 按注册顺序输出
+}
+
+// metric 各类指标的公共接口
+type metric interface {
+	desc() *desc
+	write(w *bufio.Writer)
+}
+
+// desc 指标描述
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+// Default 默认注册表
+var Default = NewRegistry()
+
+// NewRegistry 创建一个空的注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: map[string]metric{},
+	}
+}
+
+// Counter 注册或返回同名的计数器，计数器只增不减
+// name: string 指标名，如 redis_commands_total
+// help: string 指标说明
+// labels: ...string 标签名
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	m := r.register(&desc{name: name, help: help, typ: "counter", labels: labels}, func(d *desc) metric {
+		return &CounterVec{vec: newVec(d, func() *Counter { return &Counter{} })}
+	})
+	return m.(*CounterVec)
+}
+
+// Gauge 注册或返回同名的仪表，仪表可增可减，用于连接数、队列长度等瞬时值
+// name: string 指标名
+// help: string 指标说明
+// labels: ...string 标签名
+func (r *Registry) Gauge(name, help string, labels ...string) *GaugeVec {
+	m := r.register(&desc{name: name, help: help, typ: "gauge", labels: labels}, func(d *desc) metric {
+		return &GaugeVec{vec: newVec(d, func() *Gauge { return &Gauge{} })}
+	})
+	return m.(*GaugeVec)
+}
+
+// Histogram 注册或返回同名的直方图，用于耗时、大小等分布统计
+// name: string 指标名，如 redis_command_duration_seconds
+// help: string 指标说明
+// buckets: []float64 桶的上界，升序排列，为空时使用 DefaultBuckets
+// labels: ...string 标签名
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	m := r.register(&desc{name: name, help: help, typ: "histogram", labels: labels}, func(d *desc) metric {
+		return &HistogramVec{vec: newVec(d, func() *Histogram { return newHistogram(buckets) })}
+	})
+	return m.(*HistogramVec)
+}
+
+// 注册指标，同名指标已存在时直接返回，类型或标签不一致时panic
+func (r *Registry) register(d *desc, create func(d *desc) metric) metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.metrics[d.name]; ok {
+		exist := m.desc()
+		if exist.typ != d.typ || strings.Join(exist.labels, ",") != strings.Join(d.labels, ",") {
+			panic(fmt.Sprintf("pmetrics: 指标 %s 已注册为 %s%v，与 %s%v 不一致", d.name, exist.typ, exist.labels, d.typ, d.labels))
+		}
+		return m
+	}
+	m := create(d)
+	r.metrics[d.name] = m
+	r.names = append(r.names, d.name)
+	return m
+}
+
+// WriteTo 以Prometheus文本格式输出全部指标
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	metrics := make([]metric, 0, len(r.names))
+	for _, name := range r.names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.mu.RUnlock()
+
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range metrics {
+		d := m.desc()
+		fmt.Fprintf(bw, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", d.name, d.typ)
+		m.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler 返回输出指标的http.Handler，可挂载到 /metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// 组装 {k="v",...} 形式的标签，extra为额外追加的标签(如直方图的le)
+func labelPairs(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(extra[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
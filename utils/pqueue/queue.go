@@ -0,0 +1,459 @@
+// 延迟队列组件，基于Redis实现，任务持久化在Redis中，进程重启不丢失
+// 任务按到期时间存入有序集合，到期后由搬运(Promote)原子地转入就绪列表；
+// 消费者取出任务时记录可见性超时，超时未确认(进程崩溃等)的任务会重新投递；
+// 执行失败按退避时间重试，超过最大重试次数后转入死信列表。
+//
+//	q := pqueue.New(redisClient, "order:cancel")
+//	id, err := q.Enqueue(ctx, orderNo, 30*time.Minute)
+//	q.Cancel(ctx, id) // 订单已支付，取消任务
+//
+//	// 启动消费者，ctx结束后等待执行中的任务完成再返回
+//	q.Run(ctx, 8, func(ctx context.Context, job *pqueue.Job) error {
+//		return cancelOrder(ctx, job.Payload)
+//	})
+//
+// 任务至少执行一次，处理逻辑需保证幂等
+package pqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/perpower/goframe/utils/pdb/redis"
+	"github.com/perpower/goframe/utils/prand"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+type Options struct {
+	Prefix          string                          // 键名前缀，默认 "queue:"
+	Visibility      time.Duration                   // 可见性超时，任务取出后超过该时间未确认则重新投递，执行期间自动续期，默认30s
+	MaxRetries      int                             // 默认最大重试次数，默认3，负数表示不重试
+	Backoff         func(attempt int) time.Duration // 第attempt次执行失败后的重试等待时间，默认从1s开始指数增长，最大10m
+	PollInterval    time.Duration                   // 搬运到期任务及就绪列表为空时的轮询间隔，默认1s
+	BatchSize       int                             // 每次搬运的最大任务数，默认100
+	ShutdownTimeout time.Duration                   // Run退出时等待执行中任务完成的最长时间，超时后取消任务的ctx，默认30s
+	OnError         func(err error)                 // 消费过程中出现Redis错误时回调
+}
+
+// EnqueueOptions 任务选项
+type EnqueueOptions struct {
+	ID         string // 任务ID，不指定时自动生成；指定时可用于去重，相同ID的任务未完成时再次添加返回 ErrJobExists
+	MaxRetries int    // 最大重试次数，0使用队列默认值，负数表示不重试
+}
+
+// Job 队列中的任务
+type Job struct {
+	ID         string
+	Payload    string
+	Attempt    int       // 当前为第几次执行，从1开始
+	MaxRetries int       // 最大重试次数
+	CreatedAt  time.Time // 添加时间
+	Error      string    // 最近一次执行失败的原因
+}
+
+// Stats 队列中各状态的任务数
+type Stats struct {
+	Delayed int // 未到期
+	Ready   int // 已到期等待执行
+	Running int // 执行中
+	Dead    int // 死信
+}
+
+type Queue struct {
+	client *redis.Client
+	name   string
+	opts   Options
+}
+
+// 任务内容，以JSON存储
+type jobBody struct {
+	Payload    string `json:"payload"`
+	MaxRetries int    `json:"max_retries"`
+	CreatedAt  int64  `json:"created_at"` // 毫秒时间戳
+}
+
+var (
+	defaultPrefix          = "queue:"
+	defaultVisibility      = 30 * time.Second
+	defaultMaxRetries      = 3
+	defaultPollInterval    = time.Second
+	defaultBatchSize       = 100
+	defaultShutdownTimeout = 30 * time.Second
+	minBackoff             = time.Second
+	maxBackoff             = 10 * time.Minute
+	idLength               = 20
+
+	ErrJobExists = errors.New("pqueue: 相同ID的任务已存在")
+	// ErrDiscard 处理函数返回包装了该错误的error时，任务不再重试，直接转入死信列表
+	ErrDiscard = errors.New("pqueue: 任务不再重试")
+	errTimeout = errors.New("pqueue: 任务多次执行超时未确认")
+
+	// KEYS: jobs, delayed, ready  ARGV: id, body, due, now
+	enqueueScript = redis.NewScript(`
+if redis.call("HSETNX", KEYS[1], ARGV[1], ARGV[2]) == 0 then
+	return 0
+end
+if tonumber(ARGV[3]) <= tonumber(ARGV[4]) then
+	redis.call("RPUSH", KEYS[3], ARGV[1])
+else
+	redis.call("ZADD", KEYS[2], ARGV[3], ARGV[1])
+end
+return 1`)
+
+	// 到期任务及可见性超时的任务转入就绪列表
+	// KEYS: delayed, ready, running  ARGV: now, limit
+	promoteScript = redis.NewScript(`
+local n = 0
+for _, key in ipairs({KEYS[1], KEYS[3]}) do
+	local ids = redis.call("ZRANGEBYSCORE", key, "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+	for _, id in ipairs(ids) do
+		redis.call("ZREM", key, id)
+		redis.call("RPUSH", KEYS[2], id)
+	end
+	n = n + #ids
+end
+return n`)
+
+	// 取出一个就绪任务，执行次数加1并记录可见性超时
+	// KEYS: ready, jobs, attempts, running  ARGV: deadline
+	reserveScript = redis.NewScript(`
+while true do
+	local id = redis.call("LPOP", KEYS[1])
+	if not id then
+		return false
+	end
+	local body = redis.call("HGET", KEYS[2], id)
+	if body then
+		local attempt = redis.call("HINCRBY", KEYS[3], id, 1)
+		redis.call("ZADD", KEYS[4], ARGV[1], id)
+		return {id, attempt, body}
+	end
+end`)
+
+	// 任务续期，仅当任务仍由本次执行持有时生效
+	// KEYS: attempts, running  ARGV: id, attempt, deadline
+	touchScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], ARGV[1]) ~= ARGV[2] then
+	return 0
+end
+return redis.call("ZADD", KEYS[2], "XX", "CH", ARGV[3], ARGV[1])`)
+
+	// 执行失败，重新延迟或转入死信，仅当任务仍由本次执行持有时生效
+	// KEYS: attempts, errors, running, delayed, dead  ARGV: id, attempt, due, error, dead
+	failScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], ARGV[1]) ~= ARGV[2] then
+	return 0
+end
+if redis.call("ZREM", KEYS[3], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[2], ARGV[1], ARGV[4])
+if ARGV[5] == "1" then
+	redis.call("RPUSH", KEYS[5], ARGV[1])
+else
+	redis.call("ZADD", KEYS[4], ARGV[3], ARGV[1])
+end
+return 1`)
+
+	// 执行成功，删除任务，仅当任务仍由本次执行持有时生效；
+	// 可见性超时后已被重新取出的任务由新的执行负责，超时后尚未被取出的任务从就绪列表中一并删除
+	// KEYS: attempts, jobs, errors, delayed, ready, running  ARGV: id, attempt
+	ackScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], ARGV[1]) ~= ARGV[2] then
+	return 0
+end
+redis.call("HDEL", KEYS[1], ARGV[1])
+redis.call("HDEL", KEYS[2], ARGV[1])
+redis.call("HDEL", KEYS[3], ARGV[1])
+redis.call("ZREM", KEYS[4], ARGV[1])
+redis.call("LREM", KEYS[5], 0, ARGV[1])
+redis.call("ZREM", KEYS[6], ARGV[1])
+return 1`)
+
+	// 删除任务，无论其处于何种状态
+	// KEYS: jobs, attempts, errors, delayed, ready, running, dead  ARGV: id
+	removeScript = redis.NewScript(`
+local n = redis.call("HDEL", KEYS[1], ARGV[1])
+redis.call("HDEL", KEYS[2], ARGV[1])
+redis.call("HDEL", KEYS[3], ARGV[1])
+redis.call("ZREM", KEYS[4], ARGV[1])
+redis.call("LREM", KEYS[5], 0, ARGV[1])
+redis.call("ZREM", KEYS[6], ARGV[1])
+redis.call("LREM", KEYS[7], 0, ARGV[1])
+return n`)
+
+	// 死信任务重新投递，执行次数清零
+	// KEYS: dead, ready, attempts, errors  ARGV: count
+	requeueScript = redis.NewScript(`
+local n = 0
+for i = 1, tonumber(ARGV[1]) do
+	local id = redis.call("LPOP", KEYS[1])
+	if not id then
+		break
+	end
+	redis.call("HDEL", KEYS[3], id)
+	redis.call("HDEL", KEYS[4], id)
+	redis.call("RPUSH", KEYS[2], id)
+	n = n + 1
+end
+return n`)
+)
+
+// New 创建延迟队列
+// client: *redis.Client redis客户端
+// name: string 队列名称
+// opts: Options 可选配置，未设置的项使用默认值
+func New(client *redis.Client, name string, opts ...Options) *Queue {
+	opt := Options{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Prefix == "" {
+		opt.Prefix = defaultPrefix
+	}
+	if opt.Visibility <= 0 {
+		opt.Visibility = defaultVisibility
+	}
+	if opt.MaxRetries == 0 {
+		opt.MaxRetries = defaultMaxRetries
+	}
+	if opt.Backoff == nil {
+		opt.Backoff = defaultBackoff
+	}
+	if opt.PollInterval <= 0 {
+		opt.PollInterval = defaultPollInterval
+	}
+	if opt.BatchSize <= 0 {
+		opt.BatchSize = defaultBatchSize
+	}
+	if opt.ShutdownTimeout <= 0 {
+		opt.ShutdownTimeout = defaultShutdownTimeout
+	}
+	return &Queue{
+		client: client,
+		name:   name,
+		opts:   opt,
+	}
+}
+
+// Enqueue 添加延迟任务
+// payload: string 任务内容
+// delay: time.Duration 延迟时间，小于等于0时立即就绪
+// return: string 任务ID
+func (q *Queue) Enqueue(ctx context.Context, payload string, delay time.Duration, opts ...EnqueueOptions) (string, error) {
+	return q.EnqueueAt(ctx, payload, time.Now().Add(delay), opts...)
+}
+
+// EnqueueAt 添加定时任务，到达指定时间后执行
+func (q *Queue) EnqueueAt(ctx context.Context, payload string, at time.Time, opts ...EnqueueOptions) (string, error) {
+	opt := EnqueueOptions{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.ID == "" {
+		opt.ID = prand.Letters(idLength)
+	}
+	if opt.MaxRetries == 0 {
+		opt.MaxRetries = q.opts.MaxRetries
+	}
+	if opt.MaxRetries < 0 {
+		opt.MaxRetries = 0
+	}
+
+	now := time.Now()
+	body, err := json.Marshal(jobBody{
+		Payload:    payload,
+		MaxRetries: opt.MaxRetries,
+		CreatedAt:  now.UnixMilli(),
+	})
+	if err != nil {
+		return "", err
+	}
+	n, err := enqueueScript.Do(q.client.WithContext(ctx), q.keys("jobs", "delayed", "ready"),
+		opt.ID, string(body), at.UnixMilli(), now.UnixMilli()).Int()
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return opt.ID, ErrJobExists
+	}
+	return opt.ID, nil
+}
+
+// Cancel 删除任务，无论任务处于等待、执行中还是死信状态
+// return: bool 任务是否存在
+func (q *Queue) Cancel(ctx context.Context, id string) (bool, error) {
+	n, err := removeScript.Do(q.client.WithContext(ctx),
+		q.keys("jobs", "attempts", "errors", "delayed", "ready", "running", "dead"), id).Int()
+	return n > 0, err
+}
+
+// Promote 将到期任务及可见性超时的任务转入就绪列表，Run会定时调用，也可由单独的进程调用
+// return: int 转入的任务数
+func (q *Queue) Promote(ctx context.Context) (int, error) {
+	total := 0
+	client := q.client.WithContext(ctx)
+	for {
+		n, err := promoteScript.Do(client, q.keys("delayed", "ready", "running"),
+			time.Now().UnixMilli(), q.opts.BatchSize).Int()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		// 两个集合每次各搬运至多BatchSize个，不足时说明已搬运完
+		if n < q.opts.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// Stats 返回队列中各状态的任务数
+func (q *Queue) Stats(ctx context.Context) (Stats, error) {
+	p := q.client.WithContext(ctx).Pipeline()
+	p.Zset.Zcard(q.key("delayed"))
+	p.List.Llen(q.key("ready"))
+	p.Zset.Zcard(q.key("running"))
+	p.List.Llen(q.key("dead"))
+	replies, err := p.Exec()
+	if err != nil {
+		return Stats{}, err
+	}
+	counts := make([]int, len(replies))
+	for i, r := range replies {
+		counts[i], _ = r.Int()
+	}
+	return Stats{Delayed: counts[0], Ready: counts[1], Running: counts[2], Dead: counts[3]}, nil
+}
+
+// Dead 查询死信列表中的任务
+// start: int 起始位置，从0开始
+// stop: int 截止位置，-1表示最后一个
+func (q *Queue) Dead(ctx context.Context, start, stop int) ([]*Job, error) {
+	client := q.client.WithContext(ctx)
+	ids, err := client.List.Lrange(q.key("dead"), start, stop)
+	if err != nil || len(ids) == 0 {
+		return []*Job{}, err
+	}
+	p := client.Pipeline()
+	p.Hash.Hmget(q.key("jobs"), ids)
+	p.Hash.Hmget(q.key("attempts"), ids)
+	p.Hash.Hmget(q.key("errors"), ids)
+	replies, err := p.Exec()
+	if err != nil {
+		return []*Job{}, err
+	}
+	bodies, _ := replies[0].Strings()
+	attempts, _ := replies[1].Ints()
+	errs, _ := replies[2].Strings()
+
+	jobs := make([]*Job, 0, len(ids))
+	for i, id := range ids {
+		job, err := decodeJob(id, attempts[i], bodies[i])
+		if err != nil {
+			continue
+		}
+		job.Error = errs[i]
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// RequeueDead 将死信列表中最早的count个任务重新投递，执行次数清零
+// return: int 重新投递的任务数
+func (q *Queue) RequeueDead(ctx context.Context, count int) (int, error) {
+	if count <= 0 {
+		return 0, nil
+	}
+	return requeueScript.Do(q.client.WithContext(ctx), q.keys("dead", "ready", "attempts", "errors"), count).Int()
+}
+
+// 取出一个就绪任务，没有就绪任务时返回nil
+func (q *Queue) reserve(ctx context.Context) (*Job, error) {
+	deadline := time.Now().Add(q.opts.Visibility).UnixMilli()
+	res, err := redigo.Values(reserveScript.Do(q.client.WithContext(ctx),
+		q.keys("ready", "jobs", "attempts", "running"), deadline).Value())
+	if err == redigo.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var (
+		id, body string
+		attempt  int
+	)
+	if _, err := redigo.Scan(res, &id, &attempt, &body); err != nil {
+		return nil, err
+	}
+	return decodeJob(id, attempt, body)
+}
+
+// 延长执行中任务的可见性超时
+// return: bool 任务是否仍由本次执行持有
+func (q *Queue) touch(ctx context.Context, job *Job) (bool, error) {
+	deadline := time.Now().Add(q.opts.Visibility).UnixMilli()
+	n, err := touchScript.Do(q.client.WithContext(ctx), q.keys("attempts", "running"),
+		job.ID, job.Attempt, deadline).Int()
+	return n > 0, err
+}
+
+// 执行成功，删除任务，任务已被其他执行重新取出时不做处理
+func (q *Queue) ack(ctx context.Context, job *Job) error {
+	_, err := ackScript.Do(q.client.WithContext(ctx), q.keys("attempts", "jobs", "errors", "delayed", "ready", "running"),
+		job.ID, job.Attempt).Int()
+	return err
+}
+
+// 执行失败，未超过最大重试次数时按退避时间重新延迟，否则转入死信列表
+func (q *Queue) fail(ctx context.Context, job *Job, cause error) error {
+	dead := "0"
+	if job.Attempt > job.MaxRetries || errors.Is(cause, ErrDiscard) {
+		dead = "1"
+	}
+	due := time.Now().Add(q.opts.Backoff(job.Attempt)).UnixMilli()
+	_, err := failScript.Do(q.client.WithContext(ctx), q.keys("attempts", "errors", "running", "delayed", "dead"),
+		job.ID, job.Attempt, due, cause.Error(), dead).Int()
+	return err
+}
+
+func decodeJob(id string, attempt int, body string) (*Job, error) {
+	var b jobBody
+	if err := json.Unmarshal([]byte(body), &b); err != nil {
+		return nil, err
+	}
+	return &Job{
+		ID:         id,
+		Payload:    b.Payload,
+		Attempt:    attempt,
+		MaxRetries: b.MaxRetries,
+		CreatedAt:  time.UnixMilli(b.CreatedAt),
+	}, nil
+}
+
+// 队列键名，使用hash tag保证同一队列的键位于同一槽位，以便在Lua脚本中同时操作
+func (q *Queue) key(name string) string {
+	return q.opts.Prefix + "{" + q.name + "}:" + name
+}
+
+func (q *Queue) keys(names ...string) []string {
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		keys = append(keys, q.key(name))
+	}
+	return keys
+}
+
+// 默认退避时间：1s、2s、4s……最大10m，并加入随机抖动
+func defaultBackoff(attempt int) time.Duration {
+	d := minBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return prand.Duration(d/2, d)
+}
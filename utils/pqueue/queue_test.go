@@ -0,0 +1,326 @@
+package pqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/perpower/goframe/utils/pdb/redis"
+	"github.com/perpower/goframe/utils/pdb/redis/redistest"
+)
+
+func newQueue(t *testing.T, opts Options) (*Queue, *redistest.Server) {
+	t.Helper()
+	s := redistest.NewServer(t)
+	client, err := redis.New(redis.Config{Address: s.Addr()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if opts.Backoff == nil {
+		opts.Backoff = func(attempt int) time.Duration { return 0 }
+	}
+	return New(client, "test", opts), s
+}
+
+func checkStats(t *testing.T, q *Queue, want Stats) {
+	t.Helper()
+	got, err := q.Stats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("Stats = %+v, want %+v", got, want)
+	}
+}
+
+func mustReserve(t *testing.T, q *Queue) *Job {
+	t.Helper()
+	job, err := q.reserve(context.Background())
+	if err != nil || job == nil {
+		t.Fatalf("reserve = %v, %v", job, err)
+	}
+	return job
+}
+
+func TestEnqueueAndPromote(t *testing.T) {
+	q, _ := newQueue(t, Options{})
+	ctx := context.Background()
+
+	now, err := q.Enqueue(ctx, "now", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(ctx, "later", 50*time.Millisecond, EnqueueOptions{ID: "later"}); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := q.Enqueue(ctx, "again", 0, EnqueueOptions{ID: "later"}); err != ErrJobExists || id != "later" {
+		t.Fatalf("duplicate ID = %s, %v, want ErrJobExists", id, err)
+	}
+	checkStats(t, q, Stats{Delayed: 1, Ready: 1})
+
+	if n, err := q.Promote(ctx); err != nil || n != 0 {
+		t.Fatalf("Promote before due = %d, %v", n, err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if n, err := q.Promote(ctx); err != nil || n != 1 {
+		t.Fatalf("Promote after due = %d, %v", n, err)
+	}
+	checkStats(t, q, Stats{Ready: 2})
+
+	// 按就绪顺序取出
+	first := mustReserve(t, q)
+	if first.ID != now || first.Payload != "now" || first.Attempt != 1 || first.MaxRetries != defaultMaxRetries {
+		t.Fatalf("first job = %+v", first)
+	}
+	if second := mustReserve(t, q); second.ID != "later" {
+		t.Fatalf("second job = %+v", second)
+	}
+	if job, err := q.reserve(ctx); job != nil || err != nil {
+		t.Fatalf("reserve on an empty queue = %v, %v", job, err)
+	}
+	checkStats(t, q, Stats{Running: 2})
+
+	if ok, err := q.Cancel(ctx, "later"); err != nil || !ok {
+		t.Fatalf("Cancel = %v, %v", ok, err)
+	}
+	if ok, err := q.Cancel(ctx, "later"); err != nil || ok {
+		t.Fatalf("Cancel twice = %v, %v", ok, err)
+	}
+	checkStats(t, q, Stats{Running: 1})
+}
+
+func TestFailAndAck(t *testing.T) {
+	q, s := newQueue(t, Options{})
+	ctx := context.Background()
+	if _, err := q.Enqueue(ctx, "p", 0, EnqueueOptions{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	job := mustReserve(t, q)
+	if err := q.fail(ctx, job, errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+	checkStats(t, q, Stats{Delayed: 1})
+	if n, _ := q.Promote(ctx); n != 1 {
+		t.Fatalf("Promote = %d, want the failed job", n)
+	}
+	job = mustReserve(t, q)
+	if job.Attempt != 2 {
+		t.Fatalf("Attempt = %d, want 2", job.Attempt)
+	}
+	if err := q.ack(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	checkStats(t, q, Stats{})
+	if keys := s.Keys(); len(keys) != 0 {
+		t.Fatalf("keys left after ack: %v", keys)
+	}
+}
+
+func TestStaleExecutionCannotAckOrFail(t *testing.T) {
+	q, _ := newQueue(t, Options{Visibility: 30 * time.Millisecond})
+	ctx := context.Background()
+	if _, err := q.Enqueue(ctx, "p", 0, EnqueueOptions{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 可见性超时后任务被重新取出，原执行的确认及失败不生效
+	stale := mustReserve(t, q)
+	time.Sleep(40 * time.Millisecond)
+	if n, _ := q.Promote(ctx); n != 1 {
+		t.Fatalf("Promote = %d, want the timed out job", n)
+	}
+	current := mustReserve(t, q)
+	if current.Attempt != 2 {
+		t.Fatalf("Attempt = %d, want 2", current.Attempt)
+	}
+	if err := q.ack(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.fail(ctx, stale, errors.New("late")); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := q.touch(ctx, stale); err != nil || held {
+		t.Fatalf("touch by the stale execution = %v, %v", held, err)
+	}
+	checkStats(t, q, Stats{Running: 1})
+	if held, err := q.touch(ctx, current); err != nil || !held {
+		t.Fatalf("touch = %v, %v", held, err)
+	}
+	if err := q.ack(ctx, current); err != nil {
+		t.Fatal(err)
+	}
+	checkStats(t, q, Stats{})
+
+	// 超时后尚未被重新取出时，确认同时从就绪列表中删除
+	if _, err := q.Enqueue(ctx, "p", 0, EnqueueOptions{ID: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	job := mustReserve(t, q)
+	time.Sleep(40 * time.Millisecond)
+	q.Promote(ctx)
+	if err := q.ack(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	checkStats(t, q, Stats{})
+}
+
+func TestDeadLetter(t *testing.T) {
+	q, _ := newQueue(t, Options{})
+	ctx := context.Background()
+	if _, err := q.Enqueue(ctx, "retry", 0, EnqueueOptions{ID: "a", MaxRetries: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(ctx, "discard", 0, EnqueueOptions{ID: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 超过最大重试次数后转入死信
+	for attempt := 1; attempt <= 2; attempt++ {
+		job := mustReserve(t, q)
+		if job.ID != "a" || job.Attempt != attempt {
+			t.Fatalf("job = %+v, want a attempt %d", job, attempt)
+		}
+		if err := q.fail(ctx, job, fmt.Errorf("failed %d", attempt)); err != nil {
+			t.Fatal(err)
+		}
+		q.Promote(ctx)
+		if attempt == 1 {
+			// 重试的任务排在b之后
+			if job := mustReserve(t, q); job.ID != "b" {
+				t.Fatalf("job = %+v, want b", job)
+			} else if err := q.fail(ctx, job, fmt.Errorf("bad payload: %w", ErrDiscard)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	checkStats(t, q, Stats{Dead: 2})
+
+	dead, err := q.Dead(ctx, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 2 || dead[0].ID != "b" || dead[0].Attempt != 1 || dead[1].ID != "a" || dead[1].Attempt != 2 || dead[1].Error != "failed 2" {
+		t.Fatalf("Dead = %+v %+v", dead[0], dead[1])
+	}
+
+	if n, err := q.RequeueDead(ctx, 1); err != nil || n != 1 {
+		t.Fatalf("RequeueDead = %d, %v", n, err)
+	}
+	checkStats(t, q, Stats{Ready: 1, Dead: 1})
+	if job := mustReserve(t, q); job.ID != "b" || job.Attempt != 1 {
+		t.Fatalf("requeued job = %+v, want b with attempts reset", job)
+	}
+}
+
+func TestRun(t *testing.T) {
+	q, _ := newQueue(t, Options{PollInterval: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < 5; i++ {
+		if _, err := q.Enqueue(ctx, fmt.Sprint(i), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.Enqueue(ctx, "delayed", 30*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu       sync.Mutex
+		attempts = map[string]int{}
+		done     = make(chan struct{})
+	)
+	handler := func(ctx context.Context, job *Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[job.Payload]++
+		if job.Payload == "2" && job.Attempt == 1 {
+			return errors.New("first attempt fails")
+		}
+		if job.Payload == "3" && job.Attempt == 1 {
+			panic("first attempt panics")
+		}
+		if len(attempts) == 6 && attempts["2"] == 2 && attempts["3"] == 2 {
+			close(done)
+		}
+		return nil
+	}
+	stopped := make(chan error)
+	go func() { stopped <- q.Run(ctx, 3, handler) }()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("jobs not processed: %v", attempts)
+	}
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+	checkStats(t, q, Stats{})
+}
+
+func TestRunWaitsForRunningJobs(t *testing.T) {
+	q, _ := newQueue(t, Options{PollInterval: 10 * time.Millisecond, ShutdownTimeout: time.Second})
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := q.Enqueue(ctx, "slow", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	finished := false
+	stopped := make(chan error)
+	go func() {
+		stopped <- q.Run(ctx, 1, func(jobCtx context.Context, job *Job) error {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			finished = jobCtx.Err() == nil
+			return nil
+		})
+	}()
+	<-started
+	// ctx结束后等待执行中的任务完成并确认
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+	if !finished {
+		t.Fatal("the running job should finish with a live ctx")
+	}
+	checkStats(t, q, Stats{})
+}
+
+func TestRunCancelsJobsAfterShutdownTimeout(t *testing.T) {
+	q, _ := newQueue(t, Options{PollInterval: 10 * time.Millisecond, ShutdownTimeout: 30 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := q.Enqueue(ctx, "stuck", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	stopped := make(chan error)
+	go func() {
+		stopped <- q.Run(ctx, 1, func(jobCtx context.Context, job *Job) error {
+			close(started)
+			<-jobCtx.Done()
+			return jobCtx.Err()
+		})
+	}()
+	<-started
+	cancel()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after ShutdownTimeout")
+	}
+	// 被取消的任务按失败处理，等待重试
+	checkStats(t, q, Stats{Delayed: 1})
+}
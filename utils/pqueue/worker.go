@@ -0,0 +1,169 @@
+// 消费者工作池
+package pqueue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Handler 任务处理函数，返回nil表示执行成功，任务被删除；返回error则按退避时间重试
+type Handler func(ctx context.Context, job *Job) error
+
+// Run 启动消费者工作池并阻塞，同时定时搬运到期任务
+// ctx结束后不再取出新任务，等待执行中的任务完成后返回；超过ShutdownTimeout仍未完成的任务其ctx被取消，
+// 未确认的任务在可见性超时后重新投递
+// concurrency: int 并发执行的任务数
+func (q *Queue) Run(ctx context.Context, concurrency int, handler Handler) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// 任务执行及确认使用独立的ctx，不随ctx结束立即取消
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-stopped:
+			return
+		case <-ctx.Done():
+		}
+		timer := time.NewTimer(q.opts.ShutdownTimeout)
+		defer timer.Stop()
+		select {
+		case <-stopped:
+		case <-timer.C:
+			cancelJobs()
+		}
+	}()
+
+	// 就绪列表有新任务时唤醒空闲的消费者
+	wake := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.promoteLoop(ctx, wake)
+	}()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, jobCtx, wake, handler)
+		}()
+	}
+	wg.Wait()
+	close(stopped)
+	return nil
+}
+
+// 定时搬运到期任务
+func (q *Queue) promoteLoop(ctx context.Context, wake chan<- struct{}) {
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		n, err := q.Promote(ctx)
+		if err != nil && ctx.Err() == nil {
+			q.onError(err)
+		}
+		for i := 0; i < n && i < cap(wake); i++ {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 单个消费者循环取出并执行任务
+func (q *Queue) work(ctx, jobCtx context.Context, wake <-chan struct{}, handler Handler) {
+	for ctx.Err() == nil {
+		job, err := q.reserve(ctx)
+		if err != nil && ctx.Err() == nil {
+			q.onError(err)
+		}
+		if job != nil {
+			q.process(jobCtx, job, handler)
+			continue
+		}
+		timer := time.NewTimer(q.opts.PollInterval)
+		select {
+		case <-ctx.Done():
+		case <-wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// 执行任务，执行期间定时续期可见性超时
+func (q *Queue) process(ctx context.Context, job *Job, handler Handler) {
+	if job.Attempt > job.MaxRetries+1 {
+		// 多次可见性超时仍未确认(如执行时进程崩溃)，不再执行，直接转入死信列表
+		if err := q.fail(context.Background(), job, errTimeout); err != nil {
+			q.onError(err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(q.opts.Visibility / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				held, err := q.touch(ctx, job)
+				if err != nil {
+					q.onError(err)
+					continue
+				}
+				if !held {
+					// 任务已被重新投递或删除，继续执行没有意义
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	// 确认不受任务ctx取消的影响，避免已执行成功的任务被重新投递
+	err := safeCall(ctx, job, handler)
+	if err == nil {
+		err = q.ack(context.Background(), job)
+	} else {
+		err = q.fail(context.Background(), job, err)
+	}
+	if err != nil {
+		q.onError(err)
+	}
+}
+
+// 执行处理函数，panic视为执行失败
+func safeCall(ctx context.Context, job *Job, handler Handler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("pqueue: 任务执行panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+func (q *Queue) onError(err error) {
+	if q.opts.OnError != nil {
+		q.opts.OnError(err)
+	}
+}
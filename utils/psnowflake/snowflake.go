@@ -51,7 +51,7 @@ type Snowflake struct {
 var redisClient *redis.Client
 
 // NewSnowflake
-// redisObj： *redis.Client 已经实例化的redis链接对象
+// redisObj： *redis.Client 已经实例化的redis链接对象，多个服务共用时可传入 Namespace 返回的视图，为 snowflake:* 键名增加前缀
 // datacenterid: int64
 // workerid: int64
 func NewSnowflake(redisObj *redis.Client, datacenterid, workerid int64) (*Snowflake, error) {